@@ -6,14 +6,26 @@ import (
 	"sync"
 )
 
-type Repository struct {
+// Storage of addresses. Ids of known addresses are cached
+type Repository interface {
+	FindId(address string) (uint64, error)
+	FindIdOrCreate(address string) (uint64, error)
+	CachePlaceholder(address string, id uint64)
+	FindById(id uint64) (string, error)
+	FindAll(addresses []string) ([]*models.Address, error)
+	SaveAllIfNotExist(addresses []string) error
+	FindAllChangedInBlocks(from, to uint64) ([]string, error)
+	SaveFromMapIfNotExists(addresses map[string]struct{}) error
+}
+
+type pgRepository struct {
 	db       *pg.DB
 	cache    *sync.Map
 	invCache *sync.Map
 }
 
-func NewRepository(db *pg.DB) *Repository {
-	return &Repository{
+func NewRepository(db *pg.DB) Repository {
+	return &pgRepository{
 		db:       db,
 		cache:    new(sync.Map), //TODO: добавить реализацию очистки
 		invCache: new(sync.Map), //TODO: добавить реализацию очистки
@@ -21,7 +33,7 @@ func NewRepository(db *pg.DB) *Repository {
 }
 
 //Find address id
-func (r *Repository) FindId(address string) (uint64, error) {
+func (r *pgRepository) FindId(address string) (uint64, error) {
 	//First look in the cache
 	id, ok := r.cache.Load(address)
 	if ok {
@@ -37,7 +49,7 @@ func (r *Repository) FindId(address string) (uint64, error) {
 }
 
 //Find address id or create if not exist
-func (r *Repository) FindIdOrCreate(address string) (uint64, error) {
+func (r *pgRepository) FindIdOrCreate(address string) (uint64, error) {
 	//First look in the cache
 	id, ok := r.cache.Load(address)
 	if ok {
@@ -58,7 +70,12 @@ func (r *Repository) FindIdOrCreate(address string) (uint64, error) {
 	return adr.ID, err
 }
 
-func (r *Repository) FindById(id uint64) (string, error) {
+//Store the id of the address which would be created to the cache only, used by the dry run
+func (r *pgRepository) CachePlaceholder(address string, id uint64) {
+	r.cache.Store(address, id)
+}
+
+func (r *pgRepository) FindById(id uint64) (string, error) {
 	//First look in the cache
 	address, ok := r.invCache.Load(id)
 	if ok {
@@ -74,7 +91,7 @@ func (r *Repository) FindById(id uint64) (string, error) {
 	return a.Address, nil
 }
 
-func (r *Repository) FindAll(addresses []string) ([]*models.Address, error) {
+func (r *pgRepository) FindAll(addresses []string) ([]*models.Address, error) {
 	var aList []*models.Address
 	err := r.db.Model(&aList).Where(`address in (?)`, pg.In(addresses)).Select()
 	if err != nil {
@@ -84,7 +101,7 @@ func (r *Repository) FindAll(addresses []string) ([]*models.Address, error) {
 	return aList, err
 }
 
-func (r *Repository) SaveAllIfNotExist(addresses []string) error {
+func (r *pgRepository) SaveAllIfNotExist(addresses []string) error {
 	// if all addresses exists in cache do nothing
 	loadFromDb := r.checkNotInCache(addresses)
 	if len(loadFromDb) == 0 {
@@ -112,7 +129,22 @@ func (r *Repository) SaveAllIfNotExist(addresses []string) error {
 	return err
 }
 
-func (r Repository) SaveFromMapIfNotExists(addresses map[string]struct{}) error {
+// Addresses which have transactions, rewards or slashes in blocks of the (from, to] range
+func (r *pgRepository) FindAllChangedInBlocks(from, to uint64) ([]string, error) {
+	var addresses []string
+	_, err := r.db.Query(&addresses, `
+select a.address
+from addresses a
+where a.id in (select address_id from index_transaction_by_address where block_id > ? and block_id <= ?
+               union
+               select address_id from rewards where block_id > ? and block_id <= ?
+               union
+               select address_id from slashes where block_id > ? and block_id <= ?);
+	`, from, to, from, to, from, to)
+	return addresses, err
+}
+
+func (r pgRepository) SaveFromMapIfNotExists(addresses map[string]struct{}) error {
 	list := make([]string, len(addresses))
 	i := 0
 	for k := range addresses {
@@ -122,7 +154,7 @@ func (r Repository) SaveFromMapIfNotExists(addresses map[string]struct{}) error
 	return r.SaveAllIfNotExist(list)
 }
 
-func (r *Repository) addToCache(addresses []*models.Address) {
+func (r *pgRepository) addToCache(addresses []*models.Address) {
 	for _, a := range addresses {
 		_, exist := r.cache.Load(a)
 		if !exist {
@@ -132,7 +164,7 @@ func (r *Repository) addToCache(addresses []*models.Address) {
 	}
 }
 
-func (r *Repository) checkNotInCache(addresses []string) []string {
+func (r *pgRepository) checkNotInCache(addresses []string) []string {
 	var list []string
 	for _, a := range addresses {
 		_, exist := r.cache.Load(a)
@@ -1,8 +1,13 @@
 package address
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/barrier"
+	"github.com/MinterTeam/minter-explorer-extender/quarantine"
+	"github.com/MinterTeam/minter-explorer-extender/retry"
 	"github.com/MinterTeam/minter-explorer-tools/helpers"
 	"github.com/MinterTeam/minter-explorer-tools/models"
 	"github.com/MinterTeam/minter-go-node/core/check"
@@ -10,87 +15,117 @@ import (
 	"github.com/sirupsen/logrus"
 	"math"
 	"strconv"
-	"sync"
 )
 
 type Service struct {
 	env                *models.ExtenderEnvironment
-	repository         *Repository
+	repository         Repository
 	chBalanceAddresses chan<- models.BlockAddresses
-	jobSaveAddresses   chan []string
-	wgAddresses        sync.WaitGroup
+	jobSaveAddresses   chan SaveAddressesJob
+	retryPolicy        *retry.Policy
 	logger             *logrus.Entry
 }
 
-func NewService(env *models.ExtenderEnvironment, repository *Repository, chBalanceAddresses chan<- models.BlockAddresses, logger *logrus.Entry) *Service {
+func NewService(env *models.ExtenderEnvironment, repository Repository, chBalanceAddresses chan<- models.BlockAddresses,
+	retryPolicy *retry.Policy, logger *logrus.Entry) *Service {
 	return &Service{
 		env:                env,
 		repository:         repository,
 		chBalanceAddresses: chBalanceAddresses,
-		jobSaveAddresses:   make(chan []string, env.WrkSaveAddressesCount),
+		retryPolicy:        retryPolicy,
+		jobSaveAddresses:   make(chan SaveAddressesJob, env.WrkSaveAddressesCount),
 		logger:             logger,
 	}
 }
 
-func (s *Service) GetSaveAddressesJobChannel() chan []string {
+// Chunk of block addresses, the result is reported to the barrier of the block
+type SaveAddressesJob struct {
+	Addresses []string
+	barrier   *barrier.Barrier
+}
+
+func (s *Service) GetSaveAddressesJobChannel() chan SaveAddressesJob {
 	return s.jobSaveAddresses
 }
 
-func (s *Service) SaveAddressesWorker(jobs <-chan []string) {
-	for addresses := range jobs {
-		err := s.repository.SaveAllIfNotExist(addresses)
+func (s *Service) SaveAddressesWorker(jobs <-chan SaveAddressesJob) {
+	for job := range jobs {
+		ctx := job.barrier.Context()
+		if ctx.Err() != nil {
+			job.barrier.Done(ctx.Err())
+			continue
+		}
+		err := s.retryPolicy.Do(ctx, func() error {
+			return s.repository.SaveAllIfNotExist(job.Addresses)
+		})
 		if err != nil {
 			s.logger.Error(err)
 		}
-		helpers.HandleError(err)
-
-		s.wgAddresses.Done()
+		job.barrier.Done(err)
 	}
 }
 
+// Transactions which fail to parse are skipped, they are quarantined when the block is saved
 func (s *Service) ExtractAddressesFromTransactions(transactions []responses.Transaction) ([]string, error, map[string]struct{}) {
 	var mapAddresses = make(map[string]struct{}) //use as unique array
 	for _, tx := range transactions {
-		if tx.Data == nil {
-			s.logger.Error("empty transaction data")
-			return nil, errors.New("empty transaction data"), nil
+		err := s.extractTransactionAddresses(tx, mapAddresses)
+		if err != nil {
+			s.logger.WithFields(logrus.Fields{
+				"Tx": tx.Hash,
+			}).Error(err)
+		}
+	}
+	addresses := addressesMapToSlice(mapAddresses)
+	return addresses, nil, mapAddresses
+}
+
+func (s *Service) extractTransactionAddresses(tx responses.Transaction, mapAddresses map[string]struct{}) (err error) {
+	defer quarantine.Recover(&err)
+
+	mapAddresses[helpers.RemovePrefix(tx.From)] = struct{}{}
+	if tx.Data == nil {
+		return errors.New("empty transaction data")
+	}
+	switch tx.Type {
+	case models.TxTypeSend:
+		data, ok := tx.IData.(models.SendTxData)
+		if !ok {
+			return unexpectedDataError(tx)
+		}
+		mapAddresses[helpers.RemovePrefix(data.To)] = struct{}{}
+	case models.TxTypeMultiSend:
+		data, ok := tx.IData.(models.MultiSendTxData)
+		if !ok {
+			return unexpectedDataError(tx)
+		}
+		for _, receiver := range data.List {
+			mapAddresses[helpers.RemovePrefix(receiver.To)] = struct{}{}
 		}
-		mapAddresses[helpers.RemovePrefix(tx.From)] = struct{}{}
-		if tx.Type == models.TxTypeSend {
-			mapAddresses[helpers.RemovePrefix(tx.IData.(models.SendTxData).To)] = struct{}{}
+	case models.TxTypeRedeemCheck:
+		data, ok := tx.IData.(models.RedeemCheckTxData)
+		if !ok {
+			return unexpectedDataError(tx)
 		}
-		if tx.Type == models.TxTypeMultiSend {
-			for _, receiver := range tx.IData.(models.MultiSendTxData).List {
-				mapAddresses[helpers.RemovePrefix(receiver.To)] = struct{}{}
-			}
+		decoded, err := base64.StdEncoding.DecodeString(data.RawCheck)
+		if err != nil {
+			return err
 		}
-		if tx.Type == models.TxTypeRedeemCheck {
-			decoded, err := base64.StdEncoding.DecodeString(tx.IData.(models.RedeemCheckTxData).RawCheck)
-			if err != nil {
-				s.logger.WithFields(logrus.Fields{
-					"Tx": tx.Hash,
-				}).Error(err)
-				continue
-			}
-			data, err := check.DecodeFromBytes(decoded)
-			if err != nil {
-				s.logger.WithFields(logrus.Fields{
-					"Tx": tx.Hash,
-				}).Error(err)
-				continue
-			}
-			sender, err := data.Sender()
-			if err != nil {
-				s.logger.WithFields(logrus.Fields{
-					"Tx": tx.Hash,
-				}).Error(err)
-				continue
-			}
-			mapAddresses[helpers.RemovePrefix(sender.String())] = struct{}{}
+		checkData, err := check.DecodeFromBytes(decoded)
+		if err != nil {
+			return err
 		}
+		sender, err := checkData.Sender()
+		if err != nil {
+			return err
+		}
+		mapAddresses[helpers.RemovePrefix(sender.String())] = struct{}{}
 	}
-	addresses := addressesMapToSlice(mapAddresses)
-	return addresses, nil, mapAddresses
+	return nil
+}
+
+func unexpectedDataError(tx responses.Transaction) error {
+	return fmt.Errorf("unexpected data %T of transaction type %d", tx.IData, tx.Type)
 }
 
 func (s *Service) ExtractAddressesEventsResponse(response *responses.EventsResponse) ([]string, map[string]struct{}) {
@@ -109,8 +144,23 @@ func (s *Service) ExtractAddressesEventsResponse(response *responses.EventsRespo
 	return addresses, mapAddresses
 }
 
-// Find all addresses in block response and save it
+// Find all addresses in block response, save it and pass them to the balance service
 func (s *Service) HandleResponses(blockResponse *responses.BlockResponse, eventsResponse *responses.EventsResponse) error {
+	height, addresses, err := s.SaveFromResponses(blockResponse, eventsResponse)
+	if err != nil {
+		return err
+	}
+	// Sent even without addresses, so the balances stage completes every height.
+	// Without the channel balances are refreshed by another instance
+	if height != 0 && s.chBalanceAddresses != nil {
+		s.chBalanceAddresses <- models.BlockAddresses{Height: height, Addresses: addresses}
+	}
+	return nil
+}
+
+// Find all addresses in block and events responses.
+// Return the block height and the list of addresses
+func (s *Service) ExtractFromResponses(blockResponse *responses.BlockResponse, eventsResponse *responses.EventsResponse) (uint64, []string, error) {
 	var (
 		err                error
 		height             uint64
@@ -122,14 +172,14 @@ func (s *Service) HandleResponses(blockResponse *responses.BlockResponse, events
 		height, err = strconv.ParseUint(blockResponse.Result.Height, 10, 64)
 		if err != nil {
 			s.logger.Error(err)
-			return err
+			return 0, nil, err
 		}
 	}
 	if blockResponse != nil && blockResponse.Result.TxCount != "0" {
 		_, err, blockAddressesMap = s.ExtractAddressesFromTransactions(blockResponse.Result.Transactions)
 		if err != nil {
 			s.logger.Error(err)
-			return err
+			return 0, nil, err
 		}
 	}
 	if eventsResponse != nil && len(eventsResponse.Result.Events) > 0 {
@@ -140,27 +190,34 @@ func (s *Service) HandleResponses(blockResponse *responses.BlockResponse, events
 		blockAddressesMap[k] = v
 	}
 
-	addresses := addressesMapToSlice(blockAddressesMap)
+	return height, addressesMapToSlice(blockAddressesMap), nil
+}
 
-	if len(addresses) > 0 {
-		chunksCount := int(math.Ceil(float64(len(addresses)) / float64(s.env.TxChunkSize)))
-		for i := 0; i < chunksCount; i++ {
-			start := s.env.TxChunkSize * i
-			end := start + s.env.TxChunkSize
-			if end > len(addresses) {
-				end = len(addresses)
-			}
-			s.wgAddresses.Add(1)
-			s.GetSaveAddressesJobChannel() <- addresses[start:end]
-		}
-		s.wgAddresses.Wait()
+// Find all addresses in block response and save it.
+// Return the block height and the list of addresses
+func (s *Service) SaveFromResponses(blockResponse *responses.BlockResponse, eventsResponse *responses.EventsResponse) (uint64, []string, error) {
+	height, addresses, err := s.ExtractFromResponses(blockResponse, eventsResponse)
+	if err != nil {
+		return 0, nil, err
+	}
 
-		if height != 0 {
-			s.chBalanceAddresses <- models.BlockAddresses{Height: height, Addresses: addresses}
+	chunksCount := int(math.Ceil(float64(len(addresses)) / float64(s.env.TxChunkSize)))
+	blockBarrier := barrier.New(context.Background(), chunksCount)
+	for i := 0; i < chunksCount; i++ {
+		start := s.env.TxChunkSize * i
+		end := start + s.env.TxChunkSize
+		if end > len(addresses) {
+			end = len(addresses)
 		}
+		s.GetSaveAddressesJobChannel() <- SaveAddressesJob{Addresses: addresses[start:end], barrier: blockBarrier}
+	}
+	err = blockBarrier.Wait()
+	if err != nil {
+		s.logger.WithField("block", height).Error(err)
+		return 0, nil, err
 	}
 
-	return nil
+	return height, addresses, nil
 }
 
 func addressesMapToSlice(mapAddresses map[string]struct{}) []string {
@@ -0,0 +1,36 @@
+package address
+
+import (
+	"encoding/json"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestExtractAddressesSkipsMalformedTransactions(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	s := &Service{logger: logrus.NewEntry(logger)}
+	data := json.RawMessage(`{}`)
+
+	addresses, err, _ := s.ExtractAddressesFromTransactions([]responses.Transaction{
+		{From: "Mxa1", Type: models.TxTypeSend, Data: data, IData: models.SendTxData{To: "Mxb1"}},
+		{From: "Mxa2", Type: models.TxTypeSend, Data: data, IData: models.MultiSendTxData{}},
+		{From: "Mxa3", Type: models.TxTypeMultiSend, Data: data},
+		{From: "Mxa4", Type: models.TxTypeRedeemCheck, Data: data, IData: models.RedeemCheckTxData{RawCheck: "invalid"}},
+		{From: "Mxa5", Type: models.TxTypeSend},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(addresses)
+	// Senders of skipped transactions are kept, so invalid transactions can still be saved
+	want := []string{"a1", "a2", "a3", "a4", "a5", "b1"}
+	if !reflect.DeepEqual(addresses, want) {
+		t.Errorf("addresses = %v, want %v", addresses, want)
+	}
+}
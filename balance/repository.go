@@ -3,19 +3,37 @@ package balance
 import (
 	"github.com/MinterTeam/minter-explorer-tools/models"
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 )
 
-type Repository struct {
-	db *pg.DB
+// Storage of balances of addresses
+type Repository interface {
+	WithTx(tx *pg.Tx) Repository
+	FindAllByAddress(addresses []string) ([]*models.Balance, error)
+	SaveAll(balances []*models.Balance) error
+	UpdateAll(balances []*models.Balance) error
+	DeleteAll(balances []*models.Balance) error
+	DeleteByCoinId(coinId uint64) error
 }
 
-func NewRepository(db *pg.DB) *Repository {
-	return &Repository{
+type pgRepository struct {
+	db orm.DB
+}
+
+func NewRepository(db orm.DB) Repository {
+	return &pgRepository{
 		db: db,
 	}
 }
 
-func (r *Repository) FindAllByAddress(addresses []string) ([]*models.Balance, error) {
+// Return a copy of the repository which runs all queries inside the transaction
+func (r *pgRepository) WithTx(tx *pg.Tx) Repository {
+	return &pgRepository{
+		db: tx,
+	}
+}
+
+func (r *pgRepository) FindAllByAddress(addresses []string) ([]*models.Balance, error) {
 	var balances []*models.Balance
 	err := r.db.Model(&balances).
 		Column("balance.*", "Address", "Coin").
@@ -24,7 +42,7 @@ func (r *Repository) FindAllByAddress(addresses []string) ([]*models.Balance, er
 	return balances, err
 }
 
-func (r *Repository) SaveAll(balances []*models.Balance) error {
+func (r *pgRepository) SaveAll(balances []*models.Balance) error {
 	var args []interface{}
 	for _, balance := range balances {
 		args = append(args, balance)
@@ -36,17 +54,17 @@ func (r *Repository) SaveAll(balances []*models.Balance) error {
 	return r.db.Insert(args...)
 }
 
-func (r *Repository) UpdateAll(balances []*models.Balance) error {
+func (r *pgRepository) UpdateAll(balances []*models.Balance) error {
 	_, err := r.db.Model(&balances).Update()
 	return err
 }
 
-func (r *Repository) DeleteAll(balances []*models.Balance) error {
+func (r *pgRepository) DeleteAll(balances []*models.Balance) error {
 	_, err := r.db.Model(&balances).Delete()
 	return err
 }
 
-func (r Repository) DeleteByCoinId(coinId uint64) error {
+func (r pgRepository) DeleteByCoinId(coinId uint64) error {
 	_, err := r.db.Model(new(models.Balance)).Where("coin_id = ?", coinId).Delete()
 	return err
 }
@@ -1,57 +1,75 @@
 package balance
 
 import (
+	"context"
+	"fmt"
 	"github.com/MinterTeam/minter-explorer-extender/address"
 	"github.com/MinterTeam/minter-explorer-extender/broadcast"
 	"github.com/MinterTeam/minter-explorer-extender/coin"
+	"github.com/MinterTeam/minter-explorer-extender/node"
+	"github.com/MinterTeam/minter-explorer-extender/progress"
+	"github.com/MinterTeam/minter-explorer-extender/queue"
 	"github.com/MinterTeam/minter-explorer-tools/helpers"
 	"github.com/MinterTeam/minter-explorer-tools/models"
-	"github.com/MinterTeam/minter-node-go-api"
 	"github.com/MinterTeam/minter-node-go-api/responses"
 	"github.com/sirupsen/logrus"
-	"math"
+	"sort"
 	"sync"
+	"time"
+)
+
+const (
+	balanceJobLease   = time.Minute     // time to refresh claimed addresses before other workers can take them
+	queuePollInterval = 1 * time.Second // delay before the next claim when the queue is empty
 )
 
 type Service struct {
-	env                    *models.ExtenderEnvironment
-	nodeApi                *minter_node_go_api.MinterNodeApi
-	repository             *Repository
-	addressRepository      *address.Repository
-	coinRepository         *coin.Repository
-	broadcastService       *broadcast.Service
-	jobGetBalancesFromNode chan models.BlockAddresses
-	jobUpdateBalance       chan AddressesBalancesContainer
-	chAddresses            chan models.BlockAddresses
-	wgBalances             sync.WaitGroup
-	logger                 *logrus.Entry
+	env               *models.ExtenderEnvironment
+	nodeApi           node.NodeClient
+	repository        Repository
+	addressRepository address.Repository
+	coinRepository    coin.Repository
+	broadcastService  *broadcast.Service
+	queueRepository   queue.Repository
+	jobUpdateBalance  chan AddressesBalancesContainer
+	chAddresses       chan models.BlockAddresses
+	chErrors          chan error
+	progressService   *progress.Service
+	logger            *logrus.Entry
+
+	stageMu         sync.Mutex
+	queuedHeight    uint64 // highest height queued by Run
+	completedHeight uint64 // last height recorded in the StageBalances cursor
 }
 
 type AddressesBalancesContainer struct {
 	Addresses         []string
 	Balances          []*models.Balance
-	nodeApi           *minter_node_go_api.MinterNodeApi
-	repository        *Repository
-	addressRepository *address.Repository
-	coinRepository    *coin.Repository
+	nodeApi           node.NodeClient
+	repository        Repository
+	addressRepository address.Repository
+	coinRepository    coin.Repository
 	chAddresses       chan models.BlockAddresses
 	broadcastService  *broadcast.Service
+	height            uint64
 }
 
-func NewService(env *models.ExtenderEnvironment, repository *Repository, nodeApi *minter_node_go_api.MinterNodeApi,
-	addressRepository *address.Repository, coinRepository *coin.Repository, broadcastService *broadcast.Service,
-	logger *logrus.Entry) *Service {
+func NewService(env *models.ExtenderEnvironment, repository Repository, nodeApi node.NodeClient,
+	addressRepository address.Repository, coinRepository coin.Repository, broadcastService *broadcast.Service,
+	progressService *progress.Service, queueRepository queue.Repository, logger *logrus.Entry) *Service {
 	return &Service{
-		env:                    env,
-		repository:             repository,
-		nodeApi:                nodeApi,
-		addressRepository:      addressRepository,
-		coinRepository:         coinRepository,
-		broadcastService:       broadcastService,
-		chAddresses:            make(chan models.BlockAddresses),
-		jobUpdateBalance:       make(chan AddressesBalancesContainer, env.WrkUpdateBalanceCount),
-		jobGetBalancesFromNode: make(chan models.BlockAddresses, env.WrkGetBalancesFromNodeCount),
-		logger:                 logger,
+		env:               env,
+		progressService:   progressService,
+		queueRepository:   queueRepository,
+		repository:        repository,
+		nodeApi:           nodeApi,
+		addressRepository: addressRepository,
+		coinRepository:    coinRepository,
+		broadcastService:  broadcastService,
+		chAddresses:       make(chan models.BlockAddresses),
+		chErrors:          make(chan error, 1),
+		jobUpdateBalance:  make(chan AddressesBalancesContainer, env.WrkUpdateBalanceCount),
+		logger:            logger,
 	}
 }
 
@@ -59,63 +77,133 @@ func (s *Service) GetAddressesChannel() chan<- models.BlockAddresses {
 	return s.chAddresses
 }
 
-func (s *Service) GetBalancesFromNodeChannel() chan models.BlockAddresses {
-	return s.jobGetBalancesFromNode
+// Receives an error when balances of a block could not be updated
+func (s *Service) GetErrorsChannel() <-chan error {
+	return s.chErrors
 }
 
 func (s *Service) GetUpdateBalancesJobChannel() chan AddressesBalancesContainer {
 	return s.jobUpdateBalance
 }
 
+// Queue addresses of blocks. The StageBalances cursor is completed when their balances are stored
 func (s *Service) Run() {
-	for {
-		addresses := <-s.chAddresses
-		s.HandleAddresses(addresses)
+	for addresses := range s.chAddresses {
+		err := s.HandleAddresses(addresses)
+		if err != nil {
+			s.logger.WithField("block", addresses.Height).Error(err)
+			s.reportError(fmt.Errorf("balances of block %d: %s", addresses.Height, err))
+			continue
+		}
+		s.stageMu.Lock()
+		if addresses.Height > s.queuedHeight {
+			s.queuedHeight = addresses.Height
+		}
+		s.stageMu.Unlock()
+		// Blocks without addresses are complete right away
+		s.completeStage()
 	}
 }
 
-func (s *Service) HandleAddresses(blockAddresses models.BlockAddresses) {
-	// Split addresses by chunks
-	chunksCount := int(math.Ceil(float64(len(blockAddresses.Addresses)) / float64(s.env.AddrChunkSize)))
-	s.wgBalances.Add(chunksCount)
-	for i := 0; i < chunksCount; i++ {
-		start := s.env.AddrChunkSize * i
-		end := start + s.env.AddrChunkSize
-		if end > len(blockAddresses.Addresses) {
-			end = len(blockAddresses.Addresses)
-		}
-		s.GetBalancesFromNodeChannel() <- models.BlockAddresses{Height: blockAddresses.Height, Addresses: blockAddresses.Addresses[start:end]}
-	}
-	s.wgBalances.Wait()
+// Queue the block addresses for the refresh. Addresses which are queued already are not duplicated
+func (s *Service) HandleAddresses(blockAddresses models.BlockAddresses) error {
+	return s.queueRepository.Enqueue(queue.KindBalance, blockAddresses.Addresses, blockAddresses.Height)
 }
 
-func (s *Service) GetBalancesFromNodeWorker(jobs <-chan models.BlockAddresses, result chan<- AddressesBalancesContainer) {
-	for blockAddresses := range jobs {
-		addresses := make([]string, len(blockAddresses.Addresses))
-		for i, adr := range blockAddresses.Addresses {
-			addresses[i] = `"Mx` + adr + `"`
-		}
-		response, err := s.nodeApi.GetAddresses(addresses, blockAddresses.Height)
+// Claim queued addresses by chunks and get their balances from the node until the context is done
+func (s *Service) GetBalancesFromNodeWorker(ctx context.Context, result chan<- AddressesBalancesContainer) {
+	for ctx.Err() == nil {
+		jobs, err := s.queueRepository.Claim(queue.KindBalance, s.env.AddrChunkSize, balanceJobLease)
 		if err != nil {
 			s.logger.Error(err)
+		}
+		if len(jobs) == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(queuePollInterval):
+			}
 			continue
 		}
-		balances, err := s.HandleBalanceResponse(response)
-		if err != nil {
-			s.logger.Error(err)
-		} else {
-			result <- AddressesBalancesContainer{Addresses: blockAddresses.Addresses, Balances: balances}
-			go s.broadcastService.PublishBalances(balances)
+
+		// Each address is requested at its own queued height, a replayed node has the state of the recorded heights only
+		byHeight := make(map[uint64][]string)
+		var heights []uint64
+		for _, job := range jobs {
+			if _, ok := byHeight[job.Height]; !ok {
+				heights = append(heights, job.Height)
+			}
+			byHeight[job.Height] = append(byHeight[job.Height], job.Key)
+		}
+		sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+		for _, height := range heights {
+			s.getBalancesFromNode(byHeight[height], height, result)
 		}
 	}
 }
 
+// Get balances of the addresses at the height and pass them to the update workers.
+// Failed jobs stay locked until the lease is over and are taken again
+func (s *Service) getBalancesFromNode(addresses []string, height uint64, result chan<- AddressesBalancesContainer) {
+	nodeAddresses := make([]string, len(addresses))
+	for i, a := range addresses {
+		nodeAddresses[i] = `"Mx` + a + `"`
+	}
+	response, err := s.nodeApi.GetAddresses(nodeAddresses, height)
+	if err != nil {
+		s.logger.WithField("block", height).Error(err)
+		return
+	}
+	balances, err := s.HandleBalanceResponse(response)
+	if err != nil {
+		s.logger.WithField("block", height).Error(err)
+		return
+	}
+	result <- AddressesBalancesContainer{Addresses: addresses, Balances: balances, height: height}
+	go s.broadcastService.PublishBalances(balances)
+}
+
 func (s *Service) UpdateBalancesWorker(jobs <-chan AddressesBalancesContainer) {
 	for container := range jobs {
 		err := s.updateBalances(container.Addresses, container.Balances)
 		if err != nil {
 			s.logger.Error(err)
+			continue
+		}
+		err = s.queueRepository.Complete(queue.KindBalance, container.Addresses, container.height)
+		if err != nil {
+			s.logger.Error(err)
+			continue
 		}
+		s.completeStage()
+	}
+}
+
+// Move the StageBalances cursor up to the height below the lowest queued job, but not above the heights
+// queued by Run. A key queued again keeps only its highest height, its balance is stored at that height
+func (s *Service) completeStage() {
+	s.stageMu.Lock()
+	defer s.stageMu.Unlock()
+	height := s.queuedHeight
+	pending, err := s.queueRepository.MinHeight(queue.KindBalance)
+	if err != nil {
+		s.logger.Error(err)
+		return
+	}
+	if pending > 0 && pending-1 < height {
+		height = pending - 1
+	}
+	if height <= s.completedHeight {
+		return
+	}
+	s.progressService.Complete(progress.StageBalances, height)
+	s.completedHeight = height
+}
+
+// Only the first not received error is kept, the extender stops on it anyway
+func (s *Service) reportError(err error) {
+	select {
+	case s.chErrors <- err:
+	default:
 	}
 }
 
@@ -150,31 +238,11 @@ func (s *Service) HandleBalanceResponse(response *responses.BalancesResponse) ([
 }
 
 func (s *Service) updateBalances(addresses []string, nodeBalances []*models.Balance) error {
-	defer s.wgBalances.Done()
-
-	dbBalances, err := s.repository.FindAllByAddress(addresses)
+	forCreate, forUpdate, forDelete, err := s.FindChanges(addresses, nodeBalances)
 	if err != nil {
 		s.logger.Error(err)
 		return err
 	}
-	//If no balances in DB save all
-	if dbBalances == nil {
-		return s.repository.SaveAll(nodeBalances)
-	}
-
-	mapAddressBalances := makeAddressBalanceMap(dbBalances)
-	var forCreate, forUpdate, forDelete []*models.Balance
-
-	for _, nodeBalance := range nodeBalances {
-		if mapAddressBalances[nodeBalance.AddressID][nodeBalance.CoinID] != nil {
-			mapAddressBalances[nodeBalance.AddressID][nodeBalance.CoinID].Value = nodeBalance.Value
-			forUpdate = append(forUpdate, mapAddressBalances[nodeBalance.AddressID][nodeBalance.CoinID])
-			delete(mapAddressBalances[nodeBalance.AddressID], nodeBalance.CoinID)
-		} else if nodeBalance.CoinID > 0 {
-			forCreate = append(forCreate, nodeBalance)
-			delete(mapAddressBalances[nodeBalance.AddressID], nodeBalance.CoinID)
-		}
-	}
 
 	if len(forCreate) > 0 {
 		err = s.repository.SaveAll(forCreate)
@@ -192,11 +260,6 @@ func (s *Service) updateBalances(addresses []string, nodeBalances []*models.Bala
 		}
 	}
 
-	for _, adr := range mapAddressBalances {
-		for _, blc := range adr {
-			forDelete = append(forDelete, blc)
-		}
-	}
 	if len(forDelete) > 0 {
 		err = s.repository.DeleteAll(forDelete)
 		if err != nil {
@@ -207,6 +270,39 @@ func (s *Service) updateBalances(addresses []string, nodeBalances []*models.Bala
 	return nil
 }
 
+// Compare balances from the node with balances of the addresses in DB.
+// Return balances which should be created, updated and deleted
+func (s *Service) FindChanges(addresses []string, nodeBalances []*models.Balance) (forCreate, forUpdate, forDelete []*models.Balance, err error) {
+	dbBalances, err := s.repository.FindAllByAddress(addresses)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	//If no balances in DB save all
+	if dbBalances == nil {
+		return nodeBalances, nil, nil, nil
+	}
+
+	mapAddressBalances := makeAddressBalanceMap(dbBalances)
+
+	for _, nodeBalance := range nodeBalances {
+		if mapAddressBalances[nodeBalance.AddressID][nodeBalance.CoinID] != nil {
+			mapAddressBalances[nodeBalance.AddressID][nodeBalance.CoinID].Value = nodeBalance.Value
+			forUpdate = append(forUpdate, mapAddressBalances[nodeBalance.AddressID][nodeBalance.CoinID])
+			delete(mapAddressBalances[nodeBalance.AddressID], nodeBalance.CoinID)
+		} else if nodeBalance.CoinID > 0 {
+			forCreate = append(forCreate, nodeBalance)
+			delete(mapAddressBalances[nodeBalance.AddressID], nodeBalance.CoinID)
+		}
+	}
+
+	for _, adr := range mapAddressBalances {
+		for _, blc := range adr {
+			forDelete = append(forDelete, blc)
+		}
+	}
+	return forCreate, forUpdate, forDelete, nil
+}
+
 func makeAddressBalanceMap(balances []*models.Balance) map[uint64]map[uint64]*models.Balance {
 	addrMap := make(map[uint64]map[uint64]*models.Balance)
 	for _, balance := range balances {
@@ -0,0 +1,134 @@
+package balance_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/balance"
+	"github.com/MinterTeam/minter-explorer-extender/broadcast"
+	"github.com/MinterTeam/minter-explorer-extender/memory"
+	"github.com/MinterTeam/minter-explorer-extender/node"
+	"github.com/MinterTeam/minter-explorer-extender/progress"
+	"github.com/MinterTeam/minter-explorer-extender/queue"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// Node which has the balances of the recorded heights only, as a replayed archive
+type recordedNode struct {
+	node.NodeClient
+	recorded map[uint64]string // address stored at the height
+}
+
+func (n *recordedNode) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	address, ok := n.recorded[height]
+	if !ok {
+		return nil, fmt.Errorf("balances of block %d are not recorded", height)
+	}
+	resp := new(responses.BalancesResponse)
+	for _, a := range addresses {
+		if a != `"Mx`+address+`"` {
+			return nil, fmt.Errorf("balance of %s is not recorded at block %d", a, height)
+		}
+		resp.Result = append(resp.Result, responses.Balance{Address: "Mx" + address, Balance: map[string]string{"MNT": "1"}})
+	}
+	return resp, nil
+}
+
+func TestGetBalancesFromNodeAtQueuedHeights(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	entry := logrus.NewEntry(logger)
+	env := &models.ExtenderEnvironment{AddrChunkSize: 10, WrkUpdateBalanceCount: 1}
+
+	store := memory.NewStore()
+	addressRepository := memory.NewAddressRepository(store)
+	coinRepository := memory.NewCoinRepository(store)
+	queueRepository := memory.NewQueueRepository(store)
+	if err := addressRepository.SaveAllIfNotExist([]string{"a1", "a2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := coinRepository.Save(&models.Coin{Symbol: "MNT"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := queueRepository.Enqueue(queue.KindBalance, []string{"a1"}, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := queueRepository.Enqueue(queue.KindBalance, []string{"a2"}, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	nodeApi := &recordedNode{recorded: map[uint64]string{5: "a1", 7: "a2"}}
+	s := balance.NewService(env, memory.NewBalanceRepository(store), nodeApi, addressRepository, coinRepository,
+		broadcast.NewService(env, addressRepository, coinRepository, entry), nil, queueRepository, entry)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := make(chan balance.AddressesBalancesContainer, 2)
+	go s.GetBalancesFromNodeWorker(ctx, result)
+
+	// Both jobs are claimed at once and each is requested at its own height
+	for _, want := range []string{"a1", "a2"} {
+		select {
+		case container := <-result:
+			if len(container.Addresses) != 1 || container.Addresses[0] != want || len(container.Balances) != 1 {
+				t.Errorf("balances = %v of %v, want one balance of %s", container.Balances, container.Addresses, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("balances of %s are not requested at their height", want)
+		}
+	}
+}
+
+func TestBalancesCursorCompletesAfterStore(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	entry := logrus.NewEntry(logger)
+	env := &models.ExtenderEnvironment{AddrChunkSize: 10, WrkUpdateBalanceCount: 1}
+
+	store := memory.NewStore()
+	addressRepository := memory.NewAddressRepository(store)
+	coinRepository := memory.NewCoinRepository(store)
+	if err := addressRepository.SaveAllIfNotExist([]string{"a1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := coinRepository.Save(&models.Coin{Symbol: "MNT"}); err != nil {
+		t.Fatal(err)
+	}
+	progressService := progress.NewService(memory.NewProgressRepository(store), entry)
+	s := balance.NewService(env, memory.NewBalanceRepository(store), &recordedNode{recorded: map[uint64]string{5: "a1"}},
+		addressRepository, coinRepository, broadcast.NewService(env, addressRepository, coinRepository, entry),
+		progressService, memory.NewQueueRepository(store), entry)
+	go s.Run()
+	defer close(s.GetAddressesChannel())
+
+	cursor := func() uint64 {
+		cursors, err := progressService.GetAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cursors[progress.StageBalances]
+	}
+	waitCursor := func(want uint64) {
+		deadline := time.Now().Add(5 * time.Second)
+		for cursor() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("balances cursor = %d, want %d", cursor(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Queued addresses of block 5 keep the cursor below it until their balances are stored
+	s.GetAddressesChannel() <- models.BlockAddresses{Height: 5, Addresses: []string{"a1"}}
+	waitCursor(4)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.GetBalancesFromNodeWorker(ctx, s.GetUpdateBalancesJobChannel())
+	go s.UpdateBalancesWorker(s.GetUpdateBalancesJobChannel())
+	waitCursor(5)
+}
@@ -0,0 +1,76 @@
+package barrier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Waits until every chunk of a block handled by workers is reported and collects their errors.
+// The first failed chunk cancels the context of the barrier, so the other chunks of the block can be skipped
+type Barrier struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	pending int
+	errs    []error
+	done    chan struct{}
+}
+
+func New(parent context.Context, chunks int) *Barrier {
+	ctx, cancel := context.WithCancel(parent)
+	b := &Barrier{
+		ctx:     ctx,
+		cancel:  cancel,
+		pending: chunks,
+		done:    make(chan struct{}),
+	}
+	if chunks <= 0 {
+		b.finish()
+	}
+	return b
+}
+
+// Context which is done when a chunk fails or the parent context is done
+func (b *Barrier) Context() context.Context {
+	return b.ctx
+}
+
+// Report the result of one chunk. Must be called exactly once for every chunk,
+// chunks skipped after cancellation report the error of the context
+func (b *Barrier) Done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending <= 0 {
+		return
+	}
+	if err != nil {
+		b.errs = append(b.errs, err)
+		b.cancel()
+	}
+	b.pending--
+	if b.pending == 0 {
+		b.finish()
+	}
+}
+
+// Block until all chunks are reported. Return the error of the first failed chunk
+func (b *Barrier) Wait() error {
+	<-b.done
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch len(b.errs) {
+	case 0:
+		return nil
+	case 1:
+		return b.errs[0]
+	default:
+		return fmt.Errorf("%d chunks failed, first error: %s", len(b.errs), b.errs[0])
+	}
+}
+
+func (b *Barrier) finish() {
+	close(b.done)
+	b.cancel()
+}
@@ -0,0 +1,96 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitReturnsAfterAllChunks(t *testing.T) {
+	b := New(context.Background(), 3)
+	for i := 0; i < 3; i++ {
+		go b.Done(nil)
+	}
+	if err := b.Wait(); err != nil {
+		t.Fatalf("Wait() = %v, want nil", err)
+	}
+}
+
+func TestWaitBlocksUntilLastChunk(t *testing.T) {
+	b := New(context.Background(), 2)
+	b.Done(nil)
+
+	waited := make(chan error)
+	go func() {
+		waited <- b.Wait()
+	}()
+	select {
+	case <-waited:
+		t.Fatal("Wait() returned before the last chunk is reported")
+	case <-time.After(10 * time.Millisecond):
+	}
+	b.Done(nil)
+	if err := <-waited; err != nil {
+		t.Fatalf("Wait() = %v, want nil", err)
+	}
+}
+
+func TestFailedChunkCancelsContext(t *testing.T) {
+	b := New(context.Background(), 3)
+	failure := errors.New("failure")
+	b.Done(failure)
+
+	select {
+	case <-b.Context().Done():
+	default:
+		t.Fatal("context is not cancelled after a failed chunk")
+	}
+	b.Done(b.Context().Err())
+	b.Done(nil)
+	err := b.Wait()
+	if err == nil || err == failure {
+		t.Fatalf("Wait() = %v, want the count of failed chunks", err)
+	}
+}
+
+func TestWaitReturnsErrorOfSingleFailedChunk(t *testing.T) {
+	b := New(context.Background(), 2)
+	failure := errors.New("failure")
+	b.Done(nil)
+	b.Done(failure)
+	if err := b.Wait(); err != failure {
+		t.Fatalf("Wait() = %v, want %v", err, failure)
+	}
+}
+
+func TestExtraReportsAreIgnored(t *testing.T) {
+	b := New(context.Background(), 1)
+	b.Done(nil)
+	b.Done(errors.New("failure"))
+	if err := b.Wait(); err != nil {
+		t.Fatalf("Wait() = %v, want nil", err)
+	}
+}
+
+func TestBarrierWithoutChunks(t *testing.T) {
+	b := New(context.Background(), 0)
+	if err := b.Wait(); err != nil {
+		t.Fatalf("Wait() = %v, want nil", err)
+	}
+}
+
+func TestParentCancellation(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	b := New(parent, 1)
+	cancel()
+	select {
+	case <-b.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("context is not done after the parent is cancelled")
+	}
+	b.Done(b.Context().Err())
+	if err := b.Wait(); err != context.Canceled {
+		t.Fatalf("Wait() = %v, want %v", err, context.Canceled)
+	}
+}
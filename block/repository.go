@@ -1,21 +1,46 @@
 package block
 
 import (
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/bulk"
 	"github.com/MinterTeam/minter-explorer-tools/models"
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 )
 
-type Repository struct {
-	db *pg.DB
+// Storage of blocks and of all data derived from them
+type Repository interface {
+	WithTx(tx *pg.Tx) Repository
+	Save(block *models.Block) error
+	GetLastFromDB() (*models.Block, error)
+	GetById(id uint64) (*models.Block, error)
+	LinkWithValidators(links []*models.BlockValidator) error
+	DeleteLastBlockData() error
+	DeleteAfterHeight(height uint64) ([]DeletedRows, error)
+	DeleteHeight(height uint64) error
 }
 
-func NewRepository(db *pg.DB) *Repository {
-	return &Repository{
-		db: db,
+type pgRepository struct {
+	db            orm.DB
+	copyThreshold int // batches of at least this size are loaded with COPY, never if negative
+}
+
+func NewRepository(db orm.DB, copyThreshold int) Repository {
+	return &pgRepository{
+		db:            db,
+		copyThreshold: copyThreshold,
+	}
+}
+
+// Return a copy of the repository which runs all queries inside the transaction
+func (r *pgRepository) WithTx(tx *pg.Tx) Repository {
+	return &pgRepository{
+		db:            tx,
+		copyThreshold: r.copyThreshold,
 	}
 }
 
-func (r *Repository) Save(block *models.Block) error {
+func (r *pgRepository) Save(block *models.Block) error {
 	_, err := r.db.Model(block).Insert()
 	if err != nil {
 		return err
@@ -23,7 +48,7 @@ func (r *Repository) Save(block *models.Block) error {
 	return nil
 }
 
-func (r *Repository) GetLastFromDB() (*models.Block, error) {
+func (r *pgRepository) GetLastFromDB() (*models.Block, error) {
 	block := new(models.Block)
 	err := r.db.Model(block).Last()
 	if err != nil {
@@ -32,7 +57,26 @@ func (r *Repository) GetLastFromDB() (*models.Block, error) {
 	return block, nil
 }
 
-func (r *Repository) LinkWithValidators(links []*models.BlockValidator) error {
+func (r *pgRepository) GetById(id uint64) (*models.Block, error) {
+	block := &models.Block{ID: id}
+	err := r.db.Select(block)
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (r *pgRepository) LinkWithValidators(links []*models.BlockValidator) error {
+	if bulk.Enabled(r.copyThreshold, len(links)) {
+		c := bulk.NewCopy("block_validator", "block_id", "validator_id", "signed")
+		for _, l := range links {
+			err := c.Add(l.BlockID, l.ValidatorID, l.Signed)
+			if err != nil {
+				return err
+			}
+		}
+		return c.Exec(r.db)
+	}
 	var args []interface{}
 	for _, l := range links {
 		args = append(args, l)
@@ -41,21 +85,100 @@ func (r *Repository) LinkWithValidators(links []*models.BlockValidator) error {
 	return err
 }
 
-func (r *Repository) DeleteLastBlockData() error {
-	tx, err := r.db.Begin()
+// Delete the last block with all data related to it.
+// Should be called on a repository bound to a transaction
+func (r *pgRepository) DeleteLastBlockData() error {
+	queries := []string{
+		`delete from transaction_outputs where block_id = (select id from blocks order by id desc limit 1);`,
+		`delete from transaction_validator where transaction_id IN (select distinct id from transactions where block_id = (select id from blocks order by id desc limit 1));`,
+		`delete from index_transaction_by_address where transaction_id in (select distinct id from transactions where block_id = (select id from blocks order by id desc limit 1));`,
+		`delete from invalid_transactions  where block_id = (select id from blocks order by id desc limit 1);`,
+		`delete from transactions where block_id = (select id from blocks order by id desc limit 1);`,
+		`delete from rewards where block_id = (select id from blocks order by id desc limit 1);`,
+		`delete from slashes where block_id = (select id from blocks order by id desc limit 1);`,
+		`delete from block_validator where block_id = (select id from blocks order by id desc limit 1);`,
+		`delete from blocks where id = (select id from blocks order by id desc limit 1);`,
+	}
+	for _, query := range queries {
+		if _, err := r.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count of rows deleted from a table
+type DeletedRows struct {
+	Table string
+	Count int
+}
+
+// Delete all blocks above the height with all data related to them.
+// Aggregated rewards which start above the height are deleted, ones which include it are summed again up to it.
+// Should be called on a repository bound to a transaction
+func (r *pgRepository) DeleteAfterHeight(height uint64) ([]DeletedRows, error) {
+	res, err := r.db.Exec(`delete from aggregated_rewards where from_block_id > ?;`, height)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// Rewards of a range belong to its time interval, so the kept ones are the rewards up to the height
+	_, err = r.db.Exec(`
+update aggregated_rewards a
+set amount      = s.amount,
+    to_block_id = s.to_block_id
+from (select a.time_id, a.address_id, a.validator_id, a.role, sum(r.amount) as amount, max(r.block_id) as to_block_id
+      from aggregated_rewards a
+               inner join rewards r on r.address_id = a.address_id
+          and r.validator_id = a.validator_id
+          and r.role = a.role
+          and r.block_id between a.from_block_id and ?
+      where a.to_block_id > ?
+      group by a.time_id, a.address_id, a.validator_id, a.role) s
+where a.time_id = s.time_id
+  and a.address_id = s.address_id
+  and a.validator_id = s.validator_id
+  and a.role = s.role;
+	`, height, height)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := r.deleteBlocks(">", height)
+	if err != nil {
+		return nil, err
+	}
+	return append(deleted, DeletedRows{Table: "aggregated_rewards", Count: res.RowsAffected()}), nil
+}
+
+// Delete the block with all data related to it.
+// Should be called on a repository bound to a transaction
+func (r *pgRepository) DeleteHeight(height uint64) error {
+	_, err := r.deleteBlocks("=", height)
+	return err
+}
+
+func (r *pgRepository) deleteBlocks(operator string, height uint64) ([]DeletedRows, error) {
+	queries := []struct {
+		table string
+		query string
+	}{
+		{"transaction_outputs", `delete from transaction_outputs where block_id %s ?;`},
+		{"transaction_validator", `delete from transaction_validator where transaction_id in (select id from transactions where block_id %s ?);`},
+		{"index_transaction_by_address", `delete from index_transaction_by_address where block_id %s ?;`},
+		{"invalid_transactions", `delete from invalid_transactions where block_id %s ?;`},
+		{"transactions", `delete from transactions where block_id %s ?;`},
+		{"rewards", `delete from rewards where block_id %s ?;`},
+		{"slashes", `delete from slashes where block_id %s ?;`},
+		{"block_validator", `delete from block_validator where block_id %s ?;`},
+		{"quarantine", `delete from quarantine where block_id %s ?;`},
+		{"blocks", `delete from blocks where id %s ?;`},
+	}
+	deleted := make([]DeletedRows, len(queries))
+	for i, q := range queries {
+		res, err := r.db.Exec(fmt.Sprintf(q.query, operator), height)
+		if err != nil {
+			return nil, err
+		}
+		deleted[i] = DeletedRows{Table: q.table, Count: res.RowsAffected()}
 	}
-	// Rollback tx on error.
-	defer tx.Rollback()
-	_, err = tx.Query(nil, `delete from transaction_outputs where transaction_id IN (select distinct id from transactions where block_id = (select id from blocks order by id desc limit 1));`)
-	_, err = tx.Query(nil, `delete from transaction_validator where transaction_id IN (select distinct id from transactions where block_id = (select id from blocks order by id desc limit 1));`)
-	_, err = tx.Query(nil, `delete from index_transaction_by_address where transaction_id in (select distinct id from transactions where block_id = (select id from blocks order by id desc limit 1));`)
-	_, err = tx.Query(nil, `delete from invalid_transactions  where block_id = (select id from blocks order by id desc limit 1);`)
-	_, err = tx.Query(nil, `delete from transactions where block_id = (select id from blocks order by id desc limit 1);`)
-	_, err = tx.Query(nil, `delete from rewards where block_id = (select id from blocks order by id desc limit 1);`)
-	_, err = tx.Query(nil, `delete from slashes where block_id = (select id from blocks order by id desc limit 1);`)
-	_, err = tx.Query(nil, `delete from block_validator where block_id = (select id from blocks order by id desc limit 1);`)
-	_, err = tx.Query(nil, `delete from blocks where id = (select id from blocks order by id desc limit 1);`)
-	return tx.Commit()
+	return deleted, nil
 }
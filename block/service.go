@@ -1,27 +1,25 @@
 package block
 
 import (
-	"github.com/MinterTeam/minter-explorer-extender/broadcast"
 	"github.com/MinterTeam/minter-explorer-extender/validator"
 	"github.com/MinterTeam/minter-explorer-tools/helpers"
 	"github.com/MinterTeam/minter-explorer-tools/models"
 	"github.com/MinterTeam/minter-node-go-api/responses"
+	"github.com/go-pg/pg"
 	"strconv"
 	"time"
 )
 
 type Service struct {
-	blockRepository     *Repository
-	validatorRepository *validator.Repository
-	broadcastService    *broadcast.Service
+	blockRepository     Repository
+	validatorRepository validator.Repository
 	blockCache          *models.Block //Contain previous block model
 }
 
-func NewBlockService(blockRepository *Repository, validatorRepository *validator.Repository, broadcastService *broadcast.Service) *Service {
+func NewBlockService(blockRepository Repository, validatorRepository validator.Repository) *Service {
 	return &Service{
 		blockRepository:     blockRepository,
 		validatorRepository: validatorRepository,
-		broadcastService:    broadcastService,
 	}
 }
 
@@ -33,21 +31,41 @@ func (s *Service) GetBlockCache() (b *models.Block) {
 	return s.blockCache
 }
 
-//Handle response and save block to DB
-func (s *Service) HandleBlockResponse(response *responses.BlockResponse) error {
+//Handle response and save block to DB inside the block transaction.
+//The block cache is not updated, it should be done after commit
+func (s *Service) HandleBlockResponse(tx *pg.Tx, response *responses.BlockResponse) (*models.Block, error) {
+	block, err := s.ParseBlockResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	return block, s.blockRepository.WithTx(tx).Save(block)
+}
+
+//Build the block model from the response without saving it
+func (s *Service) ParseBlockResponse(response *responses.BlockResponse) (*models.Block, error) {
 	height, err := strconv.ParseUint(response.Result.Height, 10, 64)
-	helpers.HandleError(err)
+	if err != nil {
+		return nil, err
+	}
 	totalTx, err := strconv.ParseUint(response.Result.TotalTx, 10, 64)
-	helpers.HandleError(err)
+	if err != nil {
+		return nil, err
+	}
 	numTx, err := strconv.ParseUint(response.Result.TxCount, 10, 32)
-	helpers.HandleError(err)
+	if err != nil {
+		return nil, err
+	}
 	size, err := strconv.ParseUint(response.Result.Size, 10, 64)
-	helpers.HandleError(err)
+	if err != nil {
+		return nil, err
+	}
 
 	var proposerId uint64
 	if response.Result.Proposer != "" {
 		proposerId, err = s.validatorRepository.FindIdByPk(helpers.RemovePrefix(response.Result.Proposer))
-		helpers.HandleError(err)
+		if err != nil {
+			return nil, err
+		}
 	} else {
 		proposerId = 1
 	}
@@ -63,11 +81,8 @@ func (s *Service) HandleBlockResponse(response *responses.BlockResponse) error {
 		ProposerValidatorID: proposerId,
 		Hash:                response.Result.Hash,
 	}
-	s.SetBlockCache(block)
-
-	go s.broadcastService.PublishBlock(block)
 
-	return s.blockRepository.Save(block)
+	return block, nil
 }
 
 func (s *Service) getBlockTime(blockTime time.Time) uint64 {
@@ -8,7 +8,6 @@ import (
 	"github.com/MinterTeam/minter-explorer-api/transaction"
 	"github.com/MinterTeam/minter-explorer-extender/address"
 	"github.com/MinterTeam/minter-explorer-extender/coin"
-	"github.com/MinterTeam/minter-explorer-tools/helpers"
 	"github.com/MinterTeam/minter-explorer-tools/models"
 	"github.com/centrifugal/gocent"
 	"github.com/sirupsen/logrus"
@@ -18,12 +17,12 @@ import (
 type Service struct {
 	client            *gocent.Client
 	ctx               context.Context
-	addressRepository *address.Repository
-	coinRepository    *coin.Repository
+	addressRepository address.Repository
+	coinRepository    coin.Repository
 	logger            *logrus.Entry
 }
 
-func NewService(env *models.ExtenderEnvironment, addressRepository *address.Repository, coinRepository *coin.Repository,
+func NewService(env *models.ExtenderEnvironment, addressRepository address.Repository, coinRepository coin.Repository,
 	logger *logrus.Entry) *Service {
 	wsClient := gocent.New(gocent.Config{
 		Addr: env.WsLink,
@@ -72,7 +71,10 @@ func (s *Service) PublishBalances(balances []*models.Balance) {
 			continue
 		}
 		adr, err := s.addressRepository.FindById(item.AddressID)
-		helpers.HandleError(err)
+		if err != nil {
+			s.logger.Error(err)
+			continue
+		}
 		mBalance := *item
 		mBalance.Address = &models.Address{Address: adr}
 		mBalance.Coin = &models.Coin{Symbol: symbol}
@@ -82,7 +84,10 @@ func (s *Service) PublishBalances(balances []*models.Balance) {
 
 	for addressId, items := range mapBalances {
 		adr, err := s.addressRepository.FindById(addressId)
-		helpers.HandleError(err)
+		if err != nil {
+			s.logger.Error(err)
+			continue
+		}
 		channel := "Mx" + adr
 		msg, err := json.Marshal(items)
 		if err != nil {
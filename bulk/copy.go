@@ -0,0 +1,132 @@
+package bulk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/go-pg/pg/orm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rows loaded into a table with COPY FROM STDIN in the text format
+type Copy struct {
+	table   string
+	columns []string
+	buf     bytes.Buffer
+	rows    int
+}
+
+func NewCopy(table string, columns ...string) *Copy {
+	return &Copy{
+		table:   table,
+		columns: columns,
+	}
+}
+
+// Use COPY instead of a multi-row INSERT for batches of at least threshold rows. Never if threshold is negative
+func Enabled(threshold int, rows int) bool {
+	return threshold >= 0 && rows >= threshold
+}
+
+// Size of the chunks a batch of rows is saved in. A batch loaded with COPY is saved at once,
+// since its chunks would be smaller than the threshold
+func ChunkSize(threshold int, rows int, chunkSize int) int {
+	if rows > 0 && Enabled(threshold, rows) {
+		return rows
+	}
+	return chunkSize
+}
+
+// Append a row with a value for each column. A nil value is written as NULL
+func (c *Copy) Add(values ...interface{}) error {
+	if len(values) != len(c.columns) {
+		return fmt.Errorf("copy into %s: %d values for %d columns", c.table, len(values), len(c.columns))
+	}
+	for i, value := range values {
+		if i > 0 {
+			c.buf.WriteByte('\t')
+		}
+		err := c.writeValue(value)
+		if err != nil {
+			return fmt.Errorf("copy into %s, column %s: %s", c.table, c.columns[i], err)
+		}
+	}
+	c.buf.WriteByte('\n')
+	c.rows++
+	return nil
+}
+
+func (c *Copy) Len() int {
+	return c.rows
+}
+
+// Load all added rows. Runs inside the transaction if db is a *pg.Tx
+func (c *Copy) Exec(db orm.DB) error {
+	if c.rows == 0 {
+		return nil
+	}
+	query := fmt.Sprintf("COPY %s (%s) FROM STDIN", c.table, strings.Join(c.columns, ", "))
+	res, err := db.CopyFrom(&c.buf, query)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() != c.rows {
+		return fmt.Errorf("copy into %s: %d rows loaded of %d", c.table, res.RowsAffected(), c.rows)
+	}
+	return nil
+}
+
+func (c *Copy) writeValue(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		c.buf.WriteString(`\N`)
+	case string:
+		c.writeText(v)
+	case bool:
+		if v {
+			c.buf.WriteByte('t')
+		} else {
+			c.buf.WriteByte('f')
+		}
+	case int:
+		c.buf.WriteString(strconv.FormatInt(int64(v), 10))
+	case int64:
+		c.buf.WriteString(strconv.FormatInt(v, 10))
+	case uint8:
+		c.buf.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint64:
+		c.buf.WriteString(strconv.FormatUint(v, 10))
+	case time.Time:
+		c.buf.WriteString(v.Format(time.RFC3339Nano))
+	case json.RawMessage:
+		c.writeText(string(v))
+	case []byte:
+		// bytea hex format, its backslash is escaped by the text format
+		c.buf.WriteString(`\\x`)
+		c.buf.WriteString(hex.EncodeToString(v))
+	default:
+		return fmt.Errorf("unsupported type %T", value)
+	}
+	return nil
+}
+
+// Escape the characters which have a meaning in the text format
+func (c *Copy) writeText(s string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			c.buf.WriteString(`\\`)
+		case '\t':
+			c.buf.WriteString(`\t`)
+		case '\n':
+			c.buf.WriteString(`\n`)
+		case '\r':
+			c.buf.WriteString(`\r`)
+		default:
+			c.buf.WriteByte(s[i])
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package bulk
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAddEscapesText(t *testing.T) {
+	c := NewCopy("test", "a", "b")
+	err := c.Add("tab\there\\back\nline\rreturn", json.RawMessage(`{"a":"b\n"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `tab\there\\back\nline\rreturn` + "\t" + `{"a":"b\\n"}` + "\n"
+	if got := c.buf.String(); got != want {
+		t.Errorf("row = %q, want %q", got, want)
+	}
+}
+
+func TestAddWritesValues(t *testing.T) {
+	c := NewCopy("test", "null", "bool", "int", "uint8", "uint64", "time", "bytes")
+	at := time.Date(2019, 6, 1, 12, 0, 0, 5, time.UTC)
+	err := c.Add(nil, true, -1, uint8(2), uint64(3), at, []byte{0xca, 0xfe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\\N\tt\t-1\t2\t3\t2019-06-01T12:00:00.000000005Z\t\\\\xcafe\n"
+	if got := c.buf.String(); got != want {
+		t.Errorf("row = %q, want %q", got, want)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", c.Len())
+	}
+}
+
+func TestAddRejectsInvalidRows(t *testing.T) {
+	c := NewCopy("test", "a")
+	if err := c.Add(1, 2); err == nil {
+		t.Error("Add() of more values than columns succeeded")
+	}
+	if err := c.Add(1.5); err == nil {
+		t.Error("Add() of an unsupported type succeeded")
+	}
+}
+
+func TestChunkSize(t *testing.T) {
+	tests := []struct {
+		threshold, rows, chunkSize, want int
+	}{
+		{500, 650, 100, 650},
+		{500, 500, 100, 500},
+		{500, 250, 100, 100},
+		{-1, 650, 100, 100},
+		{0, 0, 100, 100},
+	}
+	for _, test := range tests {
+		got := ChunkSize(test.threshold, test.rows, test.chunkSize)
+		if got != test.want {
+			t.Errorf("ChunkSize(%d, %d, %d) = %d, want %d", test.threshold, test.rows, test.chunkSize, got, test.want)
+		}
+	}
+}
@@ -3,25 +3,47 @@ package coin
 import (
 	"github.com/MinterTeam/minter-explorer-tools/models"
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"sync"
 )
 
-type Repository struct {
-	db       *pg.DB
+// Storage of coins. Ids of known symbols are cached
+type Repository interface {
+	WithTx(tx *pg.Tx) Repository
+	FindIdBySymbol(symbol string) (uint64, error)
+	CachePlaceholder(symbol string, id uint64)
+	FindSymbolById(id uint64) (string, error)
+	Save(c *models.Coin) error
+	SaveAllIfNotExist(coins []*models.Coin) error
+	GetAllCoins() ([]*models.Coin, error)
+	DeleteBySymbol(symbol string) error
+}
+
+type pgRepository struct {
+	db       orm.DB
 	cache    *sync.Map
 	invCache *sync.Map
 }
 
-func NewRepository(db *pg.DB) *Repository {
-	return &Repository{
+func NewRepository(db orm.DB) Repository {
+	return &pgRepository{
 		db:       db,
 		cache:    new(sync.Map), //TODO: добавить реализацию очистки
 		invCache: new(sync.Map), //TODO: добавить реализацию очистки
 	}
 }
 
+// Return a copy of the repository which runs all queries inside the transaction. The cache is shared
+func (r *pgRepository) WithTx(tx *pg.Tx) Repository {
+	return &pgRepository{
+		db:       tx,
+		cache:    r.cache,
+		invCache: r.invCache,
+	}
+}
+
 // Find coin id by symbol
-func (r *Repository) FindIdBySymbol(symbol string) (uint64, error) {
+func (r *pgRepository) FindIdBySymbol(symbol string) (uint64, error) {
 	//First look in the cache
 	id, ok := r.cache.Load(symbol)
 	if ok {
@@ -40,7 +62,12 @@ func (r *Repository) FindIdBySymbol(symbol string) (uint64, error) {
 	return coin.ID, nil
 }
 
-func (r *Repository) FindSymbolById(id uint64) (string, error) {
+// Store the id of the coin which would be created to the cache only, used by the dry run
+func (r *pgRepository) CachePlaceholder(symbol string, id uint64) {
+	r.cache.Store(symbol, id)
+}
+
+func (r *pgRepository) FindSymbolById(id uint64) (string, error) {
 	//First look in the cache
 	symbol, ok := r.invCache.Load(id)
 	if ok {
@@ -60,7 +87,7 @@ func (r *Repository) FindSymbolById(id uint64) (string, error) {
 	return coin.Symbol, nil
 }
 
-func (r *Repository) Save(c *models.Coin) error {
+func (r *pgRepository) Save(c *models.Coin) error {
 	_, err := r.db.Model(c).
 		Where("symbol = ?symbol").
 		OnConflict("DO NOTHING"). //TODO: change to DO UPDATE
@@ -72,7 +99,7 @@ func (r *Repository) Save(c *models.Coin) error {
 	return nil
 }
 
-func (r Repository) SaveAllIfNotExist(coins []*models.Coin) error {
+func (r pgRepository) SaveAllIfNotExist(coins []*models.Coin) error {
 	_, err := r.db.Model(&coins).OnConflict("(symbol) DO UPDATE").Insert()
 	if err != nil {
 		return err
@@ -84,14 +111,20 @@ func (r Repository) SaveAllIfNotExist(coins []*models.Coin) error {
 	return err
 }
 
-func (r *Repository) GetAllCoins() ([]*models.Coin, error) {
+func (r *pgRepository) GetAllCoins() ([]*models.Coin, error) {
 	var coins []*models.Coin
 	err := r.db.Model(&coins).Order("symbol ASC").Select()
 	return coins, err
 }
 
-func (r Repository) DeleteBySymbol(symbol string) error {
+func (r pgRepository) DeleteBySymbol(symbol string) error {
 	coin := &models.Coin{Symbol: symbol}
-	_, err := r.db.Model(coin).Where("symbol = ?symbol").Delete()
-	return err
+	_, err := r.db.Model(coin).Where("symbol = ?symbol").Returning("id").Delete()
+	if err != nil {
+		return err
+	}
+	// A symbol created again gets a new id. If the transaction is rolled back, the id is loaded again
+	r.cache.Delete(symbol)
+	r.invCache.Delete(coin.ID)
+	return nil
 }
@@ -2,10 +2,12 @@ package coin
 
 import (
 	"errors"
+	"fmt"
 	"github.com/MinterTeam/minter-explorer-extender/address"
+	"github.com/MinterTeam/minter-explorer-extender/node"
+	"github.com/MinterTeam/minter-explorer-extender/quarantine"
 	"github.com/MinterTeam/minter-explorer-tools/helpers"
 	"github.com/MinterTeam/minter-explorer-tools/models"
-	"github.com/MinterTeam/minter-node-go-api"
 	"github.com/MinterTeam/minter-node-go-api/responses"
 	"github.com/sirupsen/logrus"
 	"strconv"
@@ -14,16 +16,16 @@ import (
 
 type Service struct {
 	env                   *models.ExtenderEnvironment
-	nodeApi               *minter_node_go_api.MinterNodeApi
-	repository            *Repository
-	addressRepository     *address.Repository
+	nodeApi               node.NodeClient
+	repository            Repository
+	addressRepository     address.Repository
 	logger                *logrus.Entry
 	jobUpdateCoins        chan []*models.Transaction
 	jobUpdateCoinsFromMap chan map[string]struct{}
 }
 
-func NewService(env *models.ExtenderEnvironment, nodeApi *minter_node_go_api.MinterNodeApi, repository *Repository,
-	addressRepository *address.Repository, logger *logrus.Entry) *Service {
+func NewService(env *models.ExtenderEnvironment, nodeApi node.NodeClient, repository Repository,
+	addressRepository address.Repository, logger *logrus.Entry) *Service {
 	return &Service{
 		env:                   env,
 		nodeApi:               nodeApi,
@@ -51,14 +53,15 @@ func (s *Service) GetUpdateCoinsFromCoinsMapJobChannel() chan map[string]struct{
 	return s.jobUpdateCoinsFromMap
 }
 
+// Transactions which fail to parse are skipped, they are quarantined when the block is saved
 func (s Service) ExtractCoinsFromTransactions(transactions []responses.Transaction) ([]*models.Coin, error) {
 	var coins []*models.Coin
 	for _, tx := range transactions {
 		if tx.Type == models.TxTypeCreateCoin {
 			coin, err := s.ExtractFromTx(tx)
 			if err != nil {
-				s.logger.Error(err)
-				return nil, err
+				s.logger.WithField("Tx", tx.Hash).Error(err)
+				continue
 			}
 			coins = append(coins, coin)
 		}
@@ -66,12 +69,17 @@ func (s Service) ExtractCoinsFromTransactions(transactions []responses.Transacti
 	return coins, nil
 }
 
-func (s *Service) ExtractFromTx(tx responses.Transaction) (*models.Coin, error) {
+func (s *Service) ExtractFromTx(tx responses.Transaction) (coin *models.Coin, err error) {
+	defer quarantine.Recover(&err)
+
 	if tx.Data == nil {
 		s.logger.Warn("empty transaction data")
 		return nil, errors.New("no data for creating a coin")
 	}
-	txData := tx.IData.(models.CreateCoinTxData)
+	txData, ok := tx.IData.(models.CreateCoinTxData)
+	if !ok {
+		return nil, fmt.Errorf("unexpected data %T of transaction type %d", tx.IData, tx.Type)
+	}
 
 	crr, err := strconv.ParseUint(txData.ConstantReserveRatio, 10, 64)
 	if err != nil {
@@ -79,7 +87,7 @@ func (s *Service) ExtractFromTx(tx responses.Transaction) (*models.Coin, error)
 		return nil, err
 	}
 
-	coin := &models.Coin{
+	coin = &models.Coin{
 		Crr:            crr,
 		Volume:         txData.InitialAmount,
 		ReserveBalance: txData.InitialReserve,
@@ -106,6 +114,18 @@ func (s *Service) CreateNewCoins(coins []*models.Coin) error {
 	return err
 }
 
+// Save coins which do not exist yet, existing coins are left as is
+func (s *Service) CreateMissingCoins(coins []*models.Coin) error {
+	for _, coin := range coins {
+		err := s.repository.Save(coin)
+		if err != nil {
+			s.logger.Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Service) UpdateCoinsInfoFromTxsWorker(jobs <-chan []*models.Transaction) {
 	for transactions := range jobs {
 		coinsMap := make(map[string]struct{})
@@ -117,22 +137,33 @@ func (s *Service) UpdateCoinsInfoFromTxsWorker(jobs <-chan []*models.Transaction
 				continue
 			}
 			coinsMap[symbol] = struct{}{}
-			switch tx.Type {
-			case models.TxTypeSellCoin:
-				coinsMap[tx.IData.(models.SellCoinTxData).CoinToBuy] = struct{}{}
-				coinsMap[tx.IData.(models.SellCoinTxData).CoinToSell] = struct{}{}
-			case models.TxTypeBuyCoin:
-				coinsMap[tx.IData.(models.BuyCoinTxData).CoinToBuy] = struct{}{}
-				coinsMap[tx.IData.(models.BuyCoinTxData).CoinToSell] = struct{}{}
-			case models.TxTypeSellAllCoin:
-				coinsMap[tx.IData.(models.SellAllCoinTxData).CoinToBuy] = struct{}{}
-				coinsMap[tx.IData.(models.SellAllCoinTxData).CoinToSell] = struct{}{}
+			for _, symbol := range tradedCoins(tx.Type, tx.IData) {
+				coinsMap[symbol] = struct{}{}
 			}
 		}
 		s.GetUpdateCoinsFromCoinsMapJobChannel() <- coinsMap
 	}
 }
 
+// Coins bought and sold by the transaction. Data which does not match the type has no coins
+func tradedCoins(txType uint8, data interface{}) []string {
+	switch txType {
+	case models.TxTypeSellCoin:
+		if d, ok := data.(models.SellCoinTxData); ok {
+			return []string{d.CoinToBuy, d.CoinToSell}
+		}
+	case models.TxTypeBuyCoin:
+		if d, ok := data.(models.BuyCoinTxData); ok {
+			return []string{d.CoinToBuy, d.CoinToSell}
+		}
+	case models.TxTypeSellAllCoin:
+		if d, ok := data.(models.SellAllCoinTxData); ok {
+			return []string{d.CoinToBuy, d.CoinToSell}
+		}
+	}
+	return nil
+}
+
 func (s Service) UpdateCoinsInfoFromCoinsMap(job <-chan map[string]struct{}) {
 	for coinsMap := range job {
 		delete(coinsMap, s.env.BaseCoin)
@@ -170,6 +201,19 @@ func (s *Service) UpdateCoinsInfo(symbols []string) error {
 	return nil
 }
 
+// Refresh info of all stored coins from the node
+func (s *Service) UpdateAllCoinsInfo() error {
+	coins, err := s.repository.GetAllCoins()
+	if err != nil {
+		return err
+	}
+	symbols := make([]string, len(coins))
+	for i, coin := range coins {
+		symbols[i] = coin.Symbol
+	}
+	return s.UpdateCoinsInfo(symbols)
+}
+
 func (s *Service) GetCoinFromNode(symbol string) (*models.Coin, error) {
 	coinResp, err := s.nodeApi.GetCoinInfo(symbol)
 	if err != nil {
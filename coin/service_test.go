@@ -0,0 +1,40 @@
+package coin
+
+import (
+	"encoding/json"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestExtractCoinsSkipsMalformedTransactions(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	s := Service{logger: logrus.NewEntry(logger)}
+	data := json.RawMessage(`{}`)
+
+	coins, err := s.ExtractCoinsFromTransactions([]responses.Transaction{
+		{Type: models.TxTypeCreateCoin, Data: data, IData: models.SendTxData{}},
+		{Type: models.TxTypeCreateCoin, Data: data, IData: models.CreateCoinTxData{ConstantReserveRatio: "x"}},
+		{Type: models.TxTypeCreateCoin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coins) != 0 {
+		t.Errorf("coins = %v, want none", coins)
+	}
+}
+
+func TestTradedCoins(t *testing.T) {
+	got := tradedCoins(models.TxTypeSellCoin, models.SellCoinTxData{CoinToBuy: "A", CoinToSell: "B"})
+	if !reflect.DeepEqual(got, []string{"A", "B"}) {
+		t.Errorf("tradedCoins() = %v, want [A B]", got)
+	}
+	if got = tradedCoins(models.TxTypeBuyCoin, models.SellCoinTxData{}); got != nil {
+		t.Errorf("tradedCoins() of mismatched data = %v, want nil", got)
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"time"
+)
+
+// Index the closed range of heights with the same services as the live tail.
+// Every height is replaced in one transaction, so the range may contain holes as well as already indexed blocks.
+// The range must be below the indexed head; head state (balances, validators, stakes, coins info) is not touched
+// and nothing is published to the WebSocket server
+func (ext *Extender) Backfill(ctx context.Context, from, to uint64) error {
+	if from == 0 || from > to {
+		return fmt.Errorf("invalid backfill range %d-%d", from, to)
+	}
+	if err := ext.checkSchema(); err != nil {
+		return err
+	}
+	head, err := ext.blockRepository.GetLastFromDB()
+	if err != nil || head == nil {
+		return errors.New("backfill requires indexed blocks, run the extender first")
+	}
+	if to > head.ID {
+		return fmt.Errorf("backfill range %d-%d is above the indexed head %d", from, to, head.ID)
+	}
+	err = ext.partitionService.CheckAttached(from)
+	if err != nil {
+		return err
+	}
+
+	err = ext.setBackfillBlockCache(from)
+	if err != nil {
+		return err
+	}
+
+	// Only addresses are saved by workers, other stages update head state
+	startWorkers(&ext.wgStages[0], ext.env.WrkSaveAddressesCount, func() {
+		ext.addressService.SaveAddressesWorker(ext.addressService.GetSaveAddressesJobChannel())
+	})
+	defer func() {
+		close(ext.addressService.GetSaveAddressesJobChannel())
+		ext.wgStages[0].Wait()
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	height := from
+	for data := range ext.prefetchBlocks(ctx, from, to, false) {
+		if data.err != nil {
+			return data.err
+		}
+		if data.blockResponse.Error != nil {
+			return fmt.Errorf("unable to get block %d from node: %s", data.height, data.blockResponse.Error.Message)
+		}
+
+		coins, err := ext.coinService.ExtractCoinsFromTransactions(data.blockResponse.Result.Transactions)
+		if err != nil {
+			return err
+		}
+		err = ext.coinService.CreateMissingCoins(coins)
+		if err != nil {
+			return err
+		}
+		_, _, err = ext.addressService.SaveFromResponses(data.blockResponse, data.eventsResponse)
+		if err != nil {
+			return err
+		}
+		_, _, _, err = ext.saveBlock(ctx, data.blockResponse, data.eventsResponse, true)
+		if err != nil {
+			return err
+		}
+
+		ext.logger.WithField("block", data.height).Info("Block backfilled")
+		height = data.height + 1
+	}
+
+	if height <= to {
+		ext.logger.Warnf("Backfill interrupted, heights %d-%d are indexed", from, height-1)
+		return nil
+	}
+	ext.logger.Warnf("Backfill of heights %d-%d finished in %s", from, to, time.Since(start))
+	return nil
+}
+
+// Block time is calculated from the previous block, so it should be in the cache before the first height
+func (ext *Extender) setBackfillBlockCache(height uint64) error {
+	if height == 1 {
+		ext.blockService.SetBlockCache(nil)
+		return nil
+	}
+	previous, err := ext.blockRepository.GetById(height - 1)
+	if err == nil {
+		ext.blockService.SetBlockCache(previous)
+		return nil
+	}
+
+	response, err := ext.nodeApi.GetBlock(height - 1)
+	if err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return fmt.Errorf("unable to get block %d from node: %s", height-1, response.Error.Message)
+	}
+	ext.blockService.SetBlockCache(&models.Block{ID: height - 1, CreatedAt: response.Result.Time})
+	return nil
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/env"
+	"github.com/go-pg/pg"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values of database.sslMode, with the same meaning as the sslmode of libpq
+const (
+	SslModeDisable    = "disable"
+	SslModeRequire    = "require"     // encrypt, verify the server certificate only if sslRootCert is set
+	SslModeVerifyCa   = "verify-ca"   // encrypt and verify that the server certificate is signed by sslRootCert
+	SslModeVerifyFull = "verify-full" // as verify-ca, and the certificate must match the host
+)
+
+// Connection options of the database settings. A host starting with a slash is a directory of the Unix socket
+func pgOptions(env *env.Environment) (*pg.Options, error) {
+	options := &pg.Options{
+		User:            env.DbUser,
+		Password:        env.DbPassword,
+		Database:        env.DbName,
+		ApplicationName: env.AppName,
+		PoolSize:        env.DbPoolSize,
+		MinIdleConns:    env.DbMinIdleConns,
+		DialTimeout:     time.Duration(env.DbDialTimeoutMs) * time.Millisecond,
+	}
+
+	port := strconv.Itoa(env.DbPort)
+	if strings.HasPrefix(env.DbHost, "/") {
+		options.Network = "unix"
+		options.Addr = fmt.Sprintf("%s/.s.PGSQL.%s", strings.TrimSuffix(env.DbHost, "/"), port)
+	} else {
+		options.Network = "tcp"
+		options.Addr = net.JoinHostPort(env.DbHost, port)
+	}
+
+	tlsConfig, err := pgTlsConfig(env)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil && options.Network == "unix" {
+		return nil, errors.New("database.sslMode requires a TCP host")
+	}
+	options.TLSConfig = tlsConfig
+
+	if env.DbStatementTimeoutMs > 0 {
+		// Applied to every new connection of the pool, the server cancels longer statements
+		options.OnConnect = func(conn *pg.Conn) error {
+			_, err := conn.Exec("SET statement_timeout = ?", env.DbStatementTimeoutMs)
+			return err
+		}
+	}
+	return options, nil
+}
+
+// TLS settings of the sslMode, nil if the connection is not encrypted
+func pgTlsConfig(env *env.Environment) (*tls.Config, error) {
+	mode := env.DbSslMode
+	if mode == SslModeDisable {
+		return nil, nil
+	}
+	if mode != SslModeRequire && mode != SslModeVerifyCa && mode != SslModeVerifyFull {
+		return nil, fmt.Errorf("unknown database.sslMode %q, expected disable, require, verify-ca or verify-full", mode)
+	}
+
+	config := &tls.Config{ServerName: env.DbHost}
+	if env.DbSslCert != "" || env.DbSslKey != "" {
+		cert, err := tls.LoadX509KeyPair(env.DbSslCert, env.DbSslKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the database client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if env.DbSslRootCert == "" {
+		if mode != SslModeRequire {
+			return nil, fmt.Errorf("database.sslMode %s requires database.sslRootCert", mode)
+		}
+		config.InsecureSkipVerify = true
+		return config, nil
+	}
+	pem, err := ioutil.ReadFile(env.DbSslRootCert)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the database root certificate: %s", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", env.DbSslRootCert)
+	}
+	config.RootCAs = roots
+	if mode == SslModeVerifyFull {
+		return config, nil
+	}
+
+	// Like libpq, require and verify-ca check the chain of the certificate but not its host name
+	config.InsecureSkipVerify = true
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+		if len(certs) == 0 {
+			return errors.New("the database server has no certificate")
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+	return config, nil
+}
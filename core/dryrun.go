@@ -0,0 +1,251 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/quarantine"
+	"github.com/MinterTeam/minter-explorer-extender/transaction"
+	"github.com/MinterTeam/minter-explorer-tools/helpers"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"github.com/go-pg/pg"
+	"github.com/sirupsen/logrus"
+	"math"
+	"sort"
+)
+
+// Rows which would be written by the checked blocks, by table
+type dryRunReport struct {
+	inserts  map[string]int
+	updates  map[string]int
+	deletes  map[string]int
+	failures int
+	nextId   uint64 // placeholder ids of rows which would be created, counted down from MaxInt64
+}
+
+func newDryRunReport() *dryRunReport {
+	return &dryRunReport{
+		inserts: make(map[string]int),
+		updates: make(map[string]int),
+		deletes: make(map[string]int),
+		nextId:  math.MaxInt64,
+	}
+}
+
+func (r *dryRunReport) placeholderId() uint64 {
+	r.nextId--
+	return r.nextId
+}
+
+func (r *dryRunReport) fields() logrus.Fields {
+	fields := logrus.Fields{"failures": r.failures}
+	for prefix, counts := range map[string]map[string]int{"insert": r.inserts, "update": r.updates, "delete": r.deletes} {
+		for table, count := range counts {
+			fields[prefix+"."+table] = count
+		}
+	}
+	return fields
+}
+
+// Fetch blocks after the indexed head and run parsing and derivation of all services on them.
+// Nothing is written to the database or published to the WebSocket server, rows which would be
+// inserted, updated or deleted are counted and items which fail to parse are logged.
+// Rows which would be created are cached with placeholder ids, so the process must not index blocks afterwards
+func (ext *Extender) DryRun(ctx context.Context) error {
+	from := uint64(1)
+	head, _ := ext.blockRepository.GetLastFromDB()
+	if head != nil {
+		from = head.ID + 1
+		ext.blockService.SetBlockCache(head)
+	}
+	nodeHeight, err := ext.getNodeLastBlockId()
+	if err != nil {
+		return err
+	}
+	to := from + uint64(ext.env.DryRunBlocks) - 1
+	if to > nodeHeight {
+		to = nodeHeight
+	}
+	if ext.env.DryRunBlocks <= 0 || from > to {
+		return fmt.Errorf("there are no blocks to check after height %d", from-1)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	report := newDryRunReport()
+	checked := from
+	for data := range ext.prefetchBlocks(ctx, from, to, false) {
+		if data.err != nil {
+			return data.err
+		}
+		if data.blockResponse.Error != nil {
+			return fmt.Errorf("unable to get block %d from node: %s", data.height, data.blockResponse.Error.Message)
+		}
+		err = ext.dryRunBlock(report, data.height, data.blockResponse, data.eventsResponse)
+		if err != nil {
+			return err
+		}
+		checked = data.height + 1
+	}
+
+	fields := report.fields()
+	fields["from"] = from
+	fields["to"] = checked - 1
+	ext.logger.WithFields(fields).Warn("Dry run finished")
+	if report.failures > 0 {
+		return fmt.Errorf("dry run found %d items which failed to parse", report.failures)
+	}
+	return nil
+}
+
+// Errors are returned only if the database or the node can not be reached, parsing failures are counted
+func (ext *Extender) dryRunBlock(report *dryRunReport, height uint64, blockResponse *responses.BlockResponse,
+	eventsResponse *responses.EventsResponse) error {
+
+	logger := ext.logger.WithField("block", height)
+	failures := report.failures
+	fail := func(stage string, err error) {
+		report.failures++
+		logger.WithField("stage", stage).Error(err)
+	}
+
+	// Coins, validators and addresses are created before the block is saved
+	err := dryRunStep(func() error {
+		coins, err := ext.coinService.ExtractCoinsFromTransactions(blockResponse.Result.Transactions)
+		if err != nil {
+			return err
+		}
+		for _, coin := range coins {
+			_, err = ext.coinRepository.FindIdBySymbol(coin.Symbol)
+			if err == pg.ErrNoRows {
+				report.inserts["coins"]++
+				ext.coinRepository.CachePlaceholder(coin.Symbol, report.placeholderId())
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fail("coins", err)
+	}
+
+	var validatorKeys []string
+	for _, v := range blockResponse.Result.Validators {
+		validatorKeys = append(validatorKeys, v.PubKey)
+	}
+	for _, tx := range blockResponse.Result.Transactions {
+		if tx.Log != nil {
+			continue
+		}
+		err = dryRunStep(func() error {
+			if pk := transaction.ValidatorPubKey(tx.Type, tx.IData); pk != "" {
+				validatorKeys = append(validatorKeys, pk)
+			}
+			return nil
+		})
+		if err != nil {
+			fail("transaction", fmt.Errorf("%s: %s", tx.Hash, err))
+		}
+	}
+	for _, pk := range validatorKeys {
+		_, err = ext.validatorRepository.FindIdByPk(helpers.RemovePrefix(pk))
+		if err == pg.ErrNoRows {
+			report.inserts["validators"]++
+			ext.validatorRepository.CachePlaceholder(helpers.RemovePrefix(pk), report.placeholderId())
+		} else if err != nil {
+			return err
+		}
+	}
+
+	var addresses []string
+	err = dryRunStep(func() error {
+		var err error
+		_, addresses, err = ext.addressService.ExtractFromResponses(blockResponse, eventsResponse)
+		return err
+	})
+	if err != nil {
+		fail("addresses", err)
+	}
+	for _, address := range addresses {
+		_, err = ext.addressRepository.FindId(address)
+		if err == pg.ErrNoRows {
+			report.inserts["addresses"]++
+			ext.addressRepository.CachePlaceholder(address, report.placeholderId())
+		} else if err != nil {
+			return err
+		}
+	}
+
+	// Rows of the block transaction
+	block, err := ext.blockService.ParseBlockResponse(blockResponse)
+	if err != nil {
+		fail("block", err)
+	} else {
+		report.inserts["blocks"]++
+		ext.blockService.SetBlockCache(block)
+	}
+	if height != 1 {
+		report.inserts["block_validator"] += len(blockResponse.Result.Validators)
+	}
+
+	txs := ext.transactionService.ParseTransactions(height, blockResponse.Result.Time, blockResponse.Result.Transactions)
+	report.inserts["transactions"] += len(txs.Transactions)
+	report.inserts["invalid_transactions"] += len(txs.InvalidTransactions)
+	report.inserts["transaction_outputs"] += len(txs.Outputs)
+	report.inserts["transaction_validator"] += txs.ValidatorLinks
+	for hash, err := range txs.Failed {
+		fail("transaction", fmt.Errorf("%s: %s", hash, err))
+	}
+
+	events := ext.eventService.ParseEvents(height, eventsResponse)
+	report.inserts["rewards"] += len(events.Rewards)
+	report.inserts["slashes"] += len(events.Slashes)
+	report.deletes["coins"] += len(events.LiquidatedCoins)
+	for _, err := range events.Failed {
+		fail("event", err)
+	}
+
+	// Balances of the block addresses at the height
+	sort.Strings(addresses)
+	for i := 0; i < len(addresses); i += ext.env.AddrChunkSize {
+		end := i + ext.env.AddrChunkSize
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		quoted := make([]string, end-i)
+		for j, address := range addresses[i:end] {
+			quoted[j] = `"Mx` + address + `"`
+		}
+		response, err := ext.nodeApi.GetAddresses(quoted, height)
+		if err != nil {
+			return err
+		}
+		err = dryRunStep(func() error {
+			balances, err := ext.balanceService.HandleBalanceResponse(response)
+			if err != nil {
+				return err
+			}
+			forCreate, forUpdate, forDelete, err := ext.balanceService.FindChanges(addresses[i:end], balances)
+			if err != nil {
+				return err
+			}
+			report.inserts["balances"] += len(forCreate)
+			report.updates["balances"] += len(forUpdate)
+			report.deletes["balances"] += len(forDelete)
+			return nil
+		})
+		if err != nil {
+			fail("balances", err)
+		}
+	}
+
+	logger.WithField("failures", report.failures-failures).Info("Block checked")
+	return nil
+}
+
+// Panics of parsing code are counted as failures like errors
+func dryRunStep(step func() error) (err error) {
+	defer quarantine.Recover(&err)
+	return step()
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+const OnForkRollback = "rollback"
+
+// The node API does not return the parent hash of a block, so the parent link is checked
+// by comparing the hash of the previous height on the node with the hash stored in blocks.
+// Return the height from which indexing should continue
+func (ext *Extender) checkChainContinuity(height uint64) (uint64, error) {
+	parent := ext.blockService.GetBlockCache()
+	if parent == nil || parent.ID+1 != height {
+		return height, nil
+	}
+
+	nodeHash, err := ext.getNodeBlockHash(parent.ID)
+	if err != nil {
+		return height, err
+	}
+	return ext.compareParentHash(height, nodeHash)
+}
+
+// Compare the hash of the previous height on the node with the last saved block.
+// On a fork the extender stops or rolls back. Return the height from which indexing should continue
+func (ext *Extender) compareParentHash(height uint64, nodeHash string) (uint64, error) {
+	parent := ext.blockService.GetBlockCache()
+	if parent == nil || parent.ID+1 != height {
+		return height, nil
+	}
+	if isSameHash(nodeHash, parent.Hash) {
+		return height, nil
+	}
+
+	ancestor, err := ext.findCommonAncestor(parent.ID - 1)
+	if err != nil {
+		return height, err
+	}
+
+	ext.logger.WithFields(logrus.Fields{
+		"common_ancestor": ancestor,
+		"diverged_from":   ancestor + 1,
+		"diverged_to":     parent.ID,
+		"db_hash":         parent.Hash,
+		"node_hash":       nodeHash,
+	}).Error("Chain fork detected")
+
+	if ext.env.OnFork != OnForkRollback {
+		return height, fmt.Errorf("chain fork detected: heights %d-%d differ from the node", ancestor+1, parent.ID)
+	}
+
+	err = ext.rollback(ancestor)
+	if err != nil {
+		return height, err
+	}
+
+	if ancestor > 0 {
+		b, err := ext.blockRepository.GetById(ancestor)
+		if err != nil {
+			return height, err
+		}
+		ext.blockService.SetBlockCache(b)
+	} else {
+		ext.blockService.SetBlockCache(nil)
+	}
+
+	ext.logger.Warnf("Re-ingesting from height %d", ancestor+1)
+	return ancestor + 1, nil
+}
+
+// Walk down from the height until the hash on the node matches the hash in DB
+func (ext *Extender) findCommonAncestor(height uint64) (uint64, error) {
+	for h := height; h > 0; h-- {
+		b, err := ext.blockRepository.GetById(h)
+		if err != nil {
+			return 0, err
+		}
+		nodeHash, err := ext.getNodeBlockHash(h)
+		if err != nil {
+			return 0, err
+		}
+		if isSameHash(nodeHash, b.Hash) {
+			return h, nil
+		}
+	}
+	return 0, nil
+}
+
+func (ext *Extender) getNodeBlockHash(height uint64) (string, error) {
+	response, err := ext.nodeApi.GetBlock(height)
+	if err != nil {
+		return "", err
+	}
+	if response.Error != nil {
+		return "", fmt.Errorf("unable to get block %d from node: %s", height, response.Error.Message)
+	}
+	return response.Result.Hash, nil
+}
+
+func isSameHash(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/env"
+	"github.com/MinterTeam/minter-explorer-extender/node"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Node which returns another hash of a height on every request, as if each request went to a node of another fork
+type switchingNode struct {
+	node.NodeClient
+	mu    sync.Mutex
+	calls map[uint64]int
+}
+
+func (n *switchingNode) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	resp, err := n.NodeClient.GetBlock(height)
+	if err != nil || resp.Error != nil {
+		return resp, err
+	}
+	n.mu.Lock()
+	n.calls[height]++
+	resp.Result.Hash = fmt.Sprintf("Mh%d%d", height, n.calls[height])
+	n.mu.Unlock()
+	return resp, nil
+}
+
+func TestChasingModeChecksEveryBlock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extender-replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for height := 1; height <= 3; height++ {
+		block := responses.BlockResponse{}
+		block.Result = responses.BlockResult{
+			Height:  strconv.Itoa(height),
+			Time:    time.Date(2019, 6, 1, 12, 0, height, 0, time.UTC),
+			TxCount: "0",
+			TotalTx: "0",
+			Size:    "100",
+		}
+		writeArchived(t, dir, strconv.Itoa(height), "block", block)
+		writeArchived(t, dir, strconv.Itoa(height), "events", responses.EventsResponse{})
+	}
+
+	ext := NewExtender(context.Background(), &env.Environment{
+		ExtenderEnvironment: models.ExtenderEnvironment{TxChunkSize: 100, EventsChunkSize: 100},
+		Roles:               []string{RoleIngest},
+		PartitionSize:       1000,
+		PartitionsAhead:     2,
+		ReplayDir:           dir,
+		Storage:             StorageMemory,
+	})
+	ext.nodeApi = &switchingNode{NodeClient: ext.nodeApi, calls: make(map[uint64]int)}
+
+	// Block 1 is saved, the hash of its height differs when it is requested again as the parent of block 2
+	height, err := ext.runChasingMode(context.Background(), 1, 3)
+	if err == nil {
+		t.Fatal("runChasingMode() saved the range over a fork")
+	}
+	if height != 2 {
+		t.Errorf("runChasingMode() = %d, want 2", height)
+	}
+	last, err := ext.blockRepository.GetLastFromDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID != 1 {
+		t.Errorf("last saved block = %d, want 1", last.ID)
+	}
+}
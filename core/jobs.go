@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/progress"
+	"github.com/MinterTeam/minter-explorer-extender/scheduler"
+	"io"
+	"time"
+)
+
+// Periodic jobs run by the scheduler
+const (
+	jobRewardsAggregation = "rewards_aggregation"
+	jobTxsIndex           = "txs_index"
+	jobCoinsUpdate        = "coins_update"
+	jobPartitions         = "partitions"
+)
+
+// Register the periodic jobs of the instance roles
+func (ext *Extender) scheduleJobs() {
+	if ext.hasRole(RoleAggregator) {
+		ext.scheduler.Add(&scheduler.Job{
+			Name:        jobRewardsAggregation,
+			EveryBlocks: uint64(ext.env.RewardAggregateEveryBlocksCount),
+			Run:         ext.aggregateRewards,
+		})
+		ext.scheduler.Add(&scheduler.Job{
+			Name:     jobTxsIndex,
+			Interval: time.Duration(ext.env.WrkUpdateTxsIndexTime) * time.Second,
+			Run: func(ctx context.Context, height uint64) error {
+				return ext.transactionService.UpdateTxsIndex()
+			},
+		})
+	}
+	if ext.hasRole(RoleIngest) {
+		ext.scheduler.Add(&scheduler.Job{
+			Name:     jobCoinsUpdate,
+			Interval: time.Duration(ext.env.CoinsUpdateTime) * time.Minute,
+			Run: func(ctx context.Context, height uint64) error {
+				return ext.coinService.UpdateAllCoinsInfo()
+			},
+		})
+		// Partitions are created well before the head reaches them
+		everyBlocks := uint64(ext.env.PartitionSize / 2)
+		if everyBlocks == 0 {
+			everyBlocks = 1
+		}
+		ext.scheduler.Add(&scheduler.Job{
+			Name:        jobPartitions,
+			EveryBlocks: everyBlocks,
+			Run: func(ctx context.Context, height uint64) error {
+				return ext.partitionService.Maintain(height)
+			},
+		})
+	}
+}
+
+// Rewards which are not aggregated are picked up by the next aggregation
+func (ext *Extender) aggregateRewards(ctx context.Context, height uint64) error {
+	err := ext.retryPolicy.Do(ctx, func() error {
+		return ext.eventService.AggregateRewards(ext.env.RewardAggregateTimeInterval, height)
+	})
+	if err != nil {
+		return err
+	}
+	ext.progressService.Complete(progress.StageRewardsAggregation, height)
+	return nil
+}
+
+// Print the last run of every scheduled job
+func (ext *Extender) printJobRuns(w io.Writer) error {
+	runs, err := ext.scheduler.GetLastRuns()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\nJOB\tHEIGHT\tSTARTED\tDURATION\tRESULT\tERROR")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", run.Job, run.Height, run.StartedAt.Format(time.RFC3339),
+			time.Duration(run.DurationMs)*time.Millisecond, run.Result, run.Error)
+	}
+	return nil
+}
@@ -1,42 +1,84 @@
 package core
 
 import (
+	"context"
 	"github.com/MinterTeam/minter-explorer-extender/address"
 	"github.com/MinterTeam/minter-explorer-extender/balance"
 	"github.com/MinterTeam/minter-explorer-extender/block"
 	"github.com/MinterTeam/minter-explorer-extender/broadcast"
+	"github.com/MinterTeam/minter-explorer-extender/bulk"
 	"github.com/MinterTeam/minter-explorer-extender/coin"
+	"github.com/MinterTeam/minter-explorer-extender/env"
 	"github.com/MinterTeam/minter-explorer-extender/events"
+	"github.com/MinterTeam/minter-explorer-extender/genesis"
+	"github.com/MinterTeam/minter-explorer-extender/leader"
+	"github.com/MinterTeam/minter-explorer-extender/memory"
+	"github.com/MinterTeam/minter-explorer-extender/migrations"
+	"github.com/MinterTeam/minter-explorer-extender/node"
+	"github.com/MinterTeam/minter-explorer-extender/partition"
+	"github.com/MinterTeam/minter-explorer-extender/progress"
+	"github.com/MinterTeam/minter-explorer-extender/quarantine"
+	"github.com/MinterTeam/minter-explorer-extender/queue"
+	"github.com/MinterTeam/minter-explorer-extender/retry"
+	"github.com/MinterTeam/minter-explorer-extender/scheduler"
 	"github.com/MinterTeam/minter-explorer-extender/transaction"
 	"github.com/MinterTeam/minter-explorer-extender/validator"
 	"github.com/MinterTeam/minter-explorer-tools/helpers"
 	"github.com/MinterTeam/minter-explorer-tools/models"
-	"github.com/MinterTeam/minter-node-go-api"
 	"github.com/MinterTeam/minter-node-go-api/responses"
 	"github.com/go-pg/pg"
 	"github.com/sirupsen/logrus"
 	"math"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 )
 
 const ChasingModDiff = 2
 
+// Lease held by the instance which indexes blocks
+const ingestLease = "ingest"
+
+// Storage which keeps all data in memory instead of PostgreSQL, for tests and demo deployments
+const StorageMemory = "memory"
+
+// Database of the extender, *pg.DB or memory.Store
+type database interface {
+	RunInTransaction(fn func(tx *pg.Tx) error) error
+}
+
 type Extender struct {
-	env                 *models.ExtenderEnvironment
-	nodeApi             *minter_node_go_api.MinterNodeApi
+	env                 *env.Environment
+	db                  database
+	nodeApi             node.NodeClient
+	blockSubscription   *node.BlockSubscription
 	blockService        *block.Service
 	addressService      *address.Service
-	blockRepository     *block.Repository
+	addressRepository   address.Repository
+	blockRepository     block.Repository
 	validatorService    *validator.Service
-	validatorRepository *validator.Repository
+	validatorRepository validator.Repository
+	coinRepository      coin.Repository
 	transactionService  *transaction.Service
 	eventService        *events.Service
 	balanceService      *balance.Service
 	coinService         *coin.Service
+	broadcastService    *broadcast.Service
+	genesisService      *genesis.Service
+	retryPolicy         *retry.Policy
+	progressService     *progress.Service
+	quarantineService   *quarantine.Service
+	queueRepository     queue.Repository
+	leaderService       *leader.Service
+	migrationService    *migrations.Service
+	partitionService    *partition.Service
+	fenceWrites         bool // block transactions check that the leader lease is still held
 	chasingMode         bool
 	currentNodeHeight   uint64
+	scheduler           *scheduler.Service
+	wgStages            [3]sync.WaitGroup  // workers grouped by the order in which their channels are closed
+	stopQueueWorkers    context.CancelFunc // stops workers which claim jobs from the queue and jobs run by time
 	logger              *logrus.Entry
 }
 
@@ -50,7 +92,8 @@ func (d dbLogger) AfterQuery(q *pg.QueryEvent) {
 	d.logger.Info(q.FormattedQuery())
 }
 
-func NewExtender(env *models.ExtenderEnvironment) *Extender {
+// The context stops retries of node requests, so the shutdown doesn't wait for their backoff
+func NewExtender(ctx context.Context, env *env.Environment) *Extender {
 	//Init Logger
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.JSONFormatter{})
@@ -72,265 +115,634 @@ func NewExtender(env *models.ExtenderEnvironment) *Extender {
 		"app":     "Minter Explorer Extender",
 	})
 
-	//Init DB
-	db := pg.Connect(&pg.Options{
-		User:            env.DbUser,
-		Password:        env.DbPassword,
-		Database:        env.DbName,
-		ApplicationName: env.AppName,
-	})
+	serviceEnv := &env.ExtenderEnvironment
 
-	if env.Debug {
-		db.AddQueryHook(dbLogger{logger: contextLogger})
+	//api
+	var blockSubscription *node.BlockSubscription
+	if env.NodeWsLink != "" && env.ReplayDir == "" {
+		blockSubscription = node.NewBlockSubscription(env.NodeWsLink, contextLogger)
 	}
 
-	//api
-	nodeApi := minter_node_go_api.New(env.NodeApi)
+	retryPolicy := retry.NewPolicy(env.RetryMaxAttempts, time.Duration(env.RetryInitialIntervalMs)*time.Millisecond,
+		time.Duration(env.RetryMaxIntervalMs)*time.Millisecond, contextLogger)
+	var nodeApi node.NodeClient
+	if env.ReplayDir != "" {
+		nodeApi = node.NewReplay(env.ReplayDir)
+	} else {
+		nodeApi = node.WithRetry(ctx, node.NewPool(env.NodeApiLinks, contextLogger), retryPolicy)
+		if env.RecordDir != "" {
+			nodeApi = node.NewRecorder(nodeApi, env.RecordDir, contextLogger)
+		}
+	}
 
 	// Repositories
-	blockRepository := block.NewRepository(db)
-	validatorRepository := validator.NewRepository(db)
-	transactionRepository := transaction.NewRepository(db)
-	addressRepository := address.NewRepository(db)
-	coinRepository := coin.NewRepository(db)
-	eventsRepository := events.NewRepository(db)
-	balanceRepository := balance.NewRepository(db)
+	var (
+		db                    database
+		blockRepository       block.Repository
+		validatorRepository   validator.Repository
+		transactionRepository transaction.Repository
+		addressRepository     address.Repository
+		coinRepository        coin.Repository
+		eventsRepository      events.Repository
+		balanceRepository     balance.Repository
+		progressRepository    progress.Repository
+		quarantineRepository  quarantine.Repository
+		queueRepository       queue.Repository
+		leaderRepository      leader.Repository
+		schedulerRepository   scheduler.Repository
+		partitionRepository   partition.Repository
+		migrationService      *migrations.Service
+	)
+	if env.Storage == StorageMemory {
+		store := memory.NewStore()
+		db = store
+		blockRepository = memory.NewBlockRepository(store)
+		validatorRepository = memory.NewValidatorRepository(store)
+		transactionRepository = memory.NewTransactionRepository(store)
+		addressRepository = memory.NewAddressRepository(store)
+		coinRepository = memory.NewCoinRepository(store)
+		eventsRepository = memory.NewEventsRepository(store)
+		balanceRepository = memory.NewBalanceRepository(store)
+		progressRepository = memory.NewProgressRepository(store)
+		quarantineRepository = memory.NewQuarantineRepository(store)
+		queueRepository = memory.NewQueueRepository(store)
+		leaderRepository = memory.NewLeaderRepository(store)
+		schedulerRepository = memory.NewSchedulerRepository(store)
+		partitionRepository = memory.NewPartitionRepository(store)
+	} else {
+		options, err := pgOptions(env)
+		if err != nil {
+			contextLogger.Fatal(err)
+		}
+		pgDb := pg.Connect(options)
+		if env.Debug {
+			pgDb.AddQueryHook(dbLogger{logger: contextLogger})
+		}
+		db = pgDb
+		blockRepository = block.NewRepository(pgDb, env.CopyThreshold)
+		validatorRepository = validator.NewRepository(pgDb)
+		transactionRepository = transaction.NewRepository(pgDb, env.CopyThreshold)
+		addressRepository = address.NewRepository(pgDb)
+		coinRepository = coin.NewRepository(pgDb)
+		eventsRepository = events.NewRepository(pgDb, env.CopyThreshold)
+		balanceRepository = balance.NewRepository(pgDb)
+		progressRepository = progress.NewRepository(pgDb)
+		quarantineRepository = quarantine.NewRepository(pgDb)
+		queueRepository = queue.NewRepository(pgDb)
+		leaderRepository = leader.NewRepository(pgDb)
+		schedulerRepository = scheduler.NewRepository(pgDb)
+		partitionRepository = partition.NewRepository(pgDb)
+		migrationService = migrations.NewService(pgDb, migrations.NewRepository(pgDb), contextLogger)
+	}
 
 	// Services
-	broadcastService := broadcast.NewService(env, addressRepository, coinRepository, contextLogger)
-	coinService := coin.NewService(env, nodeApi, coinRepository, addressRepository, contextLogger)
-	balanceService := balance.NewService(env, balanceRepository, nodeApi, addressRepository, coinRepository, broadcastService, contextLogger)
+	progressService := progress.NewService(progressRepository, contextLogger)
+	quarantineService := quarantine.NewService(quarantineRepository, contextLogger)
+	broadcastService := broadcast.NewService(serviceEnv, addressRepository, coinRepository, contextLogger)
+	coinService := coin.NewService(serviceEnv, nodeApi, coinRepository, addressRepository, contextLogger)
+	balanceService := balance.NewService(serviceEnv, balanceRepository, nodeApi, addressRepository, coinRepository, broadcastService, progressService, queueRepository, contextLogger)
+
+	// Addresses of indexed blocks go to the balances stage only if it runs in the same instance
+	var balanceAddresses chan<- models.BlockAddresses
+	if hasRole(env.Roles, RoleBalances) {
+		balanceAddresses = balanceService.GetAddressesChannel()
+	}
 
 	return &Extender{
 		env:                 env,
+		db:                  db,
 		nodeApi:             nodeApi,
-		blockService:        block.NewBlockService(blockRepository, validatorRepository, broadcastService),
-		eventService:        events.NewService(env, eventsRepository, validatorRepository, addressRepository, coinRepository, coinService, balanceRepository, contextLogger),
+		blockSubscription:   blockSubscription,
+		blockService:        block.NewBlockService(blockRepository, validatorRepository),
+		eventService:        events.NewService(serviceEnv, eventsRepository, env.CopyThreshold, validatorRepository, addressRepository, coinRepository, coinService, balanceRepository, quarantineService, contextLogger),
 		blockRepository:     blockRepository,
-		validatorService:    validator.NewService(env, nodeApi, validatorRepository, addressRepository, coinRepository, contextLogger),
-		transactionService:  transaction.NewService(env, transactionRepository, addressRepository, validatorRepository, coinRepository, coinService, broadcastService, contextLogger),
-		addressService:      address.NewService(env, addressRepository, balanceService.GetAddressesChannel(), contextLogger),
+		validatorService:    validator.NewService(serviceEnv, nodeApi, validatorRepository, addressRepository, coinRepository, retryPolicy, progressService, queueRepository, contextLogger),
+		transactionService:  transaction.NewService(serviceEnv, transactionRepository, env.CopyThreshold, addressRepository, validatorRepository, coinRepository, progressService, quarantineService, contextLogger),
+		addressRepository:   addressRepository,
+		addressService:      address.NewService(serviceEnv, addressRepository, balanceAddresses, retryPolicy, contextLogger),
 		validatorRepository: validatorRepository,
+		coinRepository:      coinRepository,
 		balanceService:      balanceService,
 		coinService:         coinService,
-		chasingMode:         true,
-		currentNodeHeight:   0,
-		logger:              contextLogger,
+		broadcastService:    broadcastService,
+		retryPolicy:         retryPolicy,
+		progressService:     progressService,
+		quarantineService:   quarantineService,
+		queueRepository:     queueRepository,
+		scheduler:           scheduler.NewService(schedulerRepository, contextLogger),
+		migrationService:    migrationService,
+		partitionService: partition.NewService(partitionRepository, env.PartitionSize, env.PartitionsAhead,
+			env.PartitionsRetain, contextLogger),
+		leaderService:     leader.NewService(leaderRepository, ingestLease, time.Duration(env.LeaderLeaseSec)*time.Second, contextLogger),
+		genesisService:    genesis.NewService(serviceEnv, addressRepository, coinRepository, validatorRepository, balanceRepository, contextLogger),
+		chasingMode:       true,
+		currentNodeHeight: 0,
+		logger:            contextLogger,
 	}
 }
 
-func (ext *Extender) Run() {
+func (ext *Extender) Run(ctx context.Context) error {
+	err := ext.checkRoles()
+	if err != nil {
+		return err
+	}
+	err = ext.checkSchema()
+	if err != nil {
+		return err
+	}
 	//check connections to node
-	_, err := ext.nodeApi.GetStatus()
-	if err == nil {
-		err = ext.blockRepository.DeleteLastBlockData()
-	} else {
+	_, err = ext.nodeApi.GetStatus()
+	if err != nil {
 		ext.logger.Error(err)
+		return err
+	}
+	if !ext.hasRole(RoleIngest) {
+		return ext.runFollower(ctx)
+	}
+
+	// Only the leader indexes blocks, a standby instance waits here until the lease of the leader expires
+	parentCtx := ctx
+	ctx, err = ext.leaderService.Campaign(parentCtx)
+	if err != nil {
+		// Stopped while waiting for the lease
+		if parentCtx.Err() != nil {
+			return nil
+		}
+		ext.logger.Error(err)
+		return err
+	}
+	defer ext.leaderService.Release()
+	ext.fenceWrites = true
+
+	// Blocks are committed atomically, this only cleans up data left by older versions
+	err = ext.retryPolicy.Do(ctx, func() error {
+		return ext.db.RunInTransaction(func(tx *pg.Tx) error {
+			return ext.blockRepository.WithTx(tx).DeleteLastBlockData()
+		})
+	})
+	if err != nil {
+		ext.logger.Error(err)
+		return err
 	}
-	helpers.HandleError(err)
 
 	var height uint64
 
 	// ----- Workers -----
-	ext.runWorkers()
+	ext.runWorkers(ctx)
+
+	if ext.blockSubscription != nil {
+		go ext.blockSubscription.Run(ctx)
+	}
 
 	lastExplorerBlock, _ := ext.blockRepository.GetLastFromDB()
 
 	if lastExplorerBlock != nil {
 		height = lastExplorerBlock.ID + 1
 		ext.blockService.SetBlockCache(lastExplorerBlock)
+		ext.resumeStages(ctx, lastExplorerBlock.ID)
+	} else if ext.env.GenesisFile != "" {
+		height, err = ext.genesisService.ImportFromFile(ext.env.GenesisFile)
+		if err != nil {
+			ext.stop(1)
+			return err
+		}
 	} else {
+		ext.logger.Warn("Database is empty and genesis file is not set")
 		height = 1
 	}
 
+	// Partitions of the next heights must exist before blocks are saved
+	err = ext.retryPolicy.Do(ctx, func() error {
+		return ext.partitionService.Maintain(height)
+	})
+	if err != nil {
+		ext.logger.Error(err)
+		ext.stop(height)
+		return err
+	}
+
 	for {
-		start := time.Now()
-		ext.findOutChasingMode(height)
-		//Pulling block data
-		blockResponse, err := ext.nodeApi.GetBlock(height)
-		helpers.HandleError(err)
-		if blockResponse.Error != nil {
-			time.Sleep(2 * time.Second)
-			continue
+		select {
+		case <-ctx.Done():
+			ext.stop(height)
+			if parentCtx.Err() == nil {
+				return leader.ErrNotLeader
+			}
+			return nil
+		case err = <-ext.balanceService.GetErrorsChannel():
+			// Workers report failures instead of leaving the stage stalled
+			ext.logger.Error(err)
+			ext.stop(height)
+			return err
+		default:
 		}
 
-		//Pulling events
-		eventsResponse, err := ext.nodeApi.GetBlockEvents(height)
+		err = ext.findOutChasingMode(height)
 		if err != nil {
 			ext.logger.Error(err)
+			ext.stop(height)
+			return err
 		}
-		helpers.HandleError(err)
+		if ext.chasingMode {
+			height, err = ext.runChasingMode(ctx, height, ext.currentNodeHeight-ChasingModDiff)
+		} else {
+			height, err = ext.runSingleBlock(ctx, height)
+		}
+		if err != nil {
+			ext.logger.Error(err)
+			ext.stop(height)
+			return err
+		}
+	}
+}
+
+// Fetch and process the next block. Return the height which should be processed next
+func (ext *Extender) runSingleBlock(ctx context.Context, height uint64) (uint64, error) {
+	start := time.Now()
+	//Pulling block data
+	blockResponse, err := ext.nodeApi.GetBlock(height)
+	if err != nil {
+		return height, err
+	}
+	if blockResponse.Error != nil {
+		ext.waitForNewBlock(ctx)
+		return height, nil
+	}
+
+	nextHeight, err := ext.checkChainContinuity(height)
+	if err != nil || nextHeight != height {
+		return nextHeight, err
+	}
+
+	//Pulling events
+	eventsResponse, err := ext.nodeApi.GetBlockEvents(height)
+	if err != nil {
+		return height, err
+	}
 
-		ext.handleCoinsFromTransactions(blockResponse.Result.Transactions)
-		ext.handleAddressesFromResponses(blockResponse, eventsResponse)
-		ext.handleBlockResponse(blockResponse)
+	err = ext.processBlock(ctx, height, blockResponse, eventsResponse)
+	if err != nil {
+		return height, err
+	}
+
+	elapsed := time.Since(start)
+	ext.logger.Info("Processing time: ", elapsed)
+	return height + 1, nil
+}
 
-		if height%uint64(ext.env.RewardAggregateEveryBlocksCount) == 0 {
-			go ext.eventService.AggregateRewards(ext.env.RewardAggregateTimeInterval, height)
+// Wait until the node commits a new block.
+// Without an active new block subscription the node is polled every 2 seconds
+func (ext *Extender) waitForNewBlock(ctx context.Context) {
+	var newBlocks <-chan struct{}
+	if ext.blockSubscription != nil && ext.blockSubscription.IsConnected() {
+		newBlocks = ext.blockSubscription.NewBlocks()
+	}
+	select {
+	case <-ctx.Done():
+	case <-newBlocks:
+	case <-time.After(2 * time.Second):
+	}
+}
+
+// Process heights from the range in order while their responses are prefetched in parallel.
+// The chain continuity is checked for every block against the block saved before it, since the node
+// can change in the middle of the range. The range is stopped on a fork.
+// Return the height which should be processed next
+func (ext *Extender) runChasingMode(ctx context.Context, from, to uint64) (uint64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	height := from
+	for data := range ext.prefetchBlocks(ctx, from, to, true) {
+		if data.err != nil {
+			return height, data.err
+		}
+		if data.blockResponse.Error != nil {
+			ext.logger.WithField("block", data.height).Warn(data.blockResponse.Error.Message)
+			return height, nil
 		}
-		go ext.handleEventResponse(height, eventsResponse)
 
-		height++
+		nextHeight, err := ext.compareParentHash(data.height, data.parentHash)
+		if err != nil || nextHeight != data.height {
+			return nextHeight, err
+		}
+
+		start := time.Now()
+		err = ext.processBlock(ctx, data.height, data.blockResponse, data.eventsResponse)
+		if err != nil {
+			return height, err
+		}
+		height = data.height + 1
 
 		elapsed := time.Since(start)
 		ext.logger.Info("Processing time: ", elapsed)
 	}
+	return height, nil
 }
 
-func (ext *Extender) runWorkers() {
-
-	// Addresses
-	for w := 1; w <= ext.env.WrkSaveAddressesCount; w++ {
-		go ext.addressService.SaveAddressesWorker(ext.addressService.GetSaveAddressesJobChannel())
+func (ext *Extender) processBlock(ctx context.Context, height uint64, blockResponse *responses.BlockResponse,
+	eventsResponse *responses.EventsResponse) error {
+	// The extender stops here if the scheduled partition maintenance keeps failing
+	err := ext.retryPolicy.Do(ctx, func() error {
+		return ext.partitionService.EnsureAhead(height)
+	})
+	if err != nil {
+		return err
 	}
-
-	// Transactions
-	for w := 1; w <= ext.env.WrkSaveTxsCount; w++ {
-		go ext.transactionService.SaveTransactionsWorker(ext.transactionService.GetSaveTxJobChannel())
+	err = ext.handleCoinsFromTransactions(ctx, blockResponse.Result.Transactions)
+	if err != nil {
+		return err
 	}
-	for w := 1; w <= ext.env.WrkSaveTxsOutputCount; w++ {
-		go ext.transactionService.SaveTransactionsOutputWorker(ext.transactionService.GetSaveTxsOutputJobChannel())
+	err = ext.handleAddressesFromResponses(blockResponse, eventsResponse)
+	if err != nil {
+		return err
 	}
-	for w := 1; w <= ext.env.WrkSaveInvTxsCount; w++ {
-		go ext.transactionService.SaveInvalidTransactionsWorker(ext.transactionService.GetSaveInvalidTxsJobChannel())
+	err = ext.handleBlockResponse(ctx, blockResponse, eventsResponse)
+	if err != nil {
+		return err
 	}
-	go ext.transactionService.UpdateTxsIndexWorker()
 
-	// Validators
-	for w := 1; w <= ext.env.WrkSaveValidatorTxsCount; w++ {
-		go ext.transactionService.SaveTxValidatorWorker(ext.transactionService.GetSaveTxValidatorJobChannel())
-	}
-	go ext.validatorService.UpdateValidatorsWorker(ext.validatorService.GetUpdateValidatorsJobChannel())
-	go ext.validatorService.UpdateStakesWorker(ext.validatorService.GetUpdateStakesJobChannel())
+	ext.scheduler.OnHeight(height)
+	return nil
+}
 
-	// Events
-	for w := 1; w <= ext.env.WrkSaveRewardsCount; w++ {
-		go ext.eventService.SaveRewardsWorker(ext.eventService.GetSaveRewardsJobChannel())
-	}
-	for w := 1; w <= ext.env.WrkSaveSlashesCount; w++ {
-		go ext.eventService.SaveSlashesWorker(ext.eventService.GetSaveSlashesJobChannel())
+// Workers are split into stages: a stage only receives jobs from the main loop
+// or from the stages before it, so closing the channels stage by stage lets
+// every queued job reach the database before the process exits.
+// Only workers of the instance roles are started
+func (ext *Extender) runWorkers(ctx context.Context) {
+	ctx, ext.stopQueueWorkers = context.WithCancel(ctx)
+	ext.scheduleJobs()
+	ext.scheduler.Run(ctx)
+	first, second, third := &ext.wgStages[0], &ext.wgStages[1], &ext.wgStages[2]
+
+	if ext.hasRole(RoleIngest) {
+		// Addresses
+		startWorkers(first, ext.env.WrkSaveAddressesCount, func() {
+			ext.addressService.SaveAddressesWorker(ext.addressService.GetSaveAddressesJobChannel())
+		})
+
+		//Coins
+		startWorkers(first, 1, func() {
+			ext.coinService.UpdateCoinsInfoFromTxsWorker(ext.coinService.GetUpdateCoinsFromTxsJobChannel())
+		})
+		startWorkers(second, 1, func() {
+			ext.coinService.UpdateCoinsInfoFromCoinsMap(ext.coinService.GetUpdateCoinsFromCoinsMapJobChannel())
+		})
 	}
 
-	// Balances
-	go ext.balanceService.Run()
-	for w := 1; w <= ext.env.WrkGetBalancesFromNodeCount; w++ {
-		go ext.balanceService.GetBalancesFromNodeWorker(ext.balanceService.GetBalancesFromNodeChannel(), ext.balanceService.GetUpdateBalancesJobChannel())
+	if ext.hasRole(RoleValidators) {
+		startWorkers(first, 1, func() {
+			ext.validatorService.UpdateValidatorsWorker(ext.validatorService.GetUpdateValidatorsJobChannel())
+		})
+		startWorkers(first, 1, func() {
+			ext.validatorService.UpdateStakesWorker(ctx)
+		})
 	}
-	for w := 1; w <= ext.env.WrkUpdateBalanceCount; w++ {
-		go ext.balanceService.UpdateBalancesWorker(ext.balanceService.GetUpdateBalancesJobChannel())
+
+	if ext.hasRole(RoleBalances) {
+		startWorkers(first, 1, ext.balanceService.Run)
+		startWorkers(second, ext.env.WrkGetBalancesFromNodeCount, func() {
+			ext.balanceService.GetBalancesFromNodeWorker(ctx, ext.balanceService.GetUpdateBalancesJobChannel())
+		})
+		startWorkers(third, ext.env.WrkUpdateBalanceCount, func() {
+			ext.balanceService.UpdateBalancesWorker(ext.balanceService.GetUpdateBalancesJobChannel())
+		})
 	}
+}
 
-	//Coins
-	go ext.coinService.UpdateCoinsInfoFromTxsWorker(ext.coinService.GetUpdateCoinsFromTxsJobChannel())
-	go ext.coinService.UpdateCoinsInfoFromCoinsMap(ext.coinService.GetUpdateCoinsFromCoinsMapJobChannel())
+func (ext *Extender) stop(height uint64) {
+	ext.shutdown()
+	ext.logger.Warnf("Extender stopped, last committed height: %d", height-1)
 }
 
-func (ext *Extender) handleAddressesFromResponses(blockResponse *responses.BlockResponse, eventsResponse *responses.EventsResponse) {
-	err := ext.addressService.HandleResponses(blockResponse, eventsResponse)
-	if err != nil {
-		ext.logger.Error(err)
+// Close service channels stage by stage and wait until workers finish their batches
+func (ext *Extender) shutdown() {
+	ext.logger.Warn("Shutting down, waiting for workers to finish")
+	ext.scheduler.Wait()
+
+	// Queued jobs are kept in the database, claimed ones are finished first
+	ext.stopQueueWorkers()
+	close(ext.addressService.GetSaveAddressesJobChannel())
+	close(ext.validatorService.GetUpdateValidatorsJobChannel())
+	close(ext.balanceService.GetAddressesChannel())
+	close(ext.coinService.GetUpdateCoinsFromTxsJobChannel())
+	ext.wgStages[0].Wait()
+
+	close(ext.coinService.GetUpdateCoinsFromCoinsMapJobChannel())
+	ext.wgStages[1].Wait()
+
+	close(ext.balanceService.GetUpdateBalancesJobChannel())
+	ext.wgStages[2].Wait()
+}
+
+func startWorkers(wg *sync.WaitGroup, count int, worker func()) {
+	for w := 1; w <= count; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker()
+		}()
 	}
-	helpers.HandleError(err)
 }
 
-func (ext *Extender) handleBlockResponse(response *responses.BlockResponse) {
-	// Save validators if not exist
-	validators, err := ext.validatorService.HandleBlockResponse(response)
+func (ext *Extender) handleAddressesFromResponses(blockResponse *responses.BlockResponse, eventsResponse *responses.EventsResponse) error {
+	err := ext.addressService.HandleResponses(blockResponse, eventsResponse)
 	if err != nil {
 		ext.logger.Error(err)
 	}
-	helpers.HandleError(err)
+	return err
+}
 
-	// Save block
-	err = ext.blockService.HandleBlockResponse(response)
+func (ext *Extender) handleBlockResponse(ctx context.Context, response *responses.BlockResponse,
+	eventsResponse *responses.EventsResponse) error {
+	height, blockModel, transactions, err := ext.saveBlock(ctx, response, eventsResponse, false)
 	if err != nil {
-		ext.logger.Error(err)
+		return err
 	}
-	helpers.HandleError(err)
 
-	ext.linkBlockValidator(*response)
+	go ext.broadcastService.PublishBlock(blockModel)
 
-	//first block don't have validators
-	if response.Result.TxCount != "0" && len(validators) > 0 {
-		ext.handleTransactions(response, validators)
+	if len(transactions) > 0 {
+		ext.coinService.GetUpdateCoinsFromTxsJobChannel() <- transactions
+		//no need to publish a big number of transaction
+		if len(transactions) > 10 {
+			go ext.broadcastService.PublishTransactions(transactions[:10])
+		} else {
+			go ext.broadcastService.PublishTransactions(transactions)
+		}
 	}
 
+	// No need to update candidate and stakes at the same time
+	// Candidate will be updated in the next iteration
+	if !ext.hasRole(RoleValidators) {
+		return nil
+	}
+	if height%stakesEveryBlocksCount == 0 {
+		return ext.queueStakesUpdate(ctx, height)
+	} else if height > 1 {
+		ext.validatorService.GetUpdateValidatorsJobChannel() <- height
+	}
+	return nil
+}
+
+// Stakes updates are persisted, so they are taken by a validators instance even after a restart
+func (ext *Extender) queueStakesUpdate(ctx context.Context, height uint64) error {
+	return ext.retryPolicy.Do(ctx, func() error {
+		return ext.validatorService.QueueStakesUpdate(height)
+	})
+}
+
+// Save the block with all data derived from it in one database transaction.
+// If replace is set, data already stored for the height is deleted in the same transaction
+// and the head state (coins and balances) is left as it is.
+// The transaction is repeated by the retry policy on transient errors until the context is done
+func (ext *Extender) saveBlock(ctx context.Context, response *responses.BlockResponse, eventsResponse *responses.EventsResponse,
+	replace bool) (uint64, *models.Block, []*models.Transaction, error) {
 	height, err := strconv.ParseUint(response.Result.Height, 10, 64)
 	if err != nil {
 		ext.logger.Error(err)
+		return 0, nil, nil, err
 	}
-	helpers.HandleError(err)
 
-	// No need to update candidate and stakes at the same time
-	// Candidate will be updated in the next iteration
-	if height%12 == 0 {
-		ext.validatorService.GetUpdateStakesJobChannel() <- height
-	} else if height > 1 {
-		ext.validatorService.GetUpdateValidatorsJobChannel() <- height
+	// Save validators if not exist
+	var validators []*models.Validator
+	err = ext.retryPolicy.Do(ctx, func() error {
+		validators, err = ext.validatorService.HandleBlockResponse(response)
+		return err
+	})
+	if err != nil {
+		ext.logger.WithField("block", height).Error(err)
+		return height, nil, nil, err
+	}
+
+	var (
+		blockModel   *models.Block
+		transactions []*models.Transaction
+		coins        map[string]struct{}
+	)
+	err = ext.retryPolicy.Do(ctx, func() error {
+		return ext.db.RunInTransaction(func(tx *pg.Tx) error {
+			var err error
+			if ext.fenceWrites {
+				err = ext.leaderService.CheckInTx(tx)
+				if err != nil {
+					return err
+				}
+			}
+			if replace {
+				err = ext.blockRepository.WithTx(tx).DeleteHeight(height)
+				if err != nil {
+					return err
+				}
+			}
+
+			blockModel, err = ext.blockService.HandleBlockResponse(tx, response)
+			if err != nil {
+				return err
+			}
+
+			err = ext.linkBlockValidator(tx, response)
+			if err != nil {
+				return err
+			}
+
+			//first block don't have validators
+			if response.Result.TxCount != "0" && len(validators) > 0 {
+				transactions, err = ext.handleTransactions(tx, response)
+				if err != nil {
+					return err
+				}
+			}
+
+			coins, err = ext.handleEventResponse(tx, height, eventsResponse, replace)
+			if err != nil {
+				return err
+			}
+
+			return ext.progressService.CompleteInTx(tx, progress.StageBlocks, height)
+		})
+	})
+	if err != nil {
+		ext.logger.WithField("block", height).Error(err)
+		return height, nil, nil, err
+	}
+
+	ext.blockService.SetBlockCache(blockModel)
+	// Coins are updated from the saved data, so only after the commit
+	if len(coins) > 0 {
+		ext.coinService.GetUpdateCoinsFromCoinsMapJobChannel() <- coins
 	}
+	return height, blockModel, transactions, nil
 }
 
-func (ext *Extender) handleCoinsFromTransactions(transactions []responses.Transaction) {
+func (ext *Extender) handleCoinsFromTransactions(ctx context.Context, transactions []responses.Transaction) error {
 	if len(transactions) > 0 {
 		coins, err := ext.coinService.ExtractCoinsFromTransactions(transactions)
 		if err != nil {
 			ext.logger.Error(err)
-			helpers.HandleError(err)
+			return err
 		}
 		if len(coins) > 0 {
-			err = ext.coinService.CreateNewCoins(coins)
+			err = ext.retryPolicy.Do(ctx, func() error {
+				return ext.coinService.CreateNewCoins(coins)
+			})
 			if err != nil {
 				ext.logger.Error(err)
-				helpers.HandleError(err)
+				return err
 			}
 		}
 	}
+	return nil
 }
 
-func (ext *Extender) handleTransactions(response *responses.BlockResponse, validators []*models.Validator) {
+func (ext *Extender) handleTransactions(tx *pg.Tx, response *responses.BlockResponse) ([]*models.Transaction, error) {
 	height, err := strconv.ParseUint(response.Result.Height, 10, 64)
 	if err != nil {
-		ext.logger.Error(err)
+		return nil, err
 	}
-	helpers.HandleError(err)
-	chunksCount := int(math.Ceil(float64(len(response.Result.Transactions)) / float64(ext.env.TxChunkSize)))
+	var transactions []*models.Transaction
+	// Transactions of a large block are saved at once, so they are loaded with COPY
+	chunkSize := bulk.ChunkSize(ext.env.CopyThreshold, len(response.Result.Transactions), ext.env.TxChunkSize)
+	chunksCount := int(math.Ceil(float64(len(response.Result.Transactions)) / float64(chunkSize)))
 	for i := 0; i < chunksCount; i++ {
-		start := ext.env.TxChunkSize * i
-		end := start + ext.env.TxChunkSize
+		start := chunkSize * i
+		end := start + chunkSize
 		if end > len(response.Result.Transactions) {
 			end = len(response.Result.Transactions)
 		}
-		ext.saveTransactions(height, response.Result.Time, response.Result.Transactions[start:end])
+		txs, err := ext.transactionService.HandleTransactionsFromBlockResponse(tx, height, response.Result.Time, response.Result.Transactions[start:end])
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, txs...)
 	}
+	return transactions, nil
 }
 
-func (ext *Extender) handleEventResponse(blockHeight uint64, response *responses.EventsResponse) {
+func (ext *Extender) handleEventResponse(tx *pg.Tx, blockHeight uint64, response *responses.EventsResponse,
+	replace bool) (map[string]struct{}, error) {
 	if len(response.Result.Events) > 0 {
 		//Save events
-		err := ext.eventService.HandleEventResponse(blockHeight, response)
-		if err != nil {
-			ext.logger.Error(err)
-		}
-		helpers.HandleError(err)
+		return ext.eventService.HandleEventResponse(tx, blockHeight, response, replace)
 	}
+	return nil, nil
 }
 
-func (ext *Extender) linkBlockValidator(response responses.BlockResponse) {
+func (ext *Extender) linkBlockValidator(tx *pg.Tx, response *responses.BlockResponse) error {
 	if response.Result.Height == "1" {
-		return
+		return nil
 	}
 	var links []*models.BlockValidator
 	height, err := strconv.ParseUint(response.Result.Height, 10, 64)
 	if err != nil {
-		ext.logger.Error(err)
+		return err
 	}
-	helpers.HandleError(err)
 	for _, v := range response.Result.Validators {
 		vId, err := ext.validatorRepository.FindIdByPk(helpers.RemovePrefix(v.PubKey))
 		if err != nil {
-			ext.logger.Error(err)
+			return err
 		}
-		helpers.HandleError(err)
 		link := models.BlockValidator{
 			ValidatorID: vId,
 			BlockID:     height,
@@ -338,20 +750,10 @@ func (ext *Extender) linkBlockValidator(response responses.BlockResponse) {
 		}
 		links = append(links, &link)
 	}
-	err = ext.blockRepository.LinkWithValidators(links)
-	if err != nil {
-		ext.logger.Error(err)
+	if len(links) == 0 {
+		return nil
 	}
-	helpers.HandleError(err)
-}
-
-func (ext *Extender) saveTransactions(blockHeight uint64, blockCreatedAt time.Time, transactions []responses.Transaction) {
-	// Save transactions
-	err := ext.transactionService.HandleTransactionsFromBlockResponse(blockHeight, blockCreatedAt, transactions)
-	if err != nil {
-		ext.logger.Error(err)
-	}
-	helpers.HandleError(err)
+	return ext.blockRepository.WithTx(tx).LinkWithValidators(links)
 }
 
 func (ext *Extender) getNodeLastBlockId() (uint64, error) {
@@ -363,22 +765,16 @@ func (ext *Extender) getNodeLastBlockId() (uint64, error) {
 	return strconv.ParseUint(statusResponse.Result.LatestBlockHeight, 10, 64)
 }
 
-func (ext *Extender) findOutChasingMode(height uint64) {
-	var err error
-	if ext.currentNodeHeight == 0 {
-		ext.currentNodeHeight, err = ext.getNodeLastBlockId()
+// Chasing mode is on while the extender is more than ChasingModDiff blocks behind the node.
+// The node height is requested again only when the extender gets close to the known one
+func (ext *Extender) findOutChasingMode(height uint64) error {
+	if ext.currentNodeHeight < height+ChasingModDiff {
+		nodeHeight, err := ext.getNodeLastBlockId()
 		if err != nil {
-			ext.logger.Error(err)
-		}
-		helpers.HandleError(err)
-	}
-	isChasingMode := ext.currentNodeHeight-height > ChasingModDiff
-	if ext.chasingMode && !isChasingMode {
-		ext.currentNodeHeight, err = ext.getNodeLastBlockId()
-		if err != nil {
-			ext.logger.Error(err)
+			return err
 		}
-		helpers.HandleError(err)
-		ext.chasingMode = ext.currentNodeHeight-height > ChasingModDiff
+		ext.currentNodeHeight = nodeHeight
 	}
+	ext.chasingMode = ext.currentNodeHeight > height+ChasingModDiff
+	return nil
 }
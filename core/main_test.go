@@ -0,0 +1,280 @@
+package core
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"github.com/MinterTeam/minter-explorer-extender/env"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	testValidator = "Mp1111111111111111111111111111111111111111111111111111111111111111"
+	testSender    = "Mx2222222222222222222222222222222222222222"
+	testReceiver  = "Mx3333333333333333333333333333333333333333"
+)
+
+// Write the response to the archive layout read by the replay
+func writeArchived(t *testing.T, dir string, height string, name string, value interface{}) {
+	path := filepath.Join(dir, "0", height, name+".json.gz")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zw := gzip.NewWriter(file)
+	if err = json.NewEncoder(zw).Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func archiveTestBlock(t *testing.T, dir string) {
+	signed := true
+	tags := map[string]string{"tx.type": "01"}
+	block := responses.BlockResponse{}
+	block.Result = responses.BlockResult{
+		Hash:        "Mh4444444444444444444444444444444444444444444444444444444444444444",
+		Height:      "2",
+		Time:        time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC),
+		TxCount:     "1",
+		TotalTx:     "1",
+		BlockReward: "333",
+		Size:        "500",
+		Proposer:    testValidator,
+		Validators:  []responses.Validator{{PubKey: testValidator, Signed: &signed}},
+		Transactions: []responses.Transaction{{
+			Hash:     "Mt5555555555555555555555555555555555555555555555555555555555555555",
+			RawTx:    "f8700102",
+			From:     testSender,
+			Nonce:    "1",
+			GasPrice: 1,
+			Type:     models.TxTypeSend,
+			Data:     json.RawMessage(`{"coin":"MNT","to":"` + testReceiver + `","value":"1"}`),
+			Payload:  "cGF5bG9hZA==",
+			Gas:      "10",
+			GasCoin:  "MNT",
+			Tags:     &tags,
+		}},
+	}
+	writeArchived(t, dir, "2", "block", block)
+
+	events := responses.EventsResponse{}
+	events.Result.Events = []responses.Event{{
+		Type: models.RewardEvent,
+		Value: responses.EventValue{
+			Role:            "Validator",
+			Address:         testSender,
+			Amount:          "100",
+			ValidatorPubKey: testValidator,
+		},
+	}}
+	writeArchived(t, dir, "2", "events", events)
+}
+
+func TestSaveBlock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extender-replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archiveTestBlock(t, dir)
+
+	ext := NewExtender(context.Background(), &env.Environment{
+		ExtenderEnvironment: models.ExtenderEnvironment{TxChunkSize: 100, EventsChunkSize: 100},
+		ReplayDir:           dir,
+		Storage:             StorageMemory,
+	})
+	err = ext.addressRepository.SaveAllIfNotExist([]string{testSender[2:], testReceiver[2:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ext.coinRepository.Save(&models.Coin{Symbol: "MNT"}); err != nil {
+		t.Fatal(err)
+	}
+
+	blockResponse, err := ext.nodeApi.GetBlock(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventsResponse, err := ext.nodeApi.GetBlockEvents(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	height, block, transactions, err := ext.saveBlock(context.Background(), blockResponse, eventsResponse, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 2 || block == nil || block.ID != 2 {
+		t.Fatalf("saveBlock() = %d, %v, want block 2", height, block)
+	}
+	if len(transactions) != 1 || transactions[0].ID == 0 {
+		t.Fatalf("saveBlock() transactions = %v, want one saved transaction", transactions)
+	}
+
+	// Deleting the block returns the count of every saved row
+	deleted, err := ext.blockRepository.DeleteAfterHeight(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, d := range deleted {
+		counts[d.Table] = d.Count
+	}
+	want := map[string]int{
+		"blocks":              1,
+		"block_validator":     1,
+		"transactions":        1,
+		"transaction_outputs": 1,
+		"rewards":             1,
+		"slashes":             0,
+		"quarantine":          0,
+	}
+	for table, count := range want {
+		if counts[table] != count {
+			t.Errorf("block saved %d rows of %s, want %d", counts[table], table, count)
+		}
+	}
+}
+
+func TestSaveBlockReplaceKeepsCoins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extender-replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ext := NewExtender(context.Background(), &env.Environment{
+		ExtenderEnvironment: models.ExtenderEnvironment{TxChunkSize: 100, EventsChunkSize: 100},
+		ReplayDir:           dir,
+		Storage:             StorageMemory,
+	})
+	if err = ext.coinRepository.Save(&models.Coin{Symbol: "TEST"}); err != nil {
+		t.Fatal(err)
+	}
+
+	blockResponse := &responses.BlockResponse{}
+	blockResponse.Result = responses.BlockResult{
+		Hash:    "Mh6666666666666666666666666666666666666666666666666666666666666666",
+		Height:  "3",
+		Time:    time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC),
+		TxCount: "0",
+		TotalTx: "0",
+		Size:    "100",
+	}
+	eventsResponse := &responses.EventsResponse{}
+	eventsResponse.Result.Events = []responses.Event{{
+		Type:  "minter/CoinLiquidationEvent",
+		Value: responses.EventValue{Coin: "TEST"},
+	}}
+
+	// A backfilled height is in the past, the coin liquidated there exists again at the head
+	if _, _, _, err = ext.saveBlock(context.Background(), blockResponse, eventsResponse, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ext.coinRepository.FindIdBySymbol("TEST"); err != nil {
+		t.Errorf("coin of a replaced height is liquidated: %v", err)
+	}
+
+	// The same event liquidates the coin at the head
+	blockResponse.Result.Height = "4"
+	if _, _, _, err = ext.saveBlock(context.Background(), blockResponse, eventsResponse, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ext.coinRepository.FindIdBySymbol("TEST"); err == nil {
+		t.Error("coin is not liquidated at the head")
+	}
+}
+
+func TestRunReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extender-replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first := responses.BlockResponse{}
+	first.Result = responses.BlockResult{
+		Hash:    "Mh7777777777777777777777777777777777777777777777777777777777777777",
+		Height:  "1",
+		Time:    time.Date(2019, 6, 1, 11, 59, 55, 0, time.UTC),
+		TxCount: "0",
+		TotalTx: "0",
+		Size:    "100",
+	}
+	writeArchived(t, dir, "1", "block", first)
+	writeArchived(t, dir, "1", "events", responses.EventsResponse{})
+	archiveTestBlock(t, dir)
+
+	ext := NewExtender(context.Background(), &env.Environment{
+		ExtenderEnvironment: models.ExtenderEnvironment{TxChunkSize: 100, EventsChunkSize: 100, WrkSaveAddressesCount: 1},
+		Roles:               []string{RoleIngest},
+		LeaderLeaseSec:      30,
+		PartitionSize:       1000,
+		PartitionsAhead:     2,
+		ReplayDir:           dir,
+		Storage:             StorageMemory,
+	})
+	// Created by the genesis on a real chain
+	if err = ext.coinRepository.Save(&models.Coin{Symbol: "MNT"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- ext.Run(ctx)
+	}()
+
+	// The replay has no height after the archived ones, so the extender waits for it
+	deadline := time.After(10 * time.Second)
+	for {
+		last, _ := ext.blockRepository.GetLastFromDB()
+		if last != nil && last.ID == 2 {
+			break
+		}
+		select {
+		case err = <-done:
+			t.Fatalf("Run() = %v before the archive is indexed", err)
+		case <-deadline:
+			t.Fatal("the archive is not indexed in time")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	if err = <-done; err != nil {
+		t.Fatalf("Run() = %v, want nil after the context is cancelled", err)
+	}
+
+	deleted, err := ext.blockRepository.DeleteAfterHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, d := range deleted {
+		counts[d.Table] = d.Count
+	}
+	want := map[string]int{
+		"blocks":          2,
+		"block_validator": 1,
+		"transactions":    1,
+		"rewards":         1,
+	}
+	for table, count := range want {
+		if counts[table] != count {
+			t.Errorf("replay saved %d rows of %s, want %d", counts[table], table, count)
+		}
+	}
+}
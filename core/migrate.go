@@ -0,0 +1,32 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Apply (up), revert the last (down) or list (status) schema migrations
+func (ext *Extender) Migrate(command string) error {
+	if ext.migrationService == nil {
+		return errors.New("the memory storage has no schema to migrate")
+	}
+	switch command {
+	case "up":
+		return ext.migrationService.Up()
+	case "down":
+		return ext.migrationService.Down()
+	case "status":
+		// Printed regardless of the log level
+		return ext.migrationService.Status(os.Stdout)
+	}
+	return fmt.Errorf("unknown migrate command %q, expected up, down or status", command)
+}
+
+// Refuse to run on an outdated schema. The memory storage has no schema
+func (ext *Extender) checkSchema() error {
+	if ext.migrationService == nil {
+		return nil
+	}
+	return ext.migrationService.Check()
+}
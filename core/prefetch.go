@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+)
+
+const defaultPrefetchSize = 20
+
+// Node responses of one height
+type blockData struct {
+	height         uint64
+	blockResponse  *responses.BlockResponse
+	eventsResponse *responses.EventsResponse
+	parentHash     string // hash of the previous height on the node, only if requested
+	err            error
+}
+
+// Fetch block and events responses for the heights in parallel and hand them over strictly in order.
+// With withParentHash the hash of the previous height is fetched too, so the chain continuity can be checked for every block.
+// At most PrefetchSize heights are requested ahead of the one which is being processed.
+// The returned channel is closed after the last height or when the context is done
+func (ext *Extender) prefetchBlocks(ctx context.Context, from, to uint64, withParentHash bool) <-chan *blockData {
+	size := ext.env.PrefetchSize
+	if size <= 0 {
+		size = defaultPrefetchSize
+	}
+
+	queue := make(chan chan *blockData, size)
+	results := make(chan *blockData)
+
+	go func() {
+		defer close(queue)
+		for height := from; height <= to; height++ {
+			result := make(chan *blockData, 1)
+			select {
+			case queue <- result:
+			case <-ctx.Done():
+				return
+			}
+			go func(height uint64) {
+				result <- ext.fetchBlockData(height, withParentHash)
+			}(height)
+		}
+	}()
+
+	go func() {
+		defer close(results)
+		for result := range queue {
+			var data *blockData
+			select {
+			case data = <-result:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case results <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return results
+}
+
+func (ext *Extender) fetchBlockData(height uint64, withParentHash bool) *blockData {
+	data := &blockData{height: height}
+	data.blockResponse, data.err = ext.nodeApi.GetBlock(height)
+	if data.err != nil || data.blockResponse.Error != nil {
+		return data
+	}
+	data.eventsResponse, data.err = ext.nodeApi.GetBlockEvents(height)
+	if data.err != nil || !withParentHash || height == 1 {
+		return data
+	}
+	data.parentHash, data.err = ext.getNodeBlockHash(height - 1)
+	return data
+}
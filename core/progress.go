@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/progress"
+	"github.com/MinterTeam/minter-explorer-extender/queue"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// Stages which run behind the indexed blocks. A stage without a cursor was never run
+// by this version and is left to catch up with the next blocks
+var laggingStages = []string{
+	progress.StageBalances,
+	progress.StageValidators,
+	progress.StageStakes,
+	progress.StageRewardsAggregation,
+}
+
+// Queue the work which was not completed before the last stop.
+// Must be called after the workers are started
+func (ext *Extender) resumeStages(ctx context.Context, head uint64) {
+	cursors, err := ext.progressService.GetAll()
+	if err != nil {
+		ext.logger.Error(err)
+		return
+	}
+
+	for _, stage := range laggingStages {
+		cursor, ok := cursors[stage]
+		if !ok || cursor >= head || !ext.hasRole(stageRoles[stage]) {
+			continue
+		}
+		ext.logger.WithFields(logrus.Fields{
+			"stage":  stage,
+			"height": cursor,
+			"head":   head,
+		}).Warn("Resuming stage behind the indexed blocks")
+
+		switch stage {
+		case progress.StageBalances:
+			addresses, err := ext.addressRepository.FindAllChangedInBlocks(cursor, head)
+			if err != nil {
+				ext.logger.Error(err)
+				continue
+			}
+			ext.balanceService.GetAddressesChannel() <- models.BlockAddresses{Height: head, Addresses: addresses}
+		case progress.StageValidators:
+			ext.validatorService.GetUpdateValidatorsJobChannel() <- head
+		case progress.StageStakes:
+			if err = ext.queueStakesUpdate(ctx, head); err != nil {
+				ext.logger.Error(err)
+			}
+		case progress.StageRewardsAggregation:
+			ext.scheduler.Trigger(jobRewardsAggregation, head)
+		}
+	}
+}
+
+// Log the height of every stage, how far it is behind the indexed blocks, the count of queued refresh jobs
+// and the last runs of scheduled jobs
+func (ext *Extender) Status() error {
+	cursors, err := ext.progressService.GetAll()
+	if err != nil {
+		return err
+	}
+	head := cursors[progress.StageBlocks]
+
+	stages := make([]string, 0, len(cursors))
+	for stage := range cursors {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+
+	// The report is printed regardless of the log level
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tHEIGHT\tLAG")
+	for _, stage := range stages {
+		var lag uint64
+		if cursors[stage] < head {
+			lag = head - cursors[stage]
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\n", stage, cursors[stage], lag)
+	}
+
+	fmt.Fprintln(w, "\nQUEUE\tJOBS")
+	for _, kind := range []string{queue.KindBalance, queue.KindStakes} {
+		count, err := ext.queueRepository.Count(kind)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%d\n", kind, count)
+	}
+
+	err = ext.printJobRuns(w)
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/node"
+	"github.com/MinterTeam/minter-explorer-extender/quarantine"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"github.com/go-pg/pg"
+)
+
+// Handle quarantined items again with the current code, e.g. after a fix is deployed.
+// Resolved items are saved to their blocks, failed ones stay in the quarantine with the new error
+func (ext *Extender) RetryQuarantined(stage string) error {
+	resolved, failed, err := ext.quarantineService.Retry(ext.db, stage, ext.handleQuarantined)
+	if err != nil {
+		return err
+	}
+	ext.logger.Warnf("Quarantine retried: %d resolved, %d failed", resolved, failed)
+	return nil
+}
+
+func (ext *Extender) handleQuarantined(tx *pg.Tx, item *quarantine.Item) error {
+	switch item.Stage {
+	case quarantine.StageTransaction:
+		block, err := ext.blockRepository.GetById(item.BlockID)
+		if err != nil {
+			return fmt.Errorf("block %d is not indexed: %s", item.BlockID, err)
+		}
+		transactions := make([]responses.Transaction, 1)
+		if err = json.Unmarshal([]byte(item.Payload), &transactions[0]); err != nil {
+			return err
+		}
+		if err = node.DecodeTransactionsData(transactions); err != nil {
+			return err
+		}
+		return ext.transactionService.SaveQuarantined(tx, item.BlockID, block.CreatedAt, transactions[0])
+	case quarantine.StageEvent:
+		var event responses.Event
+		if err := json.Unmarshal([]byte(item.Payload), &event); err != nil {
+			return err
+		}
+		return ext.eventService.SaveQuarantined(tx, item.BlockID, event)
+	}
+	return fmt.Errorf("unknown quarantine stage %q", item.Stage)
+}
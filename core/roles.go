@@ -0,0 +1,158 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/progress"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"time"
+)
+
+// Parts of the extender which can run in separate instances. Instances share the database,
+// the ingest instance stores the blocks cursor and the others follow it
+const (
+	RoleIngest     = "ingest"     // blocks, transactions, events, addresses and coins
+	RoleBalances   = "balances"   // balances of changed addresses
+	RoleValidators = "validators" // validators and stakes
+	RoleAggregator = "aggregator" // rewards aggregation and the transactions index
+)
+
+var allRoles = []string{RoleIngest, RoleBalances, RoleValidators, RoleAggregator}
+
+// Role which runs the stage
+var stageRoles = map[string]string{
+	progress.StageBalances:           RoleBalances,
+	progress.StageValidators:         RoleValidators,
+	progress.StageStakes:             RoleValidators,
+	progress.StageRewardsAggregation: RoleAggregator,
+	progress.StageTxsIndex:           RoleAggregator,
+}
+
+// How often instances without the ingest role check the blocks cursor
+const followInterval = 2 * time.Second
+
+// Every 12th block stakes are updated instead of validators
+const stakesEveryBlocksCount = 12
+
+func (ext *Extender) hasRole(role string) bool {
+	return hasRole(ext.env.Roles, role)
+}
+
+// Without configured roles the instance runs all of them
+func hasRole(roles []string, role string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (ext *Extender) checkRoles() error {
+	for _, role := range ext.env.Roles {
+		known := false
+		for _, r := range allRoles {
+			known = known || r == role
+		}
+		if !known {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+	return nil
+}
+
+// Run the roles of an instance without the ingest role until the context is done
+func (ext *Extender) runFollower(ctx context.Context) error {
+	ext.runWorkers(ctx)
+	ext.logger.WithField("roles", ext.env.Roles).Info("Following indexed blocks")
+
+	// Last heights queued by this instance, stages complete their cursors asynchronously
+	queued := make(map[string]uint64)
+	for {
+		select {
+		case <-ctx.Done():
+			ext.shutdown()
+			ext.logger.Warn("Extender stopped")
+			return nil
+		case err := <-ext.balanceService.GetErrorsChannel():
+			ext.logger.Error(err)
+			ext.shutdown()
+			return err
+		case <-time.After(followInterval):
+		}
+
+		cursors, err := ext.progressService.GetAll()
+		if err != nil {
+			ext.logger.Error(err)
+			continue
+		}
+		head, ok := cursors[progress.StageBlocks]
+		if !ok {
+			continue
+		}
+		if ext.hasRole(RoleBalances) {
+			ext.followBalances(head, cursors, queued)
+		}
+		if ext.hasRole(RoleValidators) {
+			ext.followValidators(ctx, head, cursors, queued)
+		}
+		if ext.hasRole(RoleAggregator) {
+			ext.followAggregation(head, cursors)
+		}
+	}
+}
+
+// Queue balances of addresses changed after the balances cursor. Without the cursor the stage starts from the head
+func (ext *Extender) followBalances(head uint64, cursors map[string]uint64, queued map[string]uint64) {
+	cursor, ok := cursors[progress.StageBalances]
+	if !ok {
+		ext.progressService.Complete(progress.StageBalances, head)
+		return
+	}
+	if queued[progress.StageBalances] > cursor {
+		cursor = queued[progress.StageBalances]
+	}
+	if cursor >= head {
+		return
+	}
+	addresses, err := ext.addressRepository.FindAllChangedInBlocks(cursor, head)
+	if err != nil {
+		ext.logger.Error(err)
+		return
+	}
+	ext.balanceService.GetAddressesChannel() <- models.BlockAddresses{Height: head, Addresses: addresses}
+	queued[progress.StageBalances] = head
+}
+
+// Update validators on every new head and stakes when the head passes a multiple of 12 blocks
+func (ext *Extender) followValidators(ctx context.Context, head uint64, cursors map[string]uint64, queued map[string]uint64) {
+	last := queued[progress.StageValidators]
+	if last == 0 {
+		last = cursors[progress.StageValidators]
+	}
+	if last >= head {
+		return
+	}
+	if head/stakesEveryBlocksCount > last/stakesEveryBlocksCount {
+		if err := ext.queueStakesUpdate(ctx, head); err != nil {
+			ext.logger.Error(err)
+			return
+		}
+	} else if head > 1 {
+		ext.validatorService.GetUpdateValidatorsJobChannel() <- head
+	}
+	queued[progress.StageValidators] = head
+}
+
+// Aggregate rewards when the head passes a multiple of RewardAggregateEveryBlocksCount blocks
+func (ext *Extender) followAggregation(head uint64, cursors map[string]uint64) {
+	every := uint64(ext.env.RewardAggregateEveryBlocksCount)
+	cursor, ok := cursors[progress.StageRewardsAggregation]
+	if ok && head/every <= cursor/every {
+		return
+	}
+	ext.scheduler.Trigger(jobRewardsAggregation, head)
+}
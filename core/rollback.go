@@ -0,0 +1,56 @@
+package core
+
+import (
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/block"
+	"github.com/go-pg/pg"
+	"github.com/sirupsen/logrus"
+)
+
+// Remove everything above the height from all block related tables in one transaction.
+// Balances, validators and stakes are head state and will be refreshed by the next indexed blocks
+func (ext *Extender) Rollback(height uint64) error {
+	head, err := ext.blockRepository.GetLastFromDB()
+	if err != nil || head == nil {
+		return fmt.Errorf("there are no indexed blocks")
+	}
+	if height >= head.ID {
+		return fmt.Errorf("nothing to roll back: the indexed head is %d", head.ID)
+	}
+	// A running leader would index the removed heights again
+	err = ext.leaderService.TryAcquire()
+	if err != nil {
+		return err
+	}
+	defer ext.leaderService.Release()
+	return ext.rollback(height)
+}
+
+func (ext *Extender) rollback(height uint64) error {
+	// Rows of detached partitions would stay while their blocks are deleted
+	err := ext.partitionService.CheckAttached(height + 1)
+	if err != nil {
+		ext.logger.Error(err)
+		return err
+	}
+	var deleted []block.DeletedRows
+	err = ext.db.RunInTransaction(func(tx *pg.Tx) error {
+		var err error
+		deleted, err = ext.blockRepository.WithTx(tx).DeleteAfterHeight(height)
+		if err != nil {
+			return err
+		}
+		return ext.progressService.RollbackTo(tx, height)
+	})
+	if err != nil {
+		ext.logger.Error(err)
+		return err
+	}
+
+	fields := logrus.Fields{"height": height}
+	for _, d := range deleted {
+		fields[d.Table] = d.Count
+	}
+	ext.logger.WithFields(fields).Warn("Database rolled back")
+	return nil
+}
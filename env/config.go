@@ -11,6 +11,7 @@ type Config interface {
 	GetString(key string) string
 	GetInt(key string) int
 	GetBool(key string) bool
+	GetStringSlice(key string) []string
 	Init(configPath string)
 }
 
@@ -59,3 +60,7 @@ func (v *viperConfig) GetInt(key string) int {
 func (v *viperConfig) GetBool(key string) bool {
 	return viper.GetBool(key)
 }
+
+func (v *viperConfig) GetStringSlice(key string) []string {
+	return viper.GetStringSlice(key)
+}
@@ -4,9 +4,56 @@ import (
 	"flag"
 	"github.com/MinterTeam/minter-explorer-tools/models"
 	"os"
+	"strconv"
+	"strings"
 )
 
-func New() *models.ExtenderEnvironment {
+// Blocks can not be saved without a partition for the next height, so at least one is always kept ahead
+const defaultPartitionsAhead = 2
+
+// Extender settings. Common settings are shared with other explorer services through models.ExtenderEnvironment
+type Environment struct {
+	models.ExtenderEnvironment
+	OnFork       string   // "stop" or "rollback"
+	PrefetchSize int      // count of heights fetched ahead in chasing mode
+	GenesisFile  string   // genesis JSON imported when the database is empty
+	NodeApiLinks []string // all node API links, NodeApi is the first one
+
+	RetryMaxAttempts       int // attempts of a failed node request or database operation
+	RetryInitialIntervalMs int // delay before the first retry, doubled for each next one
+	RetryMaxIntervalMs     int // limit of the delay between retries
+
+	RecordDir string // archive directory for node responses
+	ReplayDir string // archive directory used instead of the node
+
+	NodeWsLink string // Tendermint RPC websocket for new block notifications
+
+	DryRun       bool // parse blocks after the indexed head without writing anything
+	DryRunBlocks int  // count of blocks checked by the dry run
+
+	Roles []string // parts of the extender run by the instance, all if empty
+
+	LeaderLeaseSec int // lifetime of the leader lease, a standby instance takes over when it expires
+
+	PartitionSize    int // heights in one partition of tables partitioned by block_id
+	PartitionsAhead  int // count of partitions created above the indexed head
+	PartitionsRetain int // count of partitions kept attached below the head, older ones are detached. All if 0
+
+	Storage string // "postgres" or "memory", which keeps all data in the process for tests and demo deployments
+
+	DbHost               string // host name or address, or the directory of the Unix socket if it starts with a slash
+	DbPort               int
+	DbSslMode            string // disable, require, verify-ca or verify-full
+	DbSslRootCert        string // CA certificate the server certificate is verified with
+	DbSslCert            string // client certificate
+	DbSslKey             string // key of the client certificate
+	DbStatementTimeoutMs int    // statements running longer are cancelled by the server, no limit if 0
+	DbDialTimeoutMs      int    // timeout of establishing a connection
+
+	CopyThreshold int // batches of rewards, transactions and links of at least this size are loaded with COPY, never if negative
+}
+
+func New() *Environment {
 	appName := flag.String("app_name", "Minter Extender", "App name")
 	baseCoin := flag.String("base_coin", "MNT", "Base coin symbol")
 	coinsUpdateTime := flag.Int("coins_upd_time", 3600, "Coins update time in minutes")
@@ -14,8 +61,17 @@ func New() *models.ExtenderEnvironment {
 	dbName := flag.String("db_name", "", "DB name")
 	dbUser := flag.String("db_user", "", "DB user")
 	dbPassword := flag.String("db_password", "", "DB password")
-	dbMinIdleConns := flag.Int("db_min_idle_conns", 10, "DB min idle connections")
-	dbPoolSize := flag.Int("db_pool_size", 20, "DB pool size")
+	// Defaults of database flags are applied after EXPLORER_DB_* environment variables, so they are not set here
+	dbMinIdleConns := flag.Int("db_min_idle_conns", 0, "DB min idle connections (default 10)")
+	dbPoolSize := flag.Int("db_pool_size", 0, "DB pool size (default 20)")
+	dbHost := flag.String("db_host", "", "DB host, or the directory of the Unix socket if it starts with a slash (default \"localhost\")")
+	dbPort := flag.Int("db_port", 0, "DB port (default 5432)")
+	dbSslMode := flag.String("db_sslmode", "", "DB SSL mode('disable', 'require', 'verify-ca' or 'verify-full') (default \"disable\")")
+	dbSslRootCert := flag.String("db_sslrootcert", "", "CA certificate file the DB server certificate is verified with")
+	dbSslCert := flag.String("db_sslcert", "", "DB client certificate file")
+	dbSslKey := flag.String("db_sslkey", "", "DB client certificate key file")
+	dbStatementTimeout := flag.Int("db_statement_timeout_ms", 0, "Milliseconds after which the DB server cancels a statement, no limit if 0")
+	dbDialTimeout := flag.Int("db_dial_timeout_ms", 0, "Timeout in milliseconds of establishing a DB connection (default 5000)")
 	nodeApi := flag.String("node_api", "", "DB password")
 	txChunkSize := flag.Int("tx_chunk_size", 100, "Transactions chunk size")
 	eventsChunkSize := flag.Int("event_chunk_size", 100, "Events chunk size")
@@ -25,13 +81,7 @@ func New() *models.ExtenderEnvironment {
 	apiPort := flag.Int("api_port", 8000, "API port")
 	wsLink := flag.String("ws_link", "", "WebSocket server link")
 	wsKey := flag.String("ws_key", "", "WebSocket API key")
-	wrkSaveTxsCount := flag.Int("wrk_save_txs_count", 3, "Count of workers that save transactions")
-	wrkSaveTxsOutputCount := flag.Int("wrk_save_txs_output_count", 3, "Count of workers that save transactions output")
-	wrkSaveInvalidTxsCount := flag.Int("wrk_save_invtxs_count", 3, "Count of workers that save invalid transactions")
-	wrkSaveRewardsCount := flag.Int("wrk_save_rewards_count", 3, "Count of workers that save rewards")
-	wrkSaveSlashesCount := flag.Int("wrk_save_slashes_count", 3, "Count of workers that save slashes")
 	wrkSaveAddressesCount := flag.Int("wrk_save_addresses_count", 3, "Count of workers that save addresses")
-	wrkSaveValidatorTxsCount := flag.Int("wrk_save_val_tx_count", 3, "Count of workers that save transaction-validator link")
 	addrChunkSize := flag.Int("addr_chunk_size", 10, "Count of workers that save transaction-validator link")
 	wrkUpdateBalanceCount := flag.Int("wrk_upd_balances_count", 1, "Count of workers that update balance")
 	wrkGetBalancesFromNodeCount := flag.Int("wrk_node_balance_count", 1, "Count of workers that get balance from node")
@@ -39,26 +89,28 @@ func New() *models.ExtenderEnvironment {
 	wrkUpdateTxsIndexTime := flag.Int("wrk_update_txs_index_time", 60, "Time in seconds which worker sleep before the next iteration")
 	rewardAggregateEveryBlocksCount := flag.Int("reward_aggregate_every_blocks_count", 60, "Every X block will be launched reward aggregation")
 	rewardAggregateTimeInterval := flag.String("reward_aggregate_time_interval", "hour", "Rewards aggregation time interval('hour' or 'day')")
+	prefetchSize := flag.Int("prefetch_size", 20, "Count of blocks fetched ahead in parallel while chasing the node")
+	onFork := flag.String("on_fork", "stop", "Action on chain fork detection('stop' or 'rollback')")
+	genesisFile := flag.String("genesis_file", "", "Genesis file imported when the database is empty")
+	nodeApis := flag.String("node_apis", "", "Comma separated links of additional nodes")
+	retryMaxAttempts := flag.Int("retry_max_attempts", 5, "Attempts of a failed node request or database operation")
+	retryInitialInterval := flag.Int("retry_initial_interval_ms", 500, "Delay in milliseconds before the first retry")
+	retryMaxInterval := flag.Int("retry_max_interval_ms", 30000, "Max delay in milliseconds between retries")
+	recordDir := flag.String("record_dir", "", "Directory where node responses are recorded")
+	replayDir := flag.String("replay_dir", "", "Directory with recorded node responses which are used instead of the node")
+	nodeWsLink := flag.String("node_ws_link", "", "Tendermint RPC websocket link for new block notifications")
+	dryRun := flag.Bool("dry-run", false, "Parse blocks after the indexed head and report changes without writing them")
+	dryRunBlocks := flag.Int("dry-run-blocks", 100, "Count of blocks checked by the dry run")
+	roles := flag.String("roles", "", "Comma separated roles of the instance(ingest, balances, validators, aggregator), all if empty")
+	leaderLease := flag.Int("leader_lease_sec", 15, "Seconds after which a standby instance takes over from a leader which stopped renewing the lease")
+	partitionSize := flag.Int("partition_size", 1000000, "Count of heights in one partition of tables partitioned by block_id")
+	partitionsAhead := flag.Int("partitions_ahead", defaultPartitionsAhead, "Count of partitions created above the indexed head")
+	partitionsRetain := flag.Int("partitions_retain", 0, "Count of partitions kept attached below the indexed head, older ones are detached. All if 0")
+	copyThreshold := flag.Int("copy_threshold", 500, "Count of rows from which a batch is loaded with COPY instead of INSERT, never if negative")
+	storage := flag.String("storage", "postgres", "Storage of indexed data('postgres' or 'memory', which is lost on exit)")
 	flag.Parse()
 
-	envData := new(models.ExtenderEnvironment)
-
-	if envData.DbUser == "" {
-		dbUser := os.Getenv("EXPLORER_DB_USER")
-		envData.DbUser = dbUser
-	}
-	if envData.DbName == "" {
-		dbName := os.Getenv("EXPLORER_DB_NAME")
-		envData.DbName = dbName
-	}
-	if envData.DbPassword == "" {
-		dbPassword := os.Getenv("EXPLORER_DB_PASSWORD")
-		envData.DbPassword = dbPassword
-	}
-	if envData.NodeApi == "" {
-		nodeApi := os.Getenv("MINTER_NODE_API")
-		envData.NodeApi = nodeApi
-	}
+	envData := new(Environment)
 
 	if *configFile != "" {
 		config := NewViperConfig(*configFile)
@@ -81,6 +133,14 @@ func New() *models.ExtenderEnvironment {
 		envData.DbPassword = config.GetString("database.password")
 		envData.DbMinIdleConns = config.GetInt("database.minIdleConns")
 		envData.DbPoolSize = config.GetInt("database.poolSize")
+		envData.DbHost = config.GetString("database.host")
+		envData.DbPort = config.GetInt("database.port")
+		envData.DbSslMode = config.GetString("database.sslMode")
+		envData.DbSslRootCert = config.GetString("database.sslRootCert")
+		envData.DbSslCert = config.GetString("database.sslCert")
+		envData.DbSslKey = config.GetString("database.sslKey")
+		envData.DbStatementTimeoutMs = config.GetInt("database.statementTimeoutMs")
+		envData.DbDialTimeoutMs = config.GetInt("database.dialTimeoutMs")
 		envData.NodeApi = nodeApi
 		envData.TxChunkSize = config.GetInt("app.txChunkSize")
 		envData.AddrChunkSize = config.GetInt("app.addrChunkSize")
@@ -91,13 +151,7 @@ func New() *models.ExtenderEnvironment {
 		envData.WsLink = wsLink
 		envData.WsKey = config.GetString(`wsServer.key`)
 		envData.AppName = config.GetString("name")
-		envData.WrkSaveTxsCount = config.GetInt("workers.saveTxs")
-		envData.WrkSaveTxsOutputCount = config.GetInt("workers.saveTxsOutput")
-		envData.WrkSaveInvTxsCount = config.GetInt("workers.saveInvalidTxs")
-		envData.WrkSaveRewardsCount = config.GetInt("workers.saveRewards")
-		envData.WrkSaveSlashesCount = config.GetInt("workers.saveSlashes")
 		envData.WrkSaveAddressesCount = config.GetInt("workers.saveAddresses")
-		envData.WrkSaveValidatorTxsCount = config.GetInt("workers.saveTxValidator")
 		envData.WrkUpdateBalanceCount = config.GetInt("workers.updateBalance")
 		envData.WrkGetBalancesFromNodeCount = config.GetInt("workers.balancesFromNode")
 		envData.RewardAggregateEveryBlocksCount = config.GetInt("app.rewardsAggregateBlocksCount")
@@ -106,6 +160,23 @@ func New() *models.ExtenderEnvironment {
 		envData.CoinsUpdateTime = config.GetInt("app.coinsUpdateTimeMinutes")
 		envData.WrkUpdateTxsIndexNumBlocks = config.GetInt("workers.updateTxsIndexNumBlocks")
 		envData.WrkUpdateTxsIndexTime = config.GetInt("workers.updateTxsIndexSleepSec")
+		envData.OnFork = config.GetString("app.onFork")
+		envData.PrefetchSize = config.GetInt("app.prefetchSize")
+		envData.GenesisFile = config.GetString("app.genesisFile")
+		envData.NodeApiLinks = append([]string{nodeApi}, config.GetStringSlice("minterApi.nodes")...)
+		envData.RetryMaxAttempts = config.GetInt("retry.maxAttempts")
+		envData.RetryInitialIntervalMs = config.GetInt("retry.initialIntervalMs")
+		envData.RetryMaxIntervalMs = config.GetInt("retry.maxIntervalMs")
+		envData.RecordDir = config.GetString("app.recordDir")
+		envData.ReplayDir = config.GetString("app.replayDir")
+		envData.NodeWsLink = config.GetString("minterApi.wsLink")
+		envData.Roles = config.GetStringSlice("app.roles")
+		envData.LeaderLeaseSec = config.GetInt("app.leaderLeaseSec")
+		envData.PartitionSize = config.GetInt("app.partitionSize")
+		envData.PartitionsAhead = config.GetInt("app.partitionsAhead")
+		envData.PartitionsRetain = config.GetInt("app.partitionsRetain")
+		envData.CopyThreshold = config.GetInt("app.copyThreshold")
+		envData.Storage = config.GetString("database.storage")
 	} else {
 		envData.AppName = *appName
 		envData.Debug = *debug
@@ -114,6 +185,14 @@ func New() *models.ExtenderEnvironment {
 		envData.DbPassword = *dbPassword
 		envData.DbMinIdleConns = *dbMinIdleConns
 		envData.DbPoolSize = *dbPoolSize
+		envData.DbHost = *dbHost
+		envData.DbPort = *dbPort
+		envData.DbSslMode = *dbSslMode
+		envData.DbSslRootCert = *dbSslRootCert
+		envData.DbSslCert = *dbSslCert
+		envData.DbSslKey = *dbSslKey
+		envData.DbStatementTimeoutMs = *dbStatementTimeout
+		envData.DbDialTimeoutMs = *dbDialTimeout
 		envData.NodeApi = *nodeApi
 		envData.TxChunkSize = *txChunkSize
 		envData.EventsChunkSize = *eventsChunkSize
@@ -122,13 +201,7 @@ func New() *models.ExtenderEnvironment {
 		envData.ApiPort = *apiPort
 		envData.WsLink = *wsLink
 		envData.WsKey = *wsKey
-		envData.WrkSaveTxsCount = *wrkSaveTxsCount
-		envData.WrkSaveTxsOutputCount = *wrkSaveTxsOutputCount
-		envData.WrkSaveInvTxsCount = *wrkSaveInvalidTxsCount
-		envData.WrkSaveRewardsCount = *wrkSaveRewardsCount
-		envData.WrkSaveSlashesCount = *wrkSaveSlashesCount
 		envData.WrkSaveAddressesCount = *wrkSaveAddressesCount
-		envData.WrkSaveValidatorTxsCount = *wrkSaveValidatorTxsCount
 		envData.AddrChunkSize = *addrChunkSize
 		envData.WrkUpdateBalanceCount = *wrkUpdateBalanceCount
 		envData.WrkGetBalancesFromNodeCount = *wrkGetBalancesFromNodeCount
@@ -138,6 +211,107 @@ func New() *models.ExtenderEnvironment {
 		envData.WrkUpdateTxsIndexTime = *wrkUpdateTxsIndexTime
 		envData.RewardAggregateEveryBlocksCount = *rewardAggregateEveryBlocksCount
 		envData.RewardAggregateTimeInterval = *rewardAggregateTimeInterval
+		envData.OnFork = *onFork
+		envData.PrefetchSize = *prefetchSize
+		envData.GenesisFile = *genesisFile
+		envData.NodeApiLinks = append([]string{envData.NodeApi}, strings.Split(*nodeApis, ",")...)
+		envData.RetryMaxAttempts = *retryMaxAttempts
+		envData.RetryInitialIntervalMs = *retryInitialInterval
+		envData.RetryMaxIntervalMs = *retryMaxInterval
+		envData.RecordDir = *recordDir
+		envData.ReplayDir = *replayDir
+		envData.NodeWsLink = *nodeWsLink
+		envData.Roles = strings.Split(*roles, ",")
+		envData.LeaderLeaseSec = *leaderLease
+		envData.PartitionSize = *partitionSize
+		envData.PartitionsAhead = *partitionsAhead
+		envData.PartitionsRetain = *partitionsRetain
+		envData.CopyThreshold = *copyThreshold
+		envData.Storage = *storage
+	}
+	// Settings missing in the config or flags are taken from the environment
+	stringsFromEnv := map[*string]string{
+		&envData.DbUser:        "EXPLORER_DB_USER",
+		&envData.DbName:        "EXPLORER_DB_NAME",
+		&envData.DbPassword:    "EXPLORER_DB_PASSWORD",
+		&envData.DbHost:        "EXPLORER_DB_HOST",
+		&envData.DbSslMode:     "EXPLORER_DB_SSLMODE",
+		&envData.DbSslRootCert: "EXPLORER_DB_SSLROOTCERT",
+		&envData.DbSslCert:     "EXPLORER_DB_SSLCERT",
+		&envData.DbSslKey:      "EXPLORER_DB_SSLKEY",
+		&envData.NodeApi:       "MINTER_NODE_API",
+	}
+	for value, name := range stringsFromEnv {
+		if *value == "" {
+			*value = os.Getenv(name)
+		}
+	}
+	intsFromEnv := map[*int]string{
+		&envData.DbPort:               "EXPLORER_DB_PORT",
+		&envData.DbPoolSize:           "EXPLORER_DB_POOL_SIZE",
+		&envData.DbMinIdleConns:       "EXPLORER_DB_MIN_IDLE_CONNS",
+		&envData.DbStatementTimeoutMs: "EXPLORER_DB_STATEMENT_TIMEOUT_MS",
+		&envData.DbDialTimeoutMs:      "EXPLORER_DB_DIAL_TIMEOUT_MS",
+	}
+	for value, name := range intsFromEnv {
+		if *value == 0 {
+			*value, _ = strconv.Atoi(os.Getenv(name))
+		}
+	}
+	if envData.DbHost == "" {
+		envData.DbHost = "localhost"
+	}
+	if envData.DbPort <= 0 {
+		envData.DbPort = 5432
+	}
+	if envData.DbSslMode == "" {
+		envData.DbSslMode = "disable"
+	}
+	if envData.DbPoolSize <= 0 {
+		envData.DbPoolSize = 20
+	}
+	if envData.DbMinIdleConns <= 0 {
+		envData.DbMinIdleConns = 10
+	}
+	if envData.DbDialTimeoutMs <= 0 {
+		envData.DbDialTimeoutMs = 5000
+	}
+	// The node API link is the first one, duplicates are removed below
+	envData.NodeApiLinks = append([]string{envData.NodeApi}, envData.NodeApiLinks...)
+	envData.NodeApiLinks = uniqueValues(envData.NodeApiLinks)
+	envData.Roles = uniqueValues(envData.Roles)
+	envData.DryRun = *dryRun
+	envData.DryRunBlocks = *dryRunBlocks
+	if envData.LeaderLeaseSec <= 0 {
+		envData.LeaderLeaseSec = *leaderLease
+	}
+	if envData.PartitionSize <= 0 {
+		envData.PartitionSize = *partitionSize
+	}
+	if envData.CopyThreshold == 0 {
+		envData.CopyThreshold = *copyThreshold
+	}
+	if envData.PartitionsAhead <= 0 {
+		envData.PartitionsAhead = defaultPartitionsAhead
+	}
+	// Rewards are aggregated every that many blocks, 0 or a negative count of the config falls back to the default
+	if envData.RewardAggregateEveryBlocksCount <= 0 {
+		envData.RewardAggregateEveryBlocksCount = 60
 	}
 	return envData
 }
+
+// Trimmed not empty values without duplicates
+func uniqueValues(values []string) []string {
+	var list []string
+	exists := make(map[string]struct{})
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if _, ok := exists[value]; ok || value == "" {
+			continue
+		}
+		exists[value] = struct{}{}
+		list = append(list, value)
+	}
+	return list
+}
@@ -2,22 +2,52 @@ package events
 
 import (
 	"errors"
+	"github.com/MinterTeam/minter-explorer-extender/bulk"
 	"github.com/MinterTeam/minter-explorer-tools/models"
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"strings"
 )
 
-type Repository struct {
-	db *pg.DB
+// Storage of rewards and slashes
+type Repository interface {
+	WithTx(tx *pg.Tx) Repository
+	SaveRewards(rewards []*models.Reward) error
+	SaveSlashes(slashes []*models.Slash) error
+	AggregateRewards(aggregateInterval string, beforeBlockId uint64) error
 }
 
-func NewRepository(db *pg.DB) *Repository {
-	return &Repository{
-		db: db,
+type pgRepository struct {
+	db            orm.DB
+	copyThreshold int // batches of at least this size are loaded with COPY, never if negative
+}
+
+func NewRepository(db orm.DB, copyThreshold int) Repository {
+	return &pgRepository{
+		db:            db,
+		copyThreshold: copyThreshold,
+	}
+}
+
+// Return a copy of the repository which runs all queries inside the transaction
+func (r *pgRepository) WithTx(tx *pg.Tx) Repository {
+	return &pgRepository{
+		db:            tx,
+		copyThreshold: r.copyThreshold,
 	}
 }
 
-func (r *Repository) SaveRewards(rewards []*models.Reward) error {
+func (r *pgRepository) SaveRewards(rewards []*models.Reward) error {
+	if bulk.Enabled(r.copyThreshold, len(rewards)) {
+		c := bulk.NewCopy("rewards", "block_id", "address_id", "validator_id", "role", "amount")
+		for _, reward := range rewards {
+			err := c.Add(reward.BlockID, reward.AddressID, reward.ValidatorID, reward.Role, reward.Amount)
+			if err != nil {
+				return err
+			}
+		}
+		return c.Exec(r.db)
+	}
 	var args []interface{}
 	for _, reward := range rewards {
 		args = append(args, reward)
@@ -25,7 +55,7 @@ func (r *Repository) SaveRewards(rewards []*models.Reward) error {
 	return r.db.Insert(args...)
 }
 
-func (r *Repository) SaveSlashes(slashes []*models.Slash) error {
+func (r *pgRepository) SaveSlashes(slashes []*models.Slash) error {
 	var args []interface{}
 	for _, slash := range slashes {
 		args = append(args, slash)
@@ -33,7 +63,7 @@ func (r *Repository) SaveSlashes(slashes []*models.Slash) error {
 	return r.db.Insert(args...)
 }
 
-func (r *Repository) AggregateRewards(aggregateInterval string, beforeBlockId uint64) error {
+func (r *pgRepository) AggregateRewards(aggregateInterval string, beforeBlockId uint64) error {
 
 	if strings.Compare(aggregateInterval, "hour") != 0 && strings.Compare(aggregateInterval, "day") != 0 {
 		return errors.New("not acceptable aggregate interval")
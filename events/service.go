@@ -3,47 +3,53 @@ package events
 import (
 	"github.com/MinterTeam/minter-explorer-extender/address"
 	"github.com/MinterTeam/minter-explorer-extender/balance"
+	"github.com/MinterTeam/minter-explorer-extender/bulk"
 	"github.com/MinterTeam/minter-explorer-extender/coin"
+	"github.com/MinterTeam/minter-explorer-extender/quarantine"
 	"github.com/MinterTeam/minter-explorer-extender/validator"
 	"github.com/MinterTeam/minter-explorer-tools/helpers"
 	"github.com/MinterTeam/minter-explorer-tools/models"
 	"github.com/MinterTeam/minter-node-go-api/responses"
+	"github.com/go-pg/pg"
 	"github.com/sirupsen/logrus"
 	"math"
 )
 
 type Service struct {
 	env                 *models.ExtenderEnvironment
-	repository          *Repository
-	validatorRepository *validator.Repository
-	addressRepository   *address.Repository
-	coinRepository      *coin.Repository
+	repository          Repository
+	copyThreshold       int // batches of at least this size are not split into chunks, so they are loaded with COPY
+	validatorRepository validator.Repository
+	addressRepository   address.Repository
+	coinRepository      coin.Repository
 	coinService         *coin.Service
-	balanceRepository   *balance.Repository
-	jobSaveRewards      chan []*models.Reward
-	jobSaveSlashes      chan []*models.Slash
+	balanceRepository   balance.Repository
+	quarantineService   *quarantine.Service
 	logger              *logrus.Entry
 }
 
-func NewService(env *models.ExtenderEnvironment, repository *Repository, validatorRepository *validator.Repository,
-	addressRepository *address.Repository, coinRepository *coin.Repository, coinService *coin.Service,
-	balanceRepository *balance.Repository, logger *logrus.Entry) *Service {
+func NewService(env *models.ExtenderEnvironment, repository Repository, copyThreshold int, validatorRepository validator.Repository,
+	addressRepository address.Repository, coinRepository coin.Repository, coinService *coin.Service,
+	balanceRepository balance.Repository, quarantineService *quarantine.Service, logger *logrus.Entry) *Service {
 	return &Service{
 		env:                 env,
 		repository:          repository,
+		copyThreshold:       copyThreshold,
 		validatorRepository: validatorRepository,
 		addressRepository:   addressRepository,
 		coinRepository:      coinRepository,
 		coinService:         coinService,
 		balanceRepository:   balanceRepository,
-		jobSaveRewards:      make(chan []*models.Reward, env.WrkSaveRewardsCount),
-		jobSaveSlashes:      make(chan []*models.Slash, env.WrkSaveSlashesCount),
+		quarantineService:   quarantineService,
 		logger:              logger,
 	}
 }
 
-//Handle response and save block to DB
-func (s *Service) HandleEventResponse(blockHeight uint64, response *responses.EventsResponse) error {
+//Handle response and save rewards and slashes inside the block transaction.
+//Return coins of slashes, their info should be updated after the transaction is committed.
+//If replace is set the height is saved again over a past one, so the current coins and balances are not changed
+func (s *Service) HandleEventResponse(tx *pg.Tx, blockHeight uint64, response *responses.EventsResponse,
+	replace bool) (map[string]struct{}, error) {
 	var (
 		rewards           []*models.Reward
 		slashes           []*models.Slash
@@ -52,24 +58,15 @@ func (s *Service) HandleEventResponse(blockHeight uint64, response *responses.Ev
 
 	for _, event := range response.Result.Events {
 		if event.Type == "minter/CoinLiquidationEvent" {
-
-			coinId, err := s.coinRepository.FindIdBySymbol(event.Value.Coin)
-
-			err = s.balanceRepository.DeleteByCoinId(coinId)
-
-			if err != nil {
-				s.logger.WithFields(logrus.Fields{
-					"coin": event.Value.Coin,
-				}).Error(err)
-				return err
+			if replace {
+				continue
 			}
-
-			err = s.coinRepository.DeleteBySymbol(event.Value.Coin)
+			err := s.liquidateCoin(tx, event.Value.Coin)
 			if err != nil {
 				s.logger.WithFields(logrus.Fields{
 					"coin": event.Value.Coin,
 				}).Error(err)
-				return err
+				return nil, err
 			}
 			continue
 		}
@@ -77,105 +74,184 @@ func (s *Service) HandleEventResponse(blockHeight uint64, response *responses.Ev
 			continue
 		}
 
-		addressId, err := s.addressRepository.FindId(helpers.RemovePrefix(event.Value.Address))
-		if err != nil {
-			s.logger.WithFields(logrus.Fields{
-				"address": event.Value.Address,
-			}).Error(err)
-			return err
-		}
-
-		validatorId, err := s.validatorRepository.FindIdByPk(helpers.RemovePrefix(event.Value.ValidatorPubKey))
+		reward, slash, err := s.parseEvent(blockHeight, event)
 		if err != nil {
-			s.logger.WithFields(logrus.Fields{
-				"public_key": event.Value.ValidatorPubKey,
-			}).Error(err)
-			return err
-		}
-
-		switch event.Type {
-		case models.RewardEvent:
-			rewards = append(rewards, &models.Reward{
-				BlockID:     blockHeight,
-				Role:        event.Value.Role,
-				Amount:      event.Value.Amount,
-				AddressID:   addressId,
-				ValidatorID: validatorId,
-			})
-
-		case models.SlashEvent:
-			coinsForUpdateMap[event.Value.Coin] = struct{}{}
-			coinId, err := s.coinRepository.FindIdBySymbol(event.Value.Coin)
+			err = s.quarantineService.Add(tx, quarantine.StageEvent, blockHeight, "", event, err)
 			if err != nil {
 				s.logger.Error(err)
-				return err
+				return nil, err
 			}
-
-			slashes = append(slashes, &models.Slash{
-				BlockID:     blockHeight,
-				CoinID:      coinId,
-				Amount:      event.Value.Amount,
-				AddressID:   addressId,
-				ValidatorID: validatorId,
-			})
+			continue
+		}
+		if reward != nil {
+			rewards = append(rewards, reward)
+		}
+		if slash != nil {
+			if !replace {
+				coinsForUpdateMap[event.Value.Coin] = struct{}{}
+			}
+			slashes = append(slashes, slash)
 		}
 	}
 
-	if len(coinsForUpdateMap) > 0 {
-		s.coinService.GetUpdateCoinsFromCoinsMapJobChannel() <- coinsForUpdateMap
-	}
+	repository := s.repository.WithTx(tx)
 
 	if len(rewards) > 0 {
-		s.saveRewards(rewards)
+		err := s.saveRewards(repository, rewards)
+		if err != nil {
+			s.logger.Error(err)
+			return nil, err
+		}
 	}
 
 	if len(slashes) > 0 {
-		s.saveSlashes(slashes)
+		err := s.saveSlashes(repository, slashes)
+		if err != nil {
+			s.logger.Error(err)
+			return nil, err
+		}
 	}
 
-	return nil
+	return coinsForUpdateMap, nil
 }
 
-func (s *Service) GetSaveRewardsJobChannel() chan []*models.Reward {
-	return s.jobSaveRewards
+// Delete the coin with its balances inside the block transaction. A coin which is already deleted is skipped
+func (s *Service) liquidateCoin(tx *pg.Tx, symbol string) error {
+	coinRepository := s.coinRepository.WithTx(tx)
+	coinId, err := coinRepository.FindIdBySymbol(symbol)
+	if err == pg.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = s.balanceRepository.WithTx(tx).DeleteByCoinId(coinId)
+	if err != nil {
+		return err
+	}
+	return coinRepository.DeleteBySymbol(symbol)
 }
 
-func (s *Service) GetSaveSlashesJobChannel() chan []*models.Slash {
-	return s.jobSaveSlashes
+// Rows which would be saved or deleted for events of a block and events which failed to parse
+type ParseResult struct {
+	Rewards         []*models.Reward
+	Slashes         []*models.Slash
+	LiquidatedCoins []string
+	Failed          []error
 }
 
-func (s *Service) SaveRewardsWorker(jobs <-chan []*models.Reward) {
-	for rewards := range jobs {
-		err := s.repository.SaveRewards(rewards)
-		helpers.HandleError(err)
+// Run parsing of the block events without writing anything
+func (s *Service) ParseEvents(blockHeight uint64, response *responses.EventsResponse) *ParseResult {
+	result := new(ParseResult)
+	for _, event := range response.Result.Events {
+		if event.Type == "minter/CoinLiquidationEvent" {
+			result.LiquidatedCoins = append(result.LiquidatedCoins, event.Value.Coin)
+			continue
+		}
+		if event.Type == "minter/UnbondEvent" {
+			continue
+		}
+		reward, slash, err := s.parseEvent(blockHeight, event)
+		if err != nil {
+			result.Failed = append(result.Failed, err)
+			continue
+		}
+		if reward != nil {
+			result.Rewards = append(result.Rewards, reward)
+		}
+		if slash != nil {
+			result.Slashes = append(result.Slashes, slash)
+		}
 	}
+	return result
 }
 
-func (s *Service) SaveSlashesWorker(jobs <-chan []*models.Slash) {
-	for slashes := range jobs {
-		err := s.repository.SaveSlashes(slashes)
-		helpers.HandleError(err)
+// Save the quarantined reward or slash of the block inside the transaction. Parsing errors are returned
+func (s *Service) SaveQuarantined(tx *pg.Tx, blockHeight uint64, event responses.Event) error {
+	reward, slash, err := s.parseEvent(blockHeight, event)
+	if err != nil {
+		return err
+	}
+	repository := s.repository.WithTx(tx)
+	if reward != nil {
+		return s.saveRewards(repository, []*models.Reward{reward})
+	}
+	if slash != nil {
+		return s.saveSlashes(repository, []*models.Slash{slash})
+	}
+	return nil
+}
+
+// Build the reward or the slash of the event. Nothing is written, so a failed event can be skipped
+func (s *Service) parseEvent(blockHeight uint64, event responses.Event) (reward *models.Reward, slash *models.Slash, err error) {
+	defer quarantine.Recover(&err)
+
+	addressId, err := s.addressRepository.FindId(helpers.RemovePrefix(event.Value.Address))
+	if err != nil {
+		s.logger.WithFields(logrus.Fields{
+			"address": event.Value.Address,
+		}).Error(err)
+		return nil, nil, err
+	}
+
+	validatorId, err := s.validatorRepository.FindIdByPk(helpers.RemovePrefix(event.Value.ValidatorPubKey))
+	if err != nil {
+		s.logger.WithFields(logrus.Fields{
+			"public_key": event.Value.ValidatorPubKey,
+		}).Error(err)
+		return nil, nil, err
+	}
+
+	switch event.Type {
+	case models.RewardEvent:
+		reward = &models.Reward{
+			BlockID:     blockHeight,
+			Role:        event.Value.Role,
+			Amount:      event.Value.Amount,
+			AddressID:   addressId,
+			ValidatorID: validatorId,
+		}
+
+	case models.SlashEvent:
+		coinId, err := s.coinRepository.FindIdBySymbol(event.Value.Coin)
+		if err != nil {
+			s.logger.Error(err)
+			return nil, nil, err
+		}
+
+		slash = &models.Slash{
+			BlockID:     blockHeight,
+			CoinID:      coinId,
+			Amount:      event.Value.Amount,
+			AddressID:   addressId,
+			ValidatorID: validatorId,
+		}
 	}
+	return reward, slash, nil
 }
 
-func (s *Service) AggregateRewards(aggregateInterval string, beforeBlockId uint64) {
-	err := s.repository.AggregateRewards(aggregateInterval, beforeBlockId)
-	helpers.HandleError(err)
+func (s *Service) AggregateRewards(aggregateInterval string, beforeBlockId uint64) error {
+	return s.repository.AggregateRewards(aggregateInterval, beforeBlockId)
 }
 
-func (s *Service) saveRewards(rewards []*models.Reward) {
-	chunksCount := int(math.Ceil(float64(len(rewards)) / float64(s.env.EventsChunkSize)))
+func (s *Service) saveRewards(repository Repository, rewards []*models.Reward) error {
+	chunkSize := bulk.ChunkSize(s.copyThreshold, len(rewards), s.env.EventsChunkSize)
+	chunksCount := int(math.Ceil(float64(len(rewards)) / float64(chunkSize)))
 	for i := 0; i < chunksCount; i++ {
-		start := s.env.EventsChunkSize * i
-		end := start + s.env.EventsChunkSize
+		start := chunkSize * i
+		end := start + chunkSize
 		if end > len(rewards) {
 			end = len(rewards)
 		}
-		s.GetSaveRewardsJobChannel() <- rewards[start:end]
+		err := repository.SaveRewards(rewards[start:end])
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (s *Service) saveSlashes(slashes []*models.Slash) {
+func (s *Service) saveSlashes(repository Repository, slashes []*models.Slash) error {
 	chunksCount := int(math.Ceil(float64(len(slashes)) / float64(s.env.EventsChunkSize)))
 	for i := 0; i < chunksCount; i++ {
 		start := s.env.EventsChunkSize * i
@@ -183,6 +259,10 @@ func (s *Service) saveSlashes(slashes []*models.Slash) {
 		if end > len(slashes) {
 			end = len(slashes)
 		}
-		s.GetSaveSlashesJobChannel() <- slashes[start:end]
+		err := repository.SaveSlashes(slashes[start:end])
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"bytes"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/go-pg/pg/orm"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// Database which records the size of every COPY and INSERT instead of running them
+type recordingDB struct {
+	orm.DB
+	copies  []int
+	inserts []int
+}
+
+func (db *recordingDB) CopyFrom(r io.Reader, query interface{}, params ...interface{}) (orm.Result, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	rows := bytes.Count(data, []byte("\n"))
+	db.copies = append(db.copies, rows)
+	return copyResult(rows), nil
+}
+
+func (db *recordingDB) Insert(values ...interface{}) error {
+	db.inserts = append(db.inserts, len(values))
+	return nil
+}
+
+type copyResult int
+
+func (r copyResult) Model() orm.Model  { return nil }
+func (r copyResult) RowsAffected() int { return int(r) }
+func (r copyResult) RowsReturned() int { return 0 }
+
+func newRewards(count int) []*models.Reward {
+	rewards := make([]*models.Reward, count)
+	for i := range rewards {
+		rewards[i] = &models.Reward{BlockID: 10, AddressID: uint64(i + 1), ValidatorID: 1, Role: "Delegator", Amount: "1"}
+	}
+	return rewards
+}
+
+func TestSaveRewardsCopiesLargeBatchAtOnce(t *testing.T) {
+	db := new(recordingDB)
+	s := &Service{env: &models.ExtenderEnvironment{EventsChunkSize: 100}, copyThreshold: 500}
+
+	err := s.saveRewards(NewRepository(db, 500), newRewards(650))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(db.copies, []int{650}) {
+		t.Errorf("copies = %v, want one COPY of 650 rows", db.copies)
+	}
+	if len(db.inserts) != 0 {
+		t.Errorf("inserts = %v, want none", db.inserts)
+	}
+}
+
+func TestSaveRewardsInsertsSmallBatchInChunks(t *testing.T) {
+	db := new(recordingDB)
+	s := &Service{env: &models.ExtenderEnvironment{EventsChunkSize: 100}, copyThreshold: 500}
+
+	err := s.saveRewards(NewRepository(db, 500), newRewards(250))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(db.inserts, []int{100, 100, 50}) {
+		t.Errorf("inserts = %v, want chunks of 100, 100 and 50 rows", db.inserts)
+	}
+	if len(db.copies) != 0 {
+		t.Errorf("copies = %v, want none", db.copies)
+	}
+}
+
+func TestSaveRewardsWithoutCopy(t *testing.T) {
+	db := new(recordingDB)
+	s := &Service{env: &models.ExtenderEnvironment{EventsChunkSize: 100}, copyThreshold: -1}
+
+	err := s.saveRewards(NewRepository(db, -1), newRewards(650))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.inserts) != 7 || len(db.copies) != 0 {
+		t.Errorf("inserts = %v, copies = %v, want 7 inserts and no copies", db.inserts, db.copies)
+	}
+}
@@ -0,0 +1,49 @@
+package genesis
+
+// Genesis file of the Minter network. Only the application state is used
+type Genesis struct {
+	AppState AppState `json:"app_state"`
+}
+
+type AppState struct {
+	StartHeight uint64      `json:"start_height"`
+	Candidates  []Candidate `json:"candidates"`
+	Accounts    []Account   `json:"accounts"`
+	Coins       []Coin      `json:"coins"`
+}
+
+type Candidate struct {
+	RewardAddress  string  `json:"reward_address"`
+	OwnerAddress   string  `json:"owner_address"`
+	TotalBipStake  string  `json:"total_bip_stake"`
+	PubKey         string  `json:"pub_key"`
+	Commission     uint64  `json:"commission"`
+	Stakes         []Stake `json:"stakes"`
+	CreatedAtBlock uint64  `json:"created_at_block"`
+	Status         uint8   `json:"status"`
+}
+
+type Stake struct {
+	Owner    string `json:"owner"`
+	Coin     string `json:"coin"`
+	Value    string `json:"value"`
+	BipValue string `json:"bip_value"`
+}
+
+type Account struct {
+	Address string    `json:"address"`
+	Balance []Balance `json:"balance"`
+}
+
+type Balance struct {
+	Coin  string `json:"coin"`
+	Value string `json:"value"`
+}
+
+type Coin struct {
+	Name           string `json:"name"`
+	Symbol         string `json:"symbol"`
+	Volume         string `json:"volume"`
+	Crr            uint64 `json:"crr"`
+	ReserveBalance string `json:"reserve_balance"`
+}
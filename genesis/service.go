@@ -0,0 +1,258 @@
+package genesis
+
+import (
+	"encoding/json"
+	"github.com/MinterTeam/minter-explorer-extender/address"
+	"github.com/MinterTeam/minter-explorer-extender/balance"
+	"github.com/MinterTeam/minter-explorer-extender/coin"
+	"github.com/MinterTeam/minter-explorer-extender/validator"
+	"github.com/MinterTeam/minter-explorer-tools/helpers"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/sirupsen/logrus"
+	"os"
+	"time"
+)
+
+type Service struct {
+	env                 *models.ExtenderEnvironment
+	addressRepository   address.Repository
+	coinRepository      coin.Repository
+	validatorRepository validator.Repository
+	balanceRepository   balance.Repository
+	logger              *logrus.Entry
+}
+
+func NewService(env *models.ExtenderEnvironment, addressRepository address.Repository, coinRepository coin.Repository,
+	validatorRepository validator.Repository, balanceRepository balance.Repository, logger *logrus.Entry) *Service {
+	return &Service{
+		env:                 env,
+		addressRepository:   addressRepository,
+		coinRepository:      coinRepository,
+		validatorRepository: validatorRepository,
+		balanceRepository:   balanceRepository,
+		logger:              logger,
+	}
+}
+
+// Seed coins, addresses, validators, stakes and balances from the genesis file.
+// Every step skips rows which already exist, so an interrupted import can be repeated.
+// Return the first height of the chain
+func (s *Service) ImportFromFile(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	genesis := new(Genesis)
+	if err = json.NewDecoder(file).Decode(genesis); err != nil {
+		return 0, err
+	}
+	state := genesis.AppState
+
+	if err = s.saveAddresses(state); err != nil {
+		s.logger.Error(err)
+		return 0, err
+	}
+	if err = s.saveCoins(state.Coins); err != nil {
+		s.logger.Error(err)
+		return 0, err
+	}
+	if err = s.saveValidators(state.Candidates); err != nil {
+		s.logger.Error(err)
+		return 0, err
+	}
+	if err = s.saveStakes(state.Candidates); err != nil {
+		s.logger.Error(err)
+		return 0, err
+	}
+	if err = s.saveBalances(state.Accounts); err != nil {
+		s.logger.Error(err)
+		return 0, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"accounts":   len(state.Accounts),
+		"coins":      len(state.Coins),
+		"candidates": len(state.Candidates),
+	}).Warn("Genesis imported")
+
+	return state.StartHeight + 1, nil
+}
+
+func (s *Service) saveAddresses(state AppState) error {
+	addressesMap := make(map[string]struct{})
+	for _, account := range state.Accounts {
+		addressesMap[helpers.RemovePrefix(account.Address)] = struct{}{}
+	}
+	for _, candidate := range state.Candidates {
+		addressesMap[helpers.RemovePrefix(candidate.RewardAddress)] = struct{}{}
+		addressesMap[helpers.RemovePrefix(candidate.OwnerAddress)] = struct{}{}
+		for _, stake := range candidate.Stakes {
+			addressesMap[helpers.RemovePrefix(stake.Owner)] = struct{}{}
+		}
+	}
+	addresses := make([]string, 0, len(addressesMap))
+	for a := range addressesMap {
+		addresses = append(addresses, a)
+	}
+	for _, chunk := range chunks(len(addresses), s.env.AddrChunkSize) {
+		if err := s.addressRepository.SaveAllIfNotExist(addresses[chunk[0]:chunk[1]]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) saveCoins(genesisCoins []Coin) error {
+	// Base coin is not listed in the genesis
+	err := s.coinRepository.Save(&models.Coin{Symbol: s.env.BaseCoin, UpdatedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	if len(genesisCoins) == 0 {
+		return nil
+	}
+	coins := make([]*models.Coin, len(genesisCoins))
+	for i, c := range genesisCoins {
+		coins[i] = &models.Coin{
+			Name:           c.Name,
+			Symbol:         c.Symbol,
+			Crr:            c.Crr,
+			Volume:         c.Volume,
+			ReserveBalance: c.ReserveBalance,
+			UpdatedAt:      time.Now(),
+		}
+	}
+	return s.coinRepository.SaveAllIfNotExist(coins)
+}
+
+func (s *Service) saveValidators(candidates []Candidate) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+	validators := make([]*models.Validator, len(candidates))
+	for i, candidate := range candidates {
+		rewardAddressID, err := s.addressRepository.FindId(helpers.RemovePrefix(candidate.RewardAddress))
+		if err != nil {
+			return err
+		}
+		ownerAddressID, err := s.addressRepository.FindId(helpers.RemovePrefix(candidate.OwnerAddress))
+		if err != nil {
+			return err
+		}
+		status := candidate.Status
+		commission := candidate.Commission
+		createdAtBlockID := candidate.CreatedAtBlock
+		totalStake := candidate.TotalBipStake
+		updateAt := time.Now()
+		validators[i] = &models.Validator{
+			PublicKey:        helpers.RemovePrefix(candidate.PubKey),
+			RewardAddressID:  &rewardAddressID,
+			OwnerAddressID:   &ownerAddressID,
+			CreatedAtBlockID: &createdAtBlockID,
+			Status:           &status,
+			Commission:       &commission,
+			TotalStake:       &totalStake,
+			UpdateAt:         &updateAt,
+		}
+	}
+	return s.validatorRepository.SaveAllIfNotExist(validators)
+}
+
+func (s *Service) saveStakes(candidates []Candidate) error {
+	var stakes []*models.Stake
+	for _, candidate := range candidates {
+		validatorID, err := s.validatorRepository.FindIdByPk(helpers.RemovePrefix(candidate.PubKey))
+		if err != nil {
+			return err
+		}
+		for _, stake := range candidate.Stakes {
+			ownerAddressID, err := s.addressRepository.FindId(helpers.RemovePrefix(stake.Owner))
+			if err != nil {
+				return err
+			}
+			coinID, err := s.coinRepository.FindIdBySymbol(stake.Coin)
+			if err != nil {
+				return err
+			}
+			stakes = append(stakes, &models.Stake{
+				ValidatorID:    validatorID,
+				OwnerAddressID: ownerAddressID,
+				CoinID:         coinID,
+				Value:          stake.Value,
+				BipValue:       stake.BipValue,
+			})
+		}
+	}
+	for _, chunk := range chunks(len(stakes), s.env.StakeChunkSize) {
+		if err := s.validatorRepository.SaveAllStakes(stakes[chunk[0]:chunk[1]]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) saveBalances(accounts []Account) error {
+	for _, chunk := range chunks(len(accounts), s.env.AddrChunkSize) {
+		list := accounts[chunk[0]:chunk[1]]
+		addresses := make([]string, len(list))
+		for i, account := range list {
+			addresses[i] = helpers.RemovePrefix(account.Address)
+		}
+		// Balances which are already stored by an interrupted import
+		existing, err := s.balanceRepository.FindAllByAddress(addresses)
+		if err != nil {
+			return err
+		}
+		stored := make(map[uint64]map[uint64]struct{})
+		for _, b := range existing {
+			if stored[b.AddressID] == nil {
+				stored[b.AddressID] = make(map[uint64]struct{})
+			}
+			stored[b.AddressID][b.CoinID] = struct{}{}
+		}
+
+		var balances []*models.Balance
+		for _, account := range list {
+			addressID, err := s.addressRepository.FindId(helpers.RemovePrefix(account.Address))
+			if err != nil {
+				return err
+			}
+			for _, b := range account.Balance {
+				coinID, err := s.coinRepository.FindIdBySymbol(b.Coin)
+				if err != nil {
+					return err
+				}
+				if _, ok := stored[addressID][coinID]; ok {
+					continue
+				}
+				balances = append(balances, &models.Balance{
+					AddressID: addressID,
+					CoinID:    coinID,
+					Value:     b.Value,
+				})
+			}
+		}
+		if err = s.balanceRepository.SaveAll(balances); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Split count of items to [start, end) ranges of the size
+func chunks(count int, size int) [][2]int {
+	if size <= 0 {
+		size = count
+	}
+	var list [][2]int
+	for start := 0; start < count; start += size {
+		end := start + size
+		if end > count {
+			end = count
+		}
+		list = append(list, [2]int{start, end})
+	}
+	return list
+}
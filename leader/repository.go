@@ -0,0 +1,87 @@
+package leader
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"time"
+)
+
+// Named lease held by one instance until it expires
+type Lease struct {
+	tableName struct{}  `sql:"leader_leases"`
+	Name      string    `sql:",pk"`
+	Holder    string    `sql:",notnull"`
+	ExpiresAt time.Time `sql:",notnull"`
+}
+
+// Storage of leases
+type Repository interface {
+	WithTx(tx *pg.Tx) Repository
+	Acquire(name, holder string, ttl time.Duration) (bool, error)
+	IsHeld(name, holder string) (bool, error)
+	Release(name, holder string) error
+	Find(name string) (*Lease, error)
+}
+
+type pgRepository struct {
+	db orm.DB
+}
+
+func NewRepository(db orm.DB) Repository {
+	return &pgRepository{
+		db: db,
+	}
+}
+
+// Return a copy of the repository which runs all queries inside the transaction
+func (r *pgRepository) WithTx(tx *pg.Tx) Repository {
+	return &pgRepository{
+		db: tx,
+	}
+}
+
+// Take the lease or prolong it by the ttl. Succeeds if the lease is free, expired or already held by the holder
+func (r *pgRepository) Acquire(name, holder string, ttl time.Duration) (bool, error) {
+	res, err := r.db.Exec(`
+insert into leader_leases (name, holder, expires_at)
+values (?, ?, now() + ? * interval '1 millisecond')
+on conflict (name) do update set holder     = excluded.holder,
+                                 expires_at = excluded.expires_at
+where leader_leases.holder = excluded.holder
+   or leader_leases.expires_at < now();
+	`, name, holder, ttl.Nanoseconds()/int64(time.Millisecond))
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() == 1, nil
+}
+
+// Whether the holder has a not expired lease. The lease row is locked until the end of the transaction,
+// so it can not be taken over before writes of the transaction are committed
+func (r *pgRepository) IsHeld(name, holder string) (bool, error) {
+	err := r.db.Model(new(Lease)).
+		Where("name = ?", name).
+		Where("holder = ?", holder).
+		Where("expires_at > now()").
+		For("SHARE").
+		Select()
+	if err == pg.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Free the lease if it is held by the holder
+func (r *pgRepository) Release(name, holder string) error {
+	_, err := r.db.Model((*Lease)(nil)).
+		Where("name = ?", name).
+		Where("holder = ?", holder).
+		Delete()
+	return err
+}
+
+func (r *pgRepository) Find(name string) (*Lease, error) {
+	lease := new(Lease)
+	err := r.db.Model(lease).Where("name = ?", name).Select()
+	return lease, err
+}
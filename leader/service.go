@@ -0,0 +1,156 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-pg/pg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNotLeader = errors.New("the instance does not hold the leader lease")
+
+var isLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "extender_leader",
+	Help: "1 if the instance holds the leader lease",
+})
+
+func init() {
+	prometheus.MustRegister(isLeader)
+}
+
+// Election of one instance through a lease in the database. The leader prolongs the lease
+// every third of the ttl, standby instances try to take it with the same interval
+type Service struct {
+	repository Repository
+	name       string
+	holder     string
+	ttl        time.Duration
+	leading    int32 // updated atomically
+	logger     *logrus.Entry
+}
+
+func NewService(repository Repository, name string, ttl time.Duration, logger *logrus.Entry) *Service {
+	hostname, _ := os.Hostname()
+	return &Service{
+		repository: repository,
+		name:       name,
+		holder:     fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		ttl:        ttl,
+		logger:     logger.WithField("lease", name),
+	}
+}
+
+func (s *Service) IsLeader() bool {
+	return atomic.LoadInt32(&s.leading) == 1
+}
+
+// Wait until the lease is taken. The returned context is done when the lease is lost or the parent one is done
+func (s *Service) Campaign(ctx context.Context) (context.Context, error) {
+	waiting := false
+	for {
+		ok, err := s.repository.Acquire(s.name, s.holder, s.ttl)
+		if err != nil {
+			s.logger.Error(err)
+		}
+		if ok {
+			break
+		}
+		if !waiting {
+			waiting = true
+			lease, _ := s.repository.Find(s.name)
+			s.logger.WithField("leader", lease.Holder).Warn("Standing by until the leader lease expires")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.ttl / 3):
+		}
+	}
+	s.setLeading(true)
+	s.logger.WithField("holder", s.holder).Warn("Leader lease acquired")
+
+	ctx, cancel := context.WithCancel(ctx)
+	go s.keep(ctx, cancel)
+	return ctx, nil
+}
+
+// Prolong the lease until the context is done. Renewal errors are tolerated while the lease is not expired
+func (s *Service) keep(ctx context.Context, cancel context.CancelFunc) {
+	defer cancel()
+	expiresAt := time.Now().Add(s.ttl)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.ttl / 3):
+		}
+
+		start := time.Now()
+		ok, err := s.repository.Acquire(s.name, s.holder, s.ttl)
+		if ok {
+			expiresAt = start.Add(s.ttl)
+			continue
+		}
+		if err != nil && time.Now().Before(expiresAt) {
+			s.logger.Error(err)
+			continue
+		}
+		s.setLeading(false)
+		s.logger.WithField("holder", s.holder).Error("Leader lease lost")
+		return
+	}
+}
+
+// Take the lease for a one-off operation, fails if another instance is the leader
+func (s *Service) TryAcquire() error {
+	ok, err := s.repository.Acquire(s.name, s.holder, s.ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		lease, _ := s.repository.Find(s.name)
+		return fmt.Errorf("the leader lease is held by %s until %s", lease.Holder, lease.ExpiresAt)
+	}
+	s.setLeading(true)
+	return nil
+}
+
+// Free the lease, so a standby instance takes over without waiting for the expiration
+func (s *Service) Release() {
+	if !s.IsLeader() {
+		return
+	}
+	s.setLeading(false)
+	err := s.repository.Release(s.name, s.holder)
+	if err != nil {
+		s.logger.Error(err)
+	}
+}
+
+// Fence writes of the transaction: fails if the lease is not held at the moment and
+// keeps it from being taken over until the transaction ends
+func (s *Service) CheckInTx(tx *pg.Tx) error {
+	held, err := s.repository.WithTx(tx).IsHeld(s.name, s.holder)
+	if err != nil {
+		return err
+	}
+	if !held {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+func (s *Service) setLeading(leading bool) {
+	if leading {
+		atomic.StoreInt32(&s.leading, 1)
+		isLeader.Set(1)
+	} else {
+		atomic.StoreInt32(&s.leading, 0)
+		isLeader.Set(0)
+	}
+}
@@ -1,15 +1,62 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"github.com/MinterTeam/minter-explorer-extender/api"
 	"github.com/MinterTeam/minter-explorer-extender/core"
 	"github.com/MinterTeam/minter-explorer-extender/env"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
 	envData := env.New()
-	extenderApi := api.New(envData.ApiHost, envData.ApiPort)
-	go extenderApi.Run()
-	ext := core.NewExtender(envData)
-	ext.Run()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	var err error
+	switch flag.Arg(0) {
+	case "":
+		if envData.DryRun {
+			err = core.NewExtender(ctx, envData).DryRun(ctx)
+			break
+		}
+		extenderApi := api.New(envData.ApiHost, envData.ApiPort)
+		go extenderApi.Run()
+		err = core.NewExtender(ctx, envData).Run(ctx)
+	case "backfill":
+		cmd := flag.NewFlagSet("backfill", flag.ExitOnError)
+		from := cmd.Uint64("from", 0, "First height of the range")
+		to := cmd.Uint64("to", 0, "Last height of the range")
+		_ = cmd.Parse(flag.Args()[1:])
+		err = core.NewExtender(ctx, envData).Backfill(ctx, *from, *to)
+	case "rollback":
+		cmd := flag.NewFlagSet("rollback", flag.ExitOnError)
+		height := cmd.Uint64("to-height", 0, "Height which stays the last indexed one")
+		_ = cmd.Parse(flag.Args()[1:])
+		err = core.NewExtender(ctx, envData).Rollback(*height)
+	case "retry-quarantined":
+		cmd := flag.NewFlagSet("retry-quarantined", flag.ExitOnError)
+		stage := cmd.String("stage", "", "Retry only items of the stage (transaction, event)")
+		_ = cmd.Parse(flag.Args()[1:])
+		err = core.NewExtender(ctx, envData).RetryQuarantined(*stage)
+	case "status":
+		err = core.NewExtender(ctx, envData).Status()
+	case "migrate":
+		err = core.NewExtender(ctx, envData).Migrate(flag.Arg(1))
+	default:
+		log.Fatalf("unknown command %q", flag.Arg(0))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 }
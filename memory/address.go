@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"github.com/MinterTeam/minter-explorer-extender/address"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/go-pg/pg"
+	"sync"
+)
+
+type addressRepository struct {
+	store        *Store
+	placeholders *sync.Map
+}
+
+func NewAddressRepository(store *Store) address.Repository {
+	return &addressRepository{
+		store:        store,
+		placeholders: new(sync.Map),
+	}
+}
+
+func (r *addressRepository) FindId(a string) (uint64, error) {
+	id, ok := r.placeholders.Load(a)
+	if ok {
+		return id.(uint64), nil
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if id, ok := r.store.addressIds[a]; ok {
+		return id, nil
+	}
+	return 0, pg.ErrNoRows
+}
+
+func (r *addressRepository) FindIdOrCreate(a string) (uint64, error) {
+	id, ok := r.placeholders.Load(a)
+	if ok {
+		return id.(uint64), nil
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return r.store.addressId(a), nil
+}
+
+func (r *addressRepository) CachePlaceholder(a string, id uint64) {
+	r.placeholders.Store(a, id)
+}
+
+func (r *addressRepository) FindById(id uint64) (string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	value, ok := r.store.get(tableAddresses, id)
+	if !ok {
+		return "", pg.ErrNoRows
+	}
+	return value.(*models.Address).Address, nil
+}
+
+func (r *addressRepository) FindAll(addresses []string) ([]*models.Address, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var list []*models.Address
+	for _, a := range addresses {
+		id, ok := r.store.addressIds[a]
+		if !ok {
+			continue
+		}
+		value, _ := r.store.get(tableAddresses, id)
+		found := *value.(*models.Address)
+		list = append(list, &found)
+	}
+	return list, nil
+}
+
+func (r *addressRepository) SaveAllIfNotExist(addresses []string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, a := range addresses {
+		r.store.addressId(a)
+	}
+	return nil
+}
+
+func (r *addressRepository) FindAllChangedInBlocks(from, to uint64) ([]string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	ids := make(map[uint64]struct{})
+	for height := from + 1; height <= to; height++ {
+		r.store.eachAt(tableTxIndex, height, func(key interface{}, value interface{}) {
+			ids[key.(txIndexKey).addressId] = struct{}{}
+		})
+		r.store.eachAt(tableRewards, height, func(key interface{}, value interface{}) {
+			ids[value.(*models.Reward).AddressID] = struct{}{}
+		})
+		r.store.eachAt(tableSlashes, height, func(key interface{}, value interface{}) {
+			ids[value.(*models.Slash).AddressID] = struct{}{}
+		})
+	}
+	var addresses []string
+	for id := range ids {
+		if value, ok := r.store.get(tableAddresses, id); ok {
+			addresses = append(addresses, value.(*models.Address).Address)
+		}
+	}
+	return addresses, nil
+}
+
+func (r *addressRepository) SaveFromMapIfNotExists(addresses map[string]struct{}) error {
+	list := make([]string, 0, len(addresses))
+	for a := range addresses {
+		list = append(list, a)
+	}
+	return r.SaveAllIfNotExist(list)
+}
+
+// Id of the address, which is created if it does not exist
+func (s *Store) addressId(a string) uint64 {
+	if id, ok := s.addressIds[a]; ok {
+		return id
+	}
+	id := s.nextId(tableAddresses)
+	s.put(nil, tableAddresses, id, noBlock, &models.Address{ID: id, Address: a})
+	s.addressIds[a] = id
+	return id
+}
@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/balance"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/go-pg/pg"
+)
+
+type balanceRepository struct {
+	store *Store
+	tx    *pg.Tx
+}
+
+func NewBalanceRepository(store *Store) balance.Repository {
+	return &balanceRepository{
+		store: store,
+	}
+}
+
+func (r *balanceRepository) WithTx(tx *pg.Tx) balance.Repository {
+	return &balanceRepository{
+		store: r.store,
+		tx:    tx,
+	}
+}
+
+// Balances of the addresses with their address and coin
+func (r *balanceRepository) FindAllByAddress(addresses []string) ([]*models.Balance, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	ids := make(map[uint64]struct{}, len(addresses))
+	for _, a := range addresses {
+		if id, ok := r.store.addressIds[a]; ok {
+			ids[id] = struct{}{}
+		}
+	}
+	var balances []*models.Balance
+	r.store.each(tableBalances, func(key interface{}, value interface{}) {
+		b := *value.(*models.Balance)
+		if _, ok := ids[b.AddressID]; !ok {
+			return
+		}
+		if a, ok := r.store.get(tableAddresses, b.AddressID); ok {
+			found := *a.(*models.Address)
+			b.Address = &found
+		}
+		if c, ok := r.store.get(tableCoins, b.CoinID); ok {
+			found := *c.(*models.Coin)
+			b.Coin = &found
+		}
+		balances = append(balances, &b)
+	})
+	return balances, nil
+}
+
+func (r *balanceRepository) SaveAll(balances []*models.Balance) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	existing := make(map[[2]uint64]struct{})
+	r.store.each(tableBalances, func(key interface{}, value interface{}) {
+		b := value.(*models.Balance)
+		existing[[2]uint64{b.AddressID, b.CoinID}] = struct{}{}
+	})
+	for _, b := range balances {
+		if _, ok := existing[[2]uint64{b.AddressID, b.CoinID}]; ok {
+			return fmt.Errorf("balance of address %d in coin %d already exists", b.AddressID, b.CoinID)
+		}
+	}
+	for _, b := range balances {
+		b.ID = r.store.nextId(tableBalances)
+		r.store.put(r.tx, tableBalances, b.ID, noBlock, storedBalance(b))
+	}
+	return nil
+}
+
+func (r *balanceRepository) UpdateAll(balances []*models.Balance) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, b := range balances {
+		if _, ok := r.store.get(tableBalances, b.ID); ok {
+			r.store.put(r.tx, tableBalances, b.ID, noBlock, storedBalance(b))
+		}
+	}
+	return nil
+}
+
+func (r *balanceRepository) DeleteAll(balances []*models.Balance) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, b := range balances {
+		r.store.remove(r.tx, tableBalances, b.ID)
+	}
+	return nil
+}
+
+func (r *balanceRepository) DeleteByCoinId(coinId uint64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var ids []interface{}
+	r.store.each(tableBalances, func(key interface{}, value interface{}) {
+		if value.(*models.Balance).CoinID == coinId {
+			ids = append(ids, key)
+		}
+	})
+	for _, id := range ids {
+		r.store.remove(r.tx, tableBalances, id)
+	}
+	return nil
+}
+
+// Copy of the balance without relations, which are not stored
+func storedBalance(b *models.Balance) *models.Balance {
+	saved := *b
+	saved.Address = nil
+	saved.Coin = nil
+	return &saved
+}
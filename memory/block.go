@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/block"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/go-pg/pg"
+)
+
+// Tables with rows of blocks in the order of deletion
+var blockTables = []string{
+	tableTxOutputs,
+	tableTxValidators,
+	tableTxIndex,
+	tableInvalidTxs,
+	tableTransactions,
+	tableRewards,
+	tableSlashes,
+	tableBlockValidators,
+	tableQuarantine,
+	tableBlocks,
+}
+
+type blockRepository struct {
+	store *Store
+	tx    *pg.Tx
+}
+
+func NewBlockRepository(store *Store) block.Repository {
+	return &blockRepository{
+		store: store,
+	}
+}
+
+func (r *blockRepository) WithTx(tx *pg.Tx) block.Repository {
+	return &blockRepository{
+		store: r.store,
+		tx:    tx,
+	}
+}
+
+func (r *blockRepository) Save(b *models.Block) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if _, exists := r.store.get(tableBlocks, b.ID); exists {
+		return fmt.Errorf("block %d already exists", b.ID)
+	}
+	saved := *b
+	r.store.put(r.tx, tableBlocks, b.ID, b.ID, &saved)
+	return nil
+}
+
+func (r *blockRepository) GetLastFromDB() (*models.Block, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	last, ok := r.store.lastBlockId()
+	if !ok {
+		return nil, pg.ErrNoRows
+	}
+	return r.store.block(last)
+}
+
+func (r *blockRepository) GetById(id uint64) (*models.Block, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return r.store.block(id)
+}
+
+func (r *blockRepository) LinkWithValidators(links []*models.BlockValidator) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, l := range links {
+		saved := *l
+		r.store.put(r.tx, tableBlockValidators, r.store.nextId(tableBlockValidators), l.BlockID, &saved)
+	}
+	return nil
+}
+
+func (r *blockRepository) DeleteLastBlockData() error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	last, ok := r.store.lastBlockId()
+	if !ok {
+		return nil
+	}
+	r.store.removeBlockRows(r.tx, func(height uint64) bool {
+		return height == last
+	})
+	return nil
+}
+
+func (r *blockRepository) DeleteAfterHeight(height uint64) ([]block.DeletedRows, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	after := func(h uint64) bool {
+		return h > height
+	}
+	aggregated := r.store.trimAggregatedRewards(r.tx, height)
+	deleted := r.store.removeBlockRows(r.tx, after)
+	return append(deleted, block.DeletedRows{Table: tableAggregatedRewards, Count: aggregated}), nil
+}
+
+func (r *blockRepository) DeleteHeight(height uint64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.removeBlockRows(r.tx, func(h uint64) bool {
+		return h == height
+	})
+	return nil
+}
+
+func (s *Store) removeBlockRows(tx *pg.Tx, match func(height uint64) bool) []block.DeletedRows {
+	deleted := make([]block.DeletedRows, len(blockTables))
+	for i, name := range blockTables {
+		deleted[i] = block.DeletedRows{Table: name, Count: s.removeBlocks(tx, name, match)}
+	}
+	return deleted
+}
+
+func (s *Store) lastBlockId() (uint64, bool) {
+	var last uint64
+	for _, height := range s.heights(tableBlocks) {
+		if height > last {
+			last = height
+		}
+	}
+	return last, last != 0
+}
+
+func (s *Store) block(id uint64) (*models.Block, error) {
+	value, ok := s.get(tableBlocks, id)
+	if !ok {
+		return nil, pg.ErrNoRows
+	}
+	found := *value.(*models.Block)
+	return &found, nil
+}
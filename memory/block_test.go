@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"testing"
+	"time"
+)
+
+func TestDeleteAfterHeight(t *testing.T) {
+	store := NewStore()
+	blocks := NewBlockRepository(store)
+	transactions := NewTransactionRepository(store)
+	events := NewEventsRepository(store)
+	for height := uint64(1); height <= 3; height++ {
+		if err := blocks.Save(&models.Block{ID: height}); err != nil {
+			t.Fatal(err)
+		}
+		err := blocks.LinkWithValidators([]*models.BlockValidator{{BlockID: height, ValidatorID: 1, Signed: true}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = transactions.SaveAll([]*models.Transaction{{BlockID: height}, {BlockID: height}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = events.SaveRewards([]*models.Reward{{BlockID: height, AddressID: 1, ValidatorID: 1, Role: "Validator", Amount: "1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := blocks.DeleteAfterHeight(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, d := range deleted {
+		counts[d.Table] = d.Count
+	}
+	want := map[string]int{
+		tableBlocks:            2,
+		tableBlockValidators:   2,
+		tableTransactions:      4,
+		tableRewards:           2,
+		tableSlashes:           0,
+		tableAggregatedRewards: 0,
+	}
+	for name, count := range want {
+		if counts[name] != count {
+			t.Errorf("deleted %d rows of %s, want %d", counts[name], name, count)
+		}
+	}
+
+	last, err := blocks.GetLastFromDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID != 1 {
+		t.Errorf("last block = %d, want 1", last.ID)
+	}
+	store.mu.Lock()
+	left := len(store.table(tableTransactions).rows)
+	store.mu.Unlock()
+	if left != 2 {
+		t.Errorf("%d transactions are left, want 2", left)
+	}
+}
+
+func TestDeleteAfterHeightTrimsAggregatedRewards(t *testing.T) {
+	store := NewStore()
+	blocks := NewBlockRepository(store)
+	events := NewEventsRepository(store)
+	start := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	for height := uint64(1); height <= 4; height++ {
+		err := blocks.Save(&models.Block{ID: height, CreatedAt: start.Add(time.Duration(height) * time.Second)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = events.SaveRewards([]*models.Reward{{BlockID: height, AddressID: 1, ValidatorID: 1, Role: "Validator", Amount: "1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Rewards of blocks 1-3 are summed into one row of the hour
+	if err := events.AggregateRewards("hour", 4); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := blocks.DeleteAfterHeight(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range deleted {
+		if d.Table == tableAggregatedRewards && d.Count != 0 {
+			t.Errorf("deleted %d aggregated rewards, want the straddling one to be kept", d.Count)
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var sums []*aggregatedReward
+	store.each(tableAggregatedRewards, func(key interface{}, value interface{}) {
+		sums = append(sums, value.(*aggregatedReward))
+	})
+	if len(sums) != 1 {
+		t.Fatalf("%d aggregated rewards are left, want 1", len(sums))
+	}
+	if sums[0].fromBlockId != 1 || sums[0].toBlockId != 2 || sums[0].amount.String() != "2" {
+		t.Errorf("aggregated reward = %d-%d %s, want 1-2 2", sums[0].fromBlockId, sums[0].toBlockId, sums[0].amount)
+	}
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"github.com/MinterTeam/minter-explorer-extender/coin"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/go-pg/pg"
+	"sort"
+	"sync"
+)
+
+type coinRepository struct {
+	store        *Store
+	tx           *pg.Tx
+	placeholders *sync.Map
+}
+
+func NewCoinRepository(store *Store) coin.Repository {
+	return &coinRepository{
+		store:        store,
+		placeholders: new(sync.Map),
+	}
+}
+
+func (r *coinRepository) WithTx(tx *pg.Tx) coin.Repository {
+	return &coinRepository{
+		store:        r.store,
+		tx:           tx,
+		placeholders: r.placeholders,
+	}
+}
+
+func (r *coinRepository) FindIdBySymbol(symbol string) (uint64, error) {
+	id, ok := r.placeholders.Load(symbol)
+	if ok {
+		return id.(uint64), nil
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	c := r.store.coinBySymbol(symbol)
+	if c == nil {
+		return 0, pg.ErrNoRows
+	}
+	return c.ID, nil
+}
+
+func (r *coinRepository) CachePlaceholder(symbol string, id uint64) {
+	r.placeholders.Store(symbol, id)
+}
+
+func (r *coinRepository) FindSymbolById(id uint64) (string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	value, ok := r.store.get(tableCoins, id)
+	if !ok {
+		return "", pg.ErrNoRows
+	}
+	return value.(*models.Coin).Symbol, nil
+}
+
+// Load the existing coin with the symbol into c or create it
+func (r *coinRepository) Save(c *models.Coin) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if existing := r.store.coinBySymbol(c.Symbol); existing != nil {
+		*c = *existing
+		return nil
+	}
+	c.ID = r.store.nextId(tableCoins)
+	saved := *c
+	r.store.put(r.tx, tableCoins, c.ID, noBlock, &saved)
+	return nil
+}
+
+// Create coins or replace existing ones with the same symbols
+func (r *coinRepository) SaveAllIfNotExist(coins []*models.Coin) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, c := range coins {
+		if existing := r.store.coinBySymbol(c.Symbol); existing != nil {
+			c.ID = existing.ID
+		} else {
+			c.ID = r.store.nextId(tableCoins)
+		}
+		saved := *c
+		r.store.put(r.tx, tableCoins, c.ID, noBlock, &saved)
+	}
+	return nil
+}
+
+func (r *coinRepository) GetAllCoins() ([]*models.Coin, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var coins []*models.Coin
+	r.store.each(tableCoins, func(key interface{}, value interface{}) {
+		c := *value.(*models.Coin)
+		coins = append(coins, &c)
+	})
+	sort.Slice(coins, func(i, j int) bool {
+		return coins[i].Symbol < coins[j].Symbol
+	})
+	return coins, nil
+}
+
+func (r *coinRepository) DeleteBySymbol(symbol string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if c := r.store.coinBySymbol(symbol); c != nil {
+		r.store.remove(r.tx, tableCoins, c.ID)
+	}
+	return nil
+}
+
+func (s *Store) coinBySymbol(symbol string) *models.Coin {
+	var found *models.Coin
+	s.each(tableCoins, func(key interface{}, value interface{}) {
+		if c := value.(*models.Coin); c.Symbol == symbol {
+			found = c
+		}
+	})
+	return found
+}
@@ -0,0 +1,178 @@
+package memory
+
+import (
+	"errors"
+	"github.com/MinterTeam/minter-explorer-extender/events"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/go-pg/pg"
+	"math/big"
+	"time"
+)
+
+// Primary key of aggregated_rewards
+type aggregatedRewardKey struct {
+	timeId      time.Time
+	addressId   uint64
+	validatorId uint64
+	role        string
+}
+
+type aggregatedReward struct {
+	fromBlockId uint64
+	toBlockId   uint64
+	amount      *big.Int
+}
+
+type eventsRepository struct {
+	store *Store
+	tx    *pg.Tx
+}
+
+func NewEventsRepository(store *Store) events.Repository {
+	return &eventsRepository{
+		store: store,
+	}
+}
+
+func (r *eventsRepository) WithTx(tx *pg.Tx) events.Repository {
+	return &eventsRepository{
+		store: r.store,
+		tx:    tx,
+	}
+}
+
+func (r *eventsRepository) SaveRewards(rewards []*models.Reward) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, reward := range rewards {
+		saved := *reward
+		r.store.put(r.tx, tableRewards, r.store.nextId(tableRewards), reward.BlockID, &saved)
+	}
+	return nil
+}
+
+func (r *eventsRepository) SaveSlashes(slashes []*models.Slash) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, slash := range slashes {
+		slash.ID = r.store.nextId(tableSlashes)
+		saved := *slash
+		r.store.put(r.tx, tableSlashes, slash.ID, slash.BlockID, &saved)
+	}
+	return nil
+}
+
+// Sum rewards by the interval from the start of the last aggregated interval up to the block,
+// which is not included. Sums of intervals aggregated before are replaced
+func (r *eventsRepository) AggregateRewards(aggregateInterval string, beforeBlockId uint64) error {
+	if aggregateInterval != "hour" && aggregateInterval != "day" {
+		return errors.New("not acceptable aggregate interval")
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	before, ok := r.store.get(tableBlocks, beforeBlockId)
+	if !ok {
+		return nil
+	}
+	beforeTime := before.(*models.Block).CreatedAt
+	start, ok := r.store.lastAggregatedTime()
+
+	sums := make(map[aggregatedRewardKey]*aggregatedReward)
+	for height := beforeBlockId - 1; height > 0; height-- {
+		value, exists := r.store.get(tableBlocks, height)
+		if !exists {
+			continue
+		}
+		createdAt := value.(*models.Block).CreatedAt
+		if ok && createdAt.Before(start) {
+			break
+		}
+		if !createdAt.Before(beforeTime) {
+			continue
+		}
+		timeId := truncateTime(createdAt, aggregateInterval)
+		r.store.eachAt(tableRewards, height, func(key interface{}, value interface{}) {
+			reward := value.(*models.Reward)
+			amount, valid := new(big.Int).SetString(reward.Amount, 10)
+			if !valid {
+				return
+			}
+			k := aggregatedRewardKey{timeId: timeId, addressId: reward.AddressID, validatorId: reward.ValidatorID, role: reward.Role}
+			sum, exists := sums[k]
+			if !exists {
+				sums[k] = &aggregatedReward{fromBlockId: height, toBlockId: height, amount: amount}
+				return
+			}
+			sum.amount.Add(sum.amount, amount)
+			if height < sum.fromBlockId {
+				sum.fromBlockId = height
+			}
+			if height > sum.toBlockId {
+				sum.toBlockId = height
+			}
+		})
+	}
+	for k, sum := range sums {
+		r.store.put(r.tx, tableAggregatedRewards, k, sum.toBlockId, sum)
+	}
+	return nil
+}
+
+// Delete aggregated rewards which start above the height and sum ones which include it again up to the height.
+// Return the count of deleted rows
+func (s *Store) trimAggregatedRewards(tx *pg.Tx, height uint64) int {
+	deleted := 0
+	var keys []aggregatedRewardKey
+	s.each(tableAggregatedRewards, func(key interface{}, value interface{}) {
+		if value.(*aggregatedReward).toBlockId > height {
+			keys = append(keys, key.(aggregatedRewardKey))
+		}
+	})
+	for _, k := range keys {
+		value, _ := s.get(tableAggregatedRewards, k)
+		sum := value.(*aggregatedReward)
+		if sum.fromBlockId > height {
+			s.remove(tx, tableAggregatedRewards, k)
+			deleted++
+			continue
+		}
+		trimmed := &aggregatedReward{fromBlockId: sum.fromBlockId, toBlockId: sum.fromBlockId, amount: new(big.Int)}
+		for h := sum.fromBlockId; h <= height; h++ {
+			s.eachAt(tableRewards, h, func(key interface{}, value interface{}) {
+				reward := value.(*models.Reward)
+				if reward.AddressID != k.addressId || reward.ValidatorID != k.validatorId || reward.Role != k.role {
+					return
+				}
+				if amount, valid := new(big.Int).SetString(reward.Amount, 10); valid {
+					trimmed.amount.Add(trimmed.amount, amount)
+					trimmed.toBlockId = h
+				}
+			})
+		}
+		s.put(tx, tableAggregatedRewards, k, trimmed.toBlockId, trimmed)
+	}
+	return deleted
+}
+
+// Start of the last aggregated interval
+func (s *Store) lastAggregatedTime() (time.Time, bool) {
+	var last time.Time
+	found := false
+	s.each(tableAggregatedRewards, func(key interface{}, value interface{}) {
+		timeId := key.(aggregatedRewardKey).timeId
+		if !found || timeId.After(last) {
+			last = timeId
+			found = true
+		}
+	})
+	return last, found
+}
+
+func truncateTime(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	if interval == "day" {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
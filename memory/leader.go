@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"github.com/MinterTeam/minter-explorer-extender/leader"
+	"github.com/go-pg/pg"
+	"time"
+)
+
+// Leases are kept by the process, so only instances sharing the store compete for them
+type leaderRepository struct {
+	store *Store
+	tx    *pg.Tx
+}
+
+func NewLeaderRepository(store *Store) leader.Repository {
+	return &leaderRepository{
+		store: store,
+	}
+}
+
+func (r *leaderRepository) WithTx(tx *pg.Tx) leader.Repository {
+	return &leaderRepository{
+		store: r.store,
+		tx:    tx,
+	}
+}
+
+// Take the lease or prolong it by the ttl. Succeeds if the lease is free, expired or already held by the holder
+func (r *leaderRepository) Acquire(name, holder string, ttl time.Duration) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	now := time.Now()
+	if value, ok := r.store.get(tableLeases, name); ok {
+		lease := value.(*leader.Lease)
+		if lease.Holder != holder && lease.ExpiresAt.After(now) {
+			return false, nil
+		}
+	}
+	r.store.put(r.tx, tableLeases, name, noBlock, &leader.Lease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)})
+	return true, nil
+}
+
+func (r *leaderRepository) IsHeld(name, holder string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	value, ok := r.store.get(tableLeases, name)
+	if !ok {
+		return false, nil
+	}
+	lease := value.(*leader.Lease)
+	return lease.Holder == holder && lease.ExpiresAt.After(time.Now()), nil
+}
+
+func (r *leaderRepository) Release(name, holder string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if value, ok := r.store.get(tableLeases, name); ok && value.(*leader.Lease).Holder == holder {
+		r.store.remove(r.tx, tableLeases, name)
+	}
+	return nil
+}
+
+func (r *leaderRepository) Find(name string) (*leader.Lease, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	value, ok := r.store.get(tableLeases, name)
+	if !ok {
+		return new(leader.Lease), pg.ErrNoRows
+	}
+	lease := *value.(*leader.Lease)
+	return &lease, nil
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/partition"
+	"github.com/go-pg/pg"
+	"sort"
+)
+
+// Tables in memory are not split, partitions are only recorded, so their maintenance works the same way
+type partitionRepository struct {
+	store *Store
+	tx    *pg.Tx
+}
+
+func NewPartitionRepository(store *Store) partition.Repository {
+	return &partitionRepository{
+		store: store,
+	}
+}
+
+func (r *partitionRepository) WithTx(tx *pg.Tx) partition.Repository {
+	return &partitionRepository{
+		store: r.store,
+		tx:    tx,
+	}
+}
+
+// Attached partitions of the table ordered by their upper bound
+func (r *partitionRepository) GetAll(table string) ([]*partition.Partition, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var partitions []*partition.Partition
+	r.store.each(tablePartitions, func(key interface{}, value interface{}) {
+		if key.([2]string)[0] == table {
+			p := *value.(*partition.Partition)
+			partitions = append(partitions, &p)
+		}
+	})
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].UpperBound < partitions[j].UpperBound
+	})
+	return partitions, nil
+}
+
+func (r *partitionRepository) Create(table string, from, to uint64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	name := fmt.Sprintf("%s_%d", table, from)
+	if _, exists := r.store.get(tablePartitions, [2]string{table, name}); !exists {
+		r.store.put(r.tx, tablePartitions, [2]string{table, name}, noBlock, &partition.Partition{Name: name, LowerBound: from, UpperBound: to})
+	}
+	return nil
+}
+
+func (r *partitionRepository) Detach(table, name string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.remove(r.tx, tablePartitions, [2]string{table, name})
+	return nil
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"github.com/MinterTeam/minter-explorer-extender/progress"
+	"github.com/go-pg/pg"
+	"sort"
+	"time"
+)
+
+type progressRepository struct {
+	store *Store
+	tx    *pg.Tx
+}
+
+func NewProgressRepository(store *Store) progress.Repository {
+	return &progressRepository{
+		store: store,
+	}
+}
+
+func (r *progressRepository) WithTx(tx *pg.Tx) progress.Repository {
+	return &progressRepository{
+		store: r.store,
+		tx:    tx,
+	}
+}
+
+// Save the height of the stage. A cursor never moves back, except by RollbackTo
+func (r *progressRepository) Save(stage string, height uint64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	cursor := &progress.Cursor{Stage: stage, Height: height, UpdatedAt: time.Now()}
+	if value, ok := r.store.get(tableCursors, stage); ok && value.(*progress.Cursor).Height > height {
+		cursor.Height = value.(*progress.Cursor).Height
+	}
+	r.store.put(r.tx, tableCursors, stage, noBlock, cursor)
+	return nil
+}
+
+func (r *progressRepository) GetAll() ([]*progress.Cursor, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var cursors []*progress.Cursor
+	r.store.each(tableCursors, func(key interface{}, value interface{}) {
+		cursor := *value.(*progress.Cursor)
+		cursors = append(cursors, &cursor)
+	})
+	sort.Slice(cursors, func(i, j int) bool {
+		return cursors[i].Stage < cursors[j].Stage
+	})
+	return cursors, nil
+}
+
+func (r *progressRepository) RollbackTo(height uint64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var moved []*progress.Cursor
+	r.store.each(tableCursors, func(key interface{}, value interface{}) {
+		if cursor := *value.(*progress.Cursor); cursor.Height > height {
+			cursor.Height = height
+			cursor.UpdatedAt = time.Now()
+			moved = append(moved, &cursor)
+		}
+	})
+	for _, cursor := range moved {
+		r.store.put(r.tx, tableCursors, cursor.Stage, noBlock, cursor)
+	}
+	return nil
+}
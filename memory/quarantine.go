@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"github.com/MinterTeam/minter-explorer-extender/quarantine"
+	"github.com/go-pg/pg"
+	"sort"
+	"time"
+)
+
+type quarantineRepository struct {
+	store *Store
+	tx    *pg.Tx
+}
+
+func NewQuarantineRepository(store *Store) quarantine.Repository {
+	return &quarantineRepository{
+		store: store,
+	}
+}
+
+func (r *quarantineRepository) WithTx(tx *pg.Tx) quarantine.Repository {
+	return &quarantineRepository{
+		store: r.store,
+		tx:    tx,
+	}
+}
+
+func (r *quarantineRepository) Save(item *quarantine.Item) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	item.ID = r.store.nextId(tableQuarantine)
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = time.Now()
+	}
+	saved := *item
+	r.store.put(r.tx, tableQuarantine, item.ID, item.BlockID, &saved)
+	return nil
+}
+
+// Not resolved items in order of heights. Empty stage matches all stages
+func (r *quarantineRepository) FindUnresolved(stage string) ([]*quarantine.Item, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var items []*quarantine.Item
+	r.store.each(tableQuarantine, func(key interface{}, value interface{}) {
+		item := *value.(*quarantine.Item)
+		if item.ResolvedAt == nil && (stage == "" || item.Stage == stage) {
+			items = append(items, &item)
+		}
+	})
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].BlockID != items[j].BlockID {
+			return items[i].BlockID < items[j].BlockID
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items, nil
+}
+
+func (r *quarantineRepository) MarkResolved(id uint64) error {
+	now := time.Now()
+	return r.update(id, func(item *quarantine.Item) {
+		item.ResolvedAt = &now
+		item.Attempts++
+	})
+}
+
+// Keep the error of the last retry
+func (r *quarantineRepository) SaveFailedAttempt(id uint64, cause string) error {
+	return r.update(id, func(item *quarantine.Item) {
+		item.Error = cause
+		item.Attempts++
+	})
+}
+
+func (r *quarantineRepository) update(id uint64, change func(item *quarantine.Item)) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	value, ok := r.store.get(tableQuarantine, id)
+	if !ok {
+		return nil
+	}
+	item := *value.(*quarantine.Item)
+	change(&item)
+	r.store.put(r.tx, tableQuarantine, id, item.BlockID, &item)
+	return nil
+}
@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"github.com/MinterTeam/minter-explorer-extender/queue"
+	"github.com/go-pg/pg"
+	"sort"
+	"time"
+)
+
+// Primary key of refresh_jobs
+type jobKey struct {
+	kind string
+	key  string
+}
+
+type queueRepository struct {
+	store *Store
+	tx    *pg.Tx
+}
+
+func NewQueueRepository(store *Store) queue.Repository {
+	return &queueRepository{
+		store: store,
+	}
+}
+
+func (r *queueRepository) WithTx(tx *pg.Tx) queue.Repository {
+	return &queueRepository{
+		store: r.store,
+		tx:    tx,
+	}
+}
+
+// Queue the keys or raise the height of already queued ones. A locked key stays locked until Complete
+func (r *queueRepository) Enqueue(kind string, keys []string, height uint64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, key := range keys {
+		job := &queue.Job{Kind: kind, Key: key, Height: height, CreatedAt: time.Now()}
+		if value, ok := r.store.get(tableJobs, jobKey{kind, key}); ok {
+			queued := value.(*queue.Job)
+			job.CreatedAt = queued.CreatedAt
+			job.LockedUntil = queued.LockedUntil
+			if queued.Height > height {
+				job.Height = queued.Height
+			}
+		}
+		r.store.put(r.tx, tableJobs, jobKey{kind, key}, noBlock, job)
+	}
+	return nil
+}
+
+// Lock up to the limit of the oldest not locked jobs of the kind for the lease time
+func (r *queueRepository) Claim(kind string, limit int, lease time.Duration) ([]*queue.Job, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	now := time.Now()
+	var jobs []*queue.Job
+	r.store.each(tableJobs, func(key interface{}, value interface{}) {
+		job := *value.(*queue.Job)
+		if job.Kind == kind && (job.LockedUntil == nil || job.LockedUntil.Before(now)) {
+			jobs = append(jobs, &job)
+		}
+	})
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	lockedUntil := now.Add(lease)
+	for _, job := range jobs {
+		job.LockedUntil = &lockedUntil
+		locked := *job
+		r.store.put(r.tx, tableJobs, jobKey{job.Kind, job.Key}, noBlock, &locked)
+	}
+	return jobs, nil
+}
+
+// Remove handled jobs. A job queued again for a higher height than the handled one stays and is unlocked
+func (r *queueRepository) Complete(kind string, keys []string, height uint64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, key := range keys {
+		value, ok := r.store.get(tableJobs, jobKey{kind, key})
+		if !ok {
+			continue
+		}
+		if value.(*queue.Job).Height <= height {
+			r.store.remove(r.tx, tableJobs, jobKey{kind, key})
+			continue
+		}
+		unlocked := *value.(*queue.Job)
+		unlocked.LockedUntil = nil
+		r.store.put(r.tx, tableJobs, jobKey{kind, key}, noBlock, &unlocked)
+	}
+	return nil
+}
+
+func (r *queueRepository) Count(kind string) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	count := 0
+	r.store.each(tableJobs, func(key interface{}, value interface{}) {
+		if key.(jobKey).kind == kind {
+			count++
+		}
+	})
+	return count, nil
+}
+
+// Lowest height of queued jobs of the kind, 0 if there are none
+func (r *queueRepository) MinHeight(kind string) (uint64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var height uint64
+	r.store.each(tableJobs, func(key interface{}, value interface{}) {
+		job := value.(*queue.Job)
+		if job.Kind == kind && (height == 0 || job.Height < height) {
+			height = job.Height
+		}
+	})
+	return height, nil
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"github.com/MinterTeam/minter-explorer-extender/scheduler"
+	"github.com/go-pg/pg"
+	"sort"
+)
+
+type schedulerRepository struct {
+	store *Store
+	tx    *pg.Tx
+}
+
+func NewSchedulerRepository(store *Store) scheduler.Repository {
+	return &schedulerRepository{
+		store: store,
+	}
+}
+
+func (r *schedulerRepository) WithTx(tx *pg.Tx) scheduler.Repository {
+	return &schedulerRepository{
+		store: r.store,
+		tx:    tx,
+	}
+}
+
+// Replace the last run of the job
+func (r *schedulerRepository) Save(run *scheduler.Run) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	saved := *run
+	r.store.put(r.tx, tableScheduledJobs, run.Job, noBlock, &saved)
+	return nil
+}
+
+func (r *schedulerRepository) GetAll() ([]*scheduler.Run, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var runs []*scheduler.Run
+	r.store.each(tableScheduledJobs, func(key interface{}, value interface{}) {
+		run := *value.(*scheduler.Run)
+		runs = append(runs, &run)
+	})
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Job < runs[j].Job
+	})
+	return runs, nil
+}
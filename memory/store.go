@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"github.com/go-pg/pg"
+	"sync"
+)
+
+// Names of tables kept by the store, the same as in PostgreSQL
+const (
+	tableAddresses         = "addresses"
+	tableBalances          = "balances"
+	tableBlocks            = "blocks"
+	tableBlockValidators   = "block_validator"
+	tableCoins             = "coins"
+	tableValidators        = "validators"
+	tableStakes            = "stakes"
+	tableTransactions      = "transactions"
+	tableInvalidTxs        = "invalid_transactions"
+	tableTxOutputs         = "transaction_outputs"
+	tableTxValidators      = "transaction_validator"
+	tableTxIndex           = "index_transaction_by_address"
+	tableRewards           = "rewards"
+	tableSlashes           = "slashes"
+	tableAggregatedRewards = "aggregated_rewards"
+	tableCursors           = "stage_cursors"
+	tableQuarantine        = "quarantine"
+	tableJobs              = "refresh_jobs"
+	tableLeases            = "leader_leases"
+	tableScheduledJobs     = "scheduled_jobs"
+	tablePartitions        = "partitions"
+)
+
+// Height of rows of tables which are not derived from blocks
+const noBlock uint64 = 0
+
+// All tables of the extender kept in memory instead of PostgreSQL, used by tests and demo deployments.
+// Data is lost when the process exits
+type Store struct {
+	mu         sync.Mutex
+	tables     map[string]*table
+	sequences  map[string]uint64
+	addressIds map[string]uint64 // addresses are never deleted, so they are also indexed by value
+	undo       map[*pg.Tx][]func()
+}
+
+// Row with the height it belongs to. Rows of tables which are not derived from blocks have no height
+type row struct {
+	blockId uint64
+	value   interface{}
+}
+
+// Rows by their key, which is the primary key of the PostgreSQL table or a generated id if it has none
+type table struct {
+	rows    map[interface{}]row
+	byBlock map[uint64]map[interface{}]struct{}
+}
+
+func NewStore() *Store {
+	return &Store{
+		tables:     make(map[string]*table),
+		sequences:  make(map[string]uint64),
+		addressIds: make(map[string]uint64),
+		undo:       make(map[*pg.Tx][]func()),
+	}
+}
+
+// Run the function inside a transaction with the same signature as *pg.DB, so the store can replace it.
+// Changes made by repositories bound to the transaction are reverted if the function fails,
+// changes made by other repositories in the meantime are kept
+func (s *Store) RunInTransaction(fn func(tx *pg.Tx) error) (err error) {
+	// The transaction is only a key of its changes, it never reaches PostgreSQL
+	tx := new(pg.Tx)
+	s.mu.Lock()
+	s.undo[tx] = nil
+	s.mu.Unlock()
+
+	defer func() {
+		p := recover()
+		s.mu.Lock()
+		if p != nil || err != nil {
+			changes := s.undo[tx]
+			for i := len(changes) - 1; i >= 0; i-- {
+				changes[i]()
+			}
+		}
+		delete(s.undo, tx)
+		s.mu.Unlock()
+		if p != nil {
+			panic(p)
+		}
+	}()
+	return fn(tx)
+}
+
+// Should be called with the lock held, as all methods below
+func (s *Store) table(name string) *table {
+	t, ok := s.tables[name]
+	if !ok {
+		t = &table{
+			rows:    make(map[interface{}]row),
+			byBlock: make(map[uint64]map[interface{}]struct{}),
+		}
+		s.tables[name] = t
+	}
+	return t
+}
+
+// Next value of the sequence of the table. As in PostgreSQL, values taken by a reverted transaction are not reused
+func (s *Store) nextId(name string) uint64 {
+	s.sequences[name]++
+	return s.sequences[name]
+}
+
+func (s *Store) get(name string, key interface{}) (interface{}, bool) {
+	r, ok := s.table(name).rows[key]
+	return r.value, ok
+}
+
+// Insert or replace the row. Values are never changed in place, a changed row is put again
+func (s *Store) put(tx *pg.Tx, name string, key interface{}, blockId uint64, value interface{}) {
+	t := s.table(name)
+	old, exists := t.rows[key]
+	t.set(key, row{blockId: blockId, value: value})
+	s.onRollback(tx, func() {
+		if exists {
+			t.set(key, old)
+		} else {
+			t.delete(key)
+		}
+	})
+}
+
+func (s *Store) remove(tx *pg.Tx, name string, key interface{}) {
+	t := s.table(name)
+	old, exists := t.rows[key]
+	if !exists {
+		return
+	}
+	t.delete(key)
+	s.onRollback(tx, func() {
+		t.set(key, old)
+	})
+}
+
+// Call the function for all rows of the table
+func (s *Store) each(name string, fn func(key interface{}, value interface{})) {
+	for key, r := range s.table(name).rows {
+		fn(key, r.value)
+	}
+}
+
+// Call the function for rows of the table which belong to the height
+func (s *Store) eachAt(name string, height uint64, fn func(key interface{}, value interface{})) {
+	t := s.table(name)
+	for key := range t.byBlock[height] {
+		fn(key, t.rows[key].value)
+	}
+}
+
+// Heights which have rows in the table
+func (s *Store) heights(name string) []uint64 {
+	t := s.table(name)
+	heights := make([]uint64, 0, len(t.byBlock))
+	for height := range t.byBlock {
+		heights = append(heights, height)
+	}
+	return heights
+}
+
+// Delete rows of heights matching the condition and return their count
+func (s *Store) removeBlocks(tx *pg.Tx, name string, match func(height uint64) bool) int {
+	count := 0
+	for _, height := range s.heights(name) {
+		if height == noBlock || !match(height) {
+			continue
+		}
+		var keys []interface{}
+		s.eachAt(name, height, func(key interface{}, value interface{}) {
+			keys = append(keys, key)
+		})
+		for _, key := range keys {
+			s.remove(tx, name, key)
+		}
+		count += len(keys)
+	}
+	return count
+}
+
+// Remember how to revert a change if it is made inside a transaction
+func (s *Store) onRollback(tx *pg.Tx, revert func()) {
+	if tx == nil {
+		return
+	}
+	s.undo[tx] = append(s.undo[tx], revert)
+}
+
+func (t *table) set(key interface{}, r row) {
+	t.delete(key)
+	t.rows[key] = r
+	keys, ok := t.byBlock[r.blockId]
+	if !ok {
+		keys = make(map[interface{}]struct{})
+		t.byBlock[r.blockId] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+func (t *table) delete(key interface{}) {
+	r, ok := t.rows[key]
+	if !ok {
+		return
+	}
+	delete(t.rows, key)
+	delete(t.byBlock[r.blockId], key)
+	if len(t.byBlock[r.blockId]) == 0 {
+		delete(t.byBlock, r.blockId)
+	}
+}
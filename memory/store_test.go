@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"errors"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/go-pg/pg"
+	"testing"
+)
+
+func TestRunInTransactionRevertsChangesOnError(t *testing.T) {
+	store := NewStore()
+	coins := NewCoinRepository(store)
+	err := coins.Save(&models.Coin{Symbol: "MNT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("failure")
+	err = store.RunInTransaction(func(tx *pg.Tx) error {
+		repository := coins.WithTx(tx)
+		if err := repository.Save(&models.Coin{Symbol: "TEST"}); err != nil {
+			return err
+		}
+		if err := repository.DeleteBySymbol("MNT"); err != nil {
+			return err
+		}
+		// Made outside the transaction, so it is kept
+		if err := coins.Save(&models.Coin{Symbol: "OTHER"}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("RunInTransaction() = %v, want the error of the function", err)
+	}
+
+	if _, err := coins.FindIdBySymbol("TEST"); err != pg.ErrNoRows {
+		t.Errorf("coin created in the transaction: err = %v, want pg.ErrNoRows", err)
+	}
+	if _, err := coins.FindIdBySymbol("MNT"); err != nil {
+		t.Errorf("coin deleted in the transaction is not restored: %v", err)
+	}
+	if _, err := coins.FindIdBySymbol("OTHER"); err != nil {
+		t.Errorf("coin created outside the transaction is reverted: %v", err)
+	}
+}
+
+func TestRunInTransactionRevertsChangesOnPanic(t *testing.T) {
+	store := NewStore()
+	coins := NewCoinRepository(store)
+
+	func() {
+		defer func() {
+			if p := recover(); p != "panic" {
+				t.Errorf("recover() = %v, want the panic of the function", p)
+			}
+		}()
+		_ = store.RunInTransaction(func(tx *pg.Tx) error {
+			if err := coins.WithTx(tx).Save(&models.Coin{Symbol: "TEST"}); err != nil {
+				return err
+			}
+			panic("panic")
+		})
+	}()
+
+	if _, err := coins.FindIdBySymbol("TEST"); err != pg.ErrNoRows {
+		t.Errorf("coin created before the panic: err = %v, want pg.ErrNoRows", err)
+	}
+}
+
+func TestRunInTransactionKeepsChangesOnCommit(t *testing.T) {
+	store := NewStore()
+	coins := NewCoinRepository(store)
+
+	err := store.RunInTransaction(func(tx *pg.Tx) error {
+		return coins.WithTx(tx).Save(&models.Coin{Symbol: "TEST"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coins.FindIdBySymbol("TEST"); err != nil {
+		t.Errorf("committed coin is not found: %v", err)
+	}
+	if len(store.undo) != 0 {
+		t.Errorf("%d transactions are left in the undo log", len(store.undo))
+	}
+}
+
+func TestRevertedIdsAreNotReused(t *testing.T) {
+	store := NewStore()
+	coins := NewCoinRepository(store)
+
+	_ = store.RunInTransaction(func(tx *pg.Tx) error {
+		if err := coins.WithTx(tx).Save(&models.Coin{Symbol: "TEST"}); err != nil {
+			return err
+		}
+		return errors.New("failure")
+	})
+	c := &models.Coin{Symbol: "MNT"}
+	if err := coins.Save(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.ID != 2 {
+		t.Errorf("id = %d, want 2 as the sequence of PostgreSQL", c.ID)
+	}
+}
@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"github.com/MinterTeam/minter-explorer-extender/transaction"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/go-pg/pg"
+)
+
+// Primary key of index_transaction_by_address
+type txIndexKey struct {
+	blockId       uint64
+	addressId     uint64
+	transactionId uint64
+}
+
+type transactionRepository struct {
+	store *Store
+	tx    *pg.Tx
+}
+
+func NewTransactionRepository(store *Store) transaction.Repository {
+	return &transactionRepository{
+		store: store,
+	}
+}
+
+func (r *transactionRepository) WithTx(tx *pg.Tx) transaction.Repository {
+	return &transactionRepository{
+		store: r.store,
+		tx:    tx,
+	}
+}
+
+func (r *transactionRepository) Save(t *models.Transaction) error {
+	return r.SaveAll([]*models.Transaction{t})
+}
+
+func (r *transactionRepository) SaveAll(transactions []*models.Transaction) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, t := range transactions {
+		t.ID = r.store.nextId(tableTransactions)
+		saved := *t
+		r.store.put(r.tx, tableTransactions, t.ID, t.BlockID, &saved)
+	}
+	return nil
+}
+
+func (r *transactionRepository) SaveAllInvalid(transactions []*models.InvalidTransaction) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, t := range transactions {
+		t.ID = r.store.nextId(tableInvalidTxs)
+		saved := *t
+		r.store.put(r.tx, tableInvalidTxs, t.ID, t.BlockID, &saved)
+	}
+	return nil
+}
+
+func (r *transactionRepository) SaveAllTxOutputs(outputs []*models.TransactionOutput, blockId uint64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, output := range outputs {
+		output.ID = r.store.nextId(tableTxOutputs)
+		saved := *output
+		r.store.put(r.tx, tableTxOutputs, output.ID, blockId, &saved)
+	}
+	return nil
+}
+
+func (r *transactionRepository) LinkWithValidators(links []*models.TransactionValidator) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, l := range links {
+		var blockId uint64
+		if value, ok := r.store.get(tableTransactions, l.TransactionID); ok {
+			blockId = value.(*models.Transaction).BlockID
+		}
+		saved := *l
+		r.store.put(r.tx, tableTxValidators, r.store.nextId(tableTxValidators), blockId, &saved)
+	}
+	return nil
+}
+
+func (r *transactionRepository) IndexTxAddress(txsId []uint64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	ids := make(map[uint64]struct{}, len(txsId))
+	heights := make(map[uint64]struct{})
+	for _, id := range txsId {
+		value, ok := r.store.get(tableTransactions, id)
+		if !ok {
+			continue
+		}
+		ids[id] = struct{}{}
+		heights[value.(*models.Transaction).BlockID] = struct{}{}
+	}
+	for height := range heights {
+		r.store.indexTxs(r.tx, height, func(id uint64) bool {
+			_, ok := ids[id]
+			return ok
+		})
+	}
+	return nil
+}
+
+func (r *transactionRepository) IndexTxAddressInRange(from, to uint64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for height := from + 1; height <= to; height++ {
+		r.store.indexTxs(r.tx, height, func(id uint64) bool {
+			return true
+		})
+	}
+	return nil
+}
+
+// Index senders and recipients of the transactions of the height which match the condition
+func (s *Store) indexTxs(tx *pg.Tx, height uint64, match func(id uint64) bool) {
+	var keys []txIndexKey
+	s.eachAt(tableTransactions, height, func(key interface{}, value interface{}) {
+		t := value.(*models.Transaction)
+		if match(t.ID) {
+			keys = append(keys, txIndexKey{blockId: height, addressId: t.FromAddressID, transactionId: t.ID})
+		}
+	})
+	s.eachAt(tableTxOutputs, height, func(key interface{}, value interface{}) {
+		output := value.(*models.TransactionOutput)
+		if match(output.TransactionID) {
+			keys = append(keys, txIndexKey{blockId: height, addressId: output.ToAddressID, transactionId: output.TransactionID})
+		}
+	})
+	for _, key := range keys {
+		if _, exists := s.get(tableTxIndex, key); !exists {
+			s.put(tx, tableTxIndex, key, height, key)
+		}
+	}
+}
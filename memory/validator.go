@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"github.com/MinterTeam/minter-explorer-extender/validator"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/go-pg/pg"
+	"sync"
+)
+
+// Unique key of stakes
+type stakeKey struct {
+	ownerAddressId uint64
+	validatorId    uint64
+	coinId         uint64
+}
+
+type validatorRepository struct {
+	store        *Store
+	placeholders *sync.Map
+}
+
+func NewValidatorRepository(store *Store) validator.Repository {
+	return &validatorRepository{
+		store:        store,
+		placeholders: new(sync.Map),
+	}
+}
+
+func (r *validatorRepository) FindIdByPk(pk string) (uint64, error) {
+	id, ok := r.placeholders.Load(pk)
+	if ok {
+		return id.(uint64), nil
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	v := r.store.validatorByPk(pk)
+	if v == nil {
+		return 0, pg.ErrNoRows
+	}
+	return v.ID, nil
+}
+
+func (r *validatorRepository) CachePlaceholder(pk string, id uint64) {
+	r.placeholders.Store(pk, id)
+}
+
+func (r *validatorRepository) FindIdByPkOrCreate(pk string) (uint64, error) {
+	id, ok := r.placeholders.Load(pk)
+	if ok {
+		return id.(uint64), nil
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if v := r.store.validatorByPk(pk); v != nil {
+		return v.ID, nil
+	}
+	return r.store.insertValidator(&models.Validator{PublicKey: pk}), nil
+}
+
+// Create validators with public keys which are not stored yet
+func (r *validatorRepository) SaveAllIfNotExist(validators []*models.Validator) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, v := range validators {
+		if r.store.validatorByPk(v.PublicKey) == nil {
+			r.store.insertValidator(v)
+		}
+	}
+	return nil
+}
+
+func (r *validatorRepository) FindAllByPK(validators []*models.Validator) ([]*models.Validator, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var list []*models.Validator
+	for _, v := range validators {
+		if found := r.store.validatorByPk(v.PublicKey); found != nil {
+			c := *found
+			list = append(list, &c)
+		}
+	}
+	return list, nil
+}
+
+// Update status, commission, addresses and total stake of the validators
+func (r *validatorRepository) UpdateAll(validators []*models.Validator) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, v := range validators {
+		value, ok := r.store.get(tableValidators, v.ID)
+		if !ok {
+			continue
+		}
+		updated := *value.(*models.Validator)
+		updated.Status = v.Status
+		updated.Commission = v.Commission
+		updated.RewardAddressID = v.RewardAddressID
+		updated.OwnerAddressID = v.OwnerAddressID
+		updated.TotalStake = v.TotalStake
+		r.store.put(nil, tableValidators, v.ID, noBlock, &updated)
+	}
+	return nil
+}
+
+func (r *validatorRepository) Update(v *models.Validator) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if _, ok := r.store.get(tableValidators, v.ID); !ok {
+		return pg.ErrNoRows
+	}
+	updated := *v
+	r.store.put(nil, tableValidators, v.ID, noBlock, &updated)
+	return nil
+}
+
+func (r *validatorRepository) DeleteStakesNotInListIds(idList []uint64) error {
+	if len(idList) == 0 {
+		return nil
+	}
+	keep := make(map[uint64]struct{}, len(idList))
+	for _, id := range idList {
+		keep[id] = struct{}{}
+	}
+	return r.deleteStakes(func(s *models.Stake) bool {
+		_, ok := keep[s.ID]
+		return !ok
+	})
+}
+
+func (r *validatorRepository) DeleteStakesByValidatorIds(idList []uint64) error {
+	if len(idList) == 0 {
+		return nil
+	}
+	ids := make(map[uint64]struct{}, len(idList))
+	for _, id := range idList {
+		ids[id] = struct{}{}
+	}
+	return r.deleteStakes(func(s *models.Stake) bool {
+		_, ok := ids[s.ValidatorID]
+		return ok
+	})
+}
+
+// Create stakes or replace existing ones of the same owner, validator and coin
+func (r *validatorRepository) SaveAllStakes(stakes []*models.Stake) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	existing := make(map[stakeKey]uint64)
+	r.store.each(tableStakes, func(key interface{}, value interface{}) {
+		s := value.(*models.Stake)
+		existing[stakeKey{s.OwnerAddressID, s.ValidatorID, s.CoinID}] = s.ID
+	})
+	for _, s := range stakes {
+		k := stakeKey{s.OwnerAddressID, s.ValidatorID, s.CoinID}
+		id, ok := existing[k]
+		if !ok {
+			id = r.store.nextId(tableStakes)
+			existing[k] = id
+		}
+		s.ID = id
+		saved := *s
+		r.store.put(nil, tableStakes, id, noBlock, &saved)
+	}
+	return nil
+}
+
+func (r *validatorRepository) ResetAllStatuses() error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var validators []*models.Validator
+	r.store.each(tableValidators, func(key interface{}, value interface{}) {
+		v := *value.(*models.Validator)
+		v.Status = nil
+		validators = append(validators, &v)
+	})
+	for _, v := range validators {
+		r.store.put(nil, tableValidators, v.ID, noBlock, v)
+	}
+	return nil
+}
+
+func (r *validatorRepository) deleteStakes(match func(s *models.Stake) bool) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var ids []interface{}
+	r.store.each(tableStakes, func(key interface{}, value interface{}) {
+		if match(value.(*models.Stake)) {
+			ids = append(ids, key)
+		}
+	})
+	for _, id := range ids {
+		r.store.remove(nil, tableStakes, id)
+	}
+	return nil
+}
+
+func (s *Store) validatorByPk(pk string) *models.Validator {
+	var found *models.Validator
+	s.each(tableValidators, func(key interface{}, value interface{}) {
+		if v := value.(*models.Validator); v.PublicKey == pk {
+			found = v
+		}
+	})
+	return found
+}
+
+// Store the validator with a new id and return the id
+func (s *Store) insertValidator(v *models.Validator) uint64 {
+	v.ID = s.nextId(tableValidators)
+	saved := *v
+	s.put(nil, tableValidators, v.ID, noBlock, &saved)
+	return v.ID
+}
@@ -1,33 +1,8 @@
---
--- PostgreSQL database dump
---
-
--- Dumped from database version 9.5.14
--- Dumped by pg_dump version 9.5.14
-
-SET statement_timeout = 0;
-SET lock_timeout = 0;
-SET client_encoding = 'UTF8';
-SET standard_conforming_strings = on;
-SELECT pg_catalog.set_config('search_path', '', false);
-SET check_function_bodies = false;
-SET client_min_messages = warning;
-SET row_security = off;
-
---
--- Name: plpgsql; Type: EXTENSION; Schema: -; Owner: 
---
-
-CREATE EXTENSION IF NOT EXISTS plpgsql WITH SCHEMA pg_catalog;
-
-
---
--- Name: EXTENSION plpgsql; Type: COMMENT; Schema: -; Owner: 
---
-
-COMMENT ON EXTENSION plpgsql IS 'PL/pgSQL procedural language';
-
+package migrations
 
+// Schema of the database dump used before migrations
+func init() {
+	register(1, "initial_schema", `
 --
 -- Name: rewards_role; Type: TYPE; Schema: public; Owner: minter
 --
@@ -39,12 +14,6 @@ CREATE TYPE public.rewards_role AS ENUM (
     'Developers'
     );
 
-
-
-SET default_tablespace = '';
-
-SET default_with_oids = false;
-
 --
 -- Name: addresses; Type: TABLE; Schema: public; Owner: minter
 --
@@ -57,15 +26,12 @@ CREATE TABLE public.addresses
     updated_at_block_id bigint
 );
 
-
-
 --
 -- Name: COLUMN addresses.address; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.addresses.address IS 'Address hex string without prefix(Mx****)';
 
-
 --
 -- Name: COLUMN addresses.updated_at; Type: COMMENT; Schema: public; Owner: minter
 --
@@ -78,7 +44,6 @@ COMMENT ON COLUMN public.addresses.updated_at IS 'Last balance parsing time';
 
 COMMENT ON COLUMN public.addresses.updated_at_block_id IS 'Block id, that have transactions or events, that triggers address record to update from api-method GET /address';
 
-
 --
 -- Name: addresses_id_seq; Type: SEQUENCE; Schema: public; Owner: minter
 --
@@ -90,15 +55,12 @@ CREATE SEQUENCE public.addresses_id_seq
     NO MAXVALUE
     CACHE 1;
 
-
-
 --
 -- Name: addresses_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: minter
 --
 
 ALTER SEQUENCE public.addresses_id_seq OWNED BY public.addresses.id;
 
-
 --
 -- Name: balances; Type: TABLE; Schema: public; Owner: minter
 --
@@ -111,8 +73,6 @@ CREATE TABLE public.balances
     value      numeric(70, 0) NOT NULL
 );
 
-
-
 --
 -- Name: balances_id_seq; Type: SEQUENCE; Schema: public; Owner: minter
 --
@@ -124,15 +84,12 @@ CREATE SEQUENCE public.balances_id_seq
     NO MAXVALUE
     CACHE 1;
 
-
-
 --
 -- Name: balances_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: minter
 --
 
 ALTER SEQUENCE public.balances_id_seq OWNED BY public.balances.id;
 
-
 --
 -- Name: block_validator; Type: TABLE; Schema: public; Owner: minter
 --
@@ -144,8 +101,6 @@ CREATE TABLE public.block_validator
     signed       boolean DEFAULT false NOT NULL
 );
 
-
-
 --
 -- Name: blocks; Type: TABLE; Schema: public; Owner: minter
 --
@@ -164,71 +119,60 @@ CREATE TABLE public.blocks
     hash                  character varying(64)    NOT NULL
 );
 
-
-
 --
 -- Name: TABLE blocks; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON TABLE public.blocks IS 'Address entity table';
 
-
 --
 -- Name: COLUMN blocks.total_txs; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.blocks.total_txs IS 'Total count of txs in blockchain';
 
-
 --
 -- Name: COLUMN blocks.proposer_validator_id; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.blocks.proposer_validator_id IS 'Proposer public key (Mp***)';
 
-
 --
 -- Name: COLUMN blocks.num_txs; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.blocks.num_txs IS 'Count of txs in block';
 
-
 --
 -- Name: COLUMN blocks.block_time; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.blocks.block_time IS 'Block operation time (???) in microseconds';
 
-
 --
 -- Name: COLUMN blocks.created_at; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.blocks.created_at IS 'Datetime of block creation("time" field from api)';
 
-
 --
 -- Name: COLUMN blocks.updated_at; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.blocks.updated_at IS 'Time of record last update';
 
-
 --
 -- Name: COLUMN blocks.block_reward; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.blocks.block_reward IS 'Sum of all block rewards';
 
-
 --
 -- Name: COLUMN blocks.hash; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.blocks.hash IS 'Hex string';
 
-
 --
 -- Name: coins; Type: TABLE; Schema: public; Owner: minter
 --
@@ -247,22 +191,18 @@ CREATE TABLE public.coins
     deleted_at              timestamp with time zone               NULL
 );
 
-
-
 --
 -- Name: COLUMN coins.creation_address_id; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.coins.creation_address_id IS 'Id of creator address in address table';
 
-
 --
 -- Name: COLUMN coins.updated_at; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.coins.updated_at IS 'Timestamp of coin balance/value updation(from api for example)';
 
-
 --
 -- Name: COLUMN coins.reserve_balance; Type: COMMENT; Schema: public; Owner: minter
 --
@@ -270,21 +210,18 @@ COMMENT ON COLUMN public.coins.updated_at IS 'Timestamp of coin balance/value up
 COMMENT ON COLUMN public.coins.reserve_balance IS 'Reservation balance for coin creation
 ';
 
-
 --
 -- Name: COLUMN coins.name; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.coins.name IS 'Name of coin';
 
-
 --
 -- Name: COLUMN coins.symbol; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.coins.symbol IS 'Short symbol of coin';
 
-
 --
 -- Name: coins_id_seq; Type: SEQUENCE; Schema: public; Owner: minter
 --
@@ -296,15 +233,12 @@ CREATE SEQUENCE public.coins_id_seq
     NO MAXVALUE
     CACHE 1;
 
-
-
 --
 -- Name: coins_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: minter
 --
 
 ALTER SEQUENCE public.coins_id_seq OWNED BY public.coins.id;
 
-
 --
 -- Name: invalid_transactions; Type: TABLE; Schema: public; Owner: minter
 --
@@ -320,15 +254,12 @@ CREATE TABLE public.invalid_transactions
     tx_data         jsonb                    NOT NULL
 );
 
-
-
 --
 -- Name: COLUMN invalid_transactions.created_at; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.invalid_transactions.created_at IS 'Duplicate of block created_at for less joins listings';
 
-
 --
 -- Name: invalid_transactions_id_seq; Type: SEQUENCE; Schema: public; Owner: minter
 --
@@ -340,15 +271,12 @@ CREATE SEQUENCE public.invalid_transactions_id_seq
     NO MAXVALUE
     CACHE 1;
 
-
-
 --
 -- Name: invalid_transactions_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: minter
 --
 
 ALTER SEQUENCE public.invalid_transactions_id_seq OWNED BY public.invalid_transactions.id;
 
-
 --
 -- Name: rewards; Type: TABLE; Schema: public; Owner: minter
 --
@@ -362,8 +290,6 @@ CREATE TABLE public.rewards
     amount       numeric(70, 0)      NOT NULL
 );
 
-
-
 --
 -- Name: aggregated_rewards; Type: TABLE; Schema: public; Owner: minter
 --
@@ -379,8 +305,6 @@ CREATE TABLE public.aggregated_rewards
   amount        numeric(70, 0)           NOT NULL
 );
 
-
-
 --
 -- Name: slashes; Type: TABLE; Schema: public; Owner: minter
 --
@@ -395,8 +319,6 @@ CREATE TABLE public.slashes
     amount       numeric(70, 0) NOT NULL
 );
 
-
-
 --
 -- Name: slashes_id_seq; Type: SEQUENCE; Schema: public; Owner: minter
 --
@@ -408,15 +330,12 @@ CREATE SEQUENCE public.slashes_id_seq
     NO MAXVALUE
     CACHE 1;
 
-
-
 --
 -- Name: slashes_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: minter
 --
 
 ALTER SEQUENCE public.slashes_id_seq OWNED BY public.slashes.id;
 
-
 --
 -- Name: stakes; Type: TABLE; Schema: public; Owner: minter
 --
@@ -444,15 +363,12 @@ CREATE TABLE public.transaction_outputs
   value          numeric(70, 0) NOT NULL
 );
 
-
-
 --
 -- Name: COLUMN transaction_outputs.value; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.transaction_outputs.value IS 'Value of tx output';
 
-
 --
 -- Name: transaction_outputs_id_seq; Type: SEQUENCE; Schema: public; Owner: minter
 --
@@ -464,15 +380,12 @@ CREATE SEQUENCE public.transaction_outputs_id_seq
     NO MAXVALUE
     CACHE 1;
 
-
-
 --
 -- Name: transaction_outputs_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: minter
 --
 
 ALTER SEQUENCE public.transaction_outputs_id_seq OWNED BY public.transaction_outputs.id;
 
-
 --
 -- Name: transaction_validator; Type: TABLE; Schema: public; Owner: minter
 --
@@ -483,8 +396,6 @@ CREATE TABLE public.transaction_validator
     validator_id   integer NOT NULL
 );
 
-
-
 --
 -- Name: transactions; Type: TABLE; Schema: public; Owner: minter
 --
@@ -508,29 +419,24 @@ CREATE TABLE public.transactions
     raw_tx          bytea                    NOT NULL
 );
 
-
-
 --
 -- Name: COLUMN transactions.from_address_id; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.transactions.from_address_id IS 'Link to address, from that tx was signed';
 
-
 --
 -- Name: COLUMN transactions.block_id; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.transactions.block_id IS 'Link to block';
 
-
 --
 -- Name: COLUMN transactions.created_at; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.transactions.created_at IS 'Timestamp of tx = timestamp of block. Duplicate data for less joins on blocks';
 
-
 --
 -- Name: COLUMN transactions.type; Type: COMMENT; Schema: public; Owner: minter
 --
@@ -543,21 +449,18 @@ COMMENT ON COLUMN public.transactions.type IS 'Integer index of tx type';
 
 COMMENT ON COLUMN public.transactions.hash IS 'Tx hash 64 symbols hex string without prefix(Mt****). Because of key-value-only filtering uses hash index';
 
-
 --
 -- Name: COLUMN transactions.payload; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.transactions.payload IS 'transaction payload in bytes';
 
-
 --
 -- Name: COLUMN transactions.raw_tx; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON COLUMN public.transactions.raw_tx IS 'Raw tx data in bytes';
 
-
 --
 -- Name: transactions_id_seq; Type: SEQUENCE; Schema: public; Owner: minter
 --
@@ -569,15 +472,12 @@ CREATE SEQUENCE public.transactions_id_seq
     NO MAXVALUE
     CACHE 1;
 
-
-
 --
 -- Name: transactions_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: minter
 --
 
 ALTER SEQUENCE public.transactions_id_seq OWNED BY public.transactions.id;
 
-
 --
 -- Name: validators; Type: TABLE; Schema: public; Owner: minter
 --
@@ -595,15 +495,12 @@ CREATE TABLE public.validators
     update_at           timestamp with time zone DEFAULT now() NOT NULL
 );
 
-
-
 --
 -- Name: TABLE validators; Type: COMMENT; Schema: public; Owner: minter
 --
 
 COMMENT ON TABLE public.validators IS 'ATTENTION - only public _ey is not null field, other fields can be null';
 
-
 --
 -- Name: validator_public_keys_id_seq; Type: SEQUENCE; Schema: public; Owner: minter
 --
@@ -615,15 +512,12 @@ CREATE SEQUENCE public.validator_public_keys_id_seq
     NO MAXVALUE
     CACHE 1;
 
-
-
 --
 -- Name: validator_public_keys_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: minter
 --
 
 ALTER SEQUENCE public.validator_public_keys_id_seq OWNED BY public.validators.id;
 
-
 --
 -- Name: index_transaction_by_address; Type: TABLE; Schema: public; Owner: minter
 --
@@ -642,7 +536,6 @@ CREATE TABLE public.index_transaction_by_address
 ALTER TABLE ONLY public.addresses
     ALTER COLUMN id SET DEFAULT nextval('public.addresses_id_seq'::regclass);
 
-
 --
 -- Name: id; Type: DEFAULT; Schema: public; Owner: minter
 --
@@ -650,7 +543,6 @@ ALTER TABLE ONLY public.addresses
 ALTER TABLE ONLY public.balances
     ALTER COLUMN id SET DEFAULT nextval('public.balances_id_seq'::regclass);
 
-
 --
 -- Name: id; Type: DEFAULT; Schema: public; Owner: minter
 --
@@ -658,7 +550,6 @@ ALTER TABLE ONLY public.balances
 ALTER TABLE ONLY public.coins
     ALTER COLUMN id SET DEFAULT nextval('public.coins_id_seq'::regclass);
 
-
 --
 -- Name: id; Type: DEFAULT; Schema: public; Owner: minter
 --
@@ -666,7 +557,6 @@ ALTER TABLE ONLY public.coins
 ALTER TABLE ONLY public.invalid_transactions
     ALTER COLUMN id SET DEFAULT nextval('public.invalid_transactions_id_seq'::regclass);
 
-
 --
 -- Name: id; Type: DEFAULT; Schema: public; Owner: minter
 --
@@ -681,7 +571,6 @@ ALTER TABLE ONLY public.slashes
 ALTER TABLE ONLY public.transaction_outputs
     ALTER COLUMN id SET DEFAULT nextval('public.transaction_outputs_id_seq'::regclass);
 
-
 --
 -- Name: id; Type: DEFAULT; Schema: public; Owner: minter
 --
@@ -689,7 +578,6 @@ ALTER TABLE ONLY public.transaction_outputs
 ALTER TABLE ONLY public.transactions
     ALTER COLUMN id SET DEFAULT nextval('public.transactions_id_seq'::regclass);
 
-
 --
 -- Name: id; Type: DEFAULT; Schema: public; Owner: minter
 --
@@ -697,170 +585,6 @@ ALTER TABLE ONLY public.transactions
 ALTER TABLE ONLY public.validators
     ALTER COLUMN id SET DEFAULT nextval('public.validator_public_keys_id_seq'::regclass);
 
-
---
--- Data for Name: addresses; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.addresses (id, address, updated_at) FROM stdin;
-\.
-
-
---
--- Name: addresses_id_seq; Type: SEQUENCE SET; Schema: public; Owner: minter
---
-
-SELECT pg_catalog.setval('public.addresses_id_seq', 1, false);
-
-
---
--- Data for Name: balances; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.balances (id, address_id, coin_id, value) FROM stdin;
-\.
-
-
---
--- Name: balances_id_seq; Type: SEQUENCE SET; Schema: public; Owner: minter
---
-
-SELECT pg_catalog.setval('public.balances_id_seq', 1, false);
-
-
---
--- Data for Name: block_validator; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.block_validator (block_id, validator_id, signed) FROM stdin;
-\.
-
-
---
--- Data for Name: blocks; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.blocks (id, total_txs, size, proposer_validator_id, num_txs, block_time, created_at, updated_at,
-                    block_reward, hash) FROM stdin;
-\.
-
-
---
--- Data for Name: coins; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.coins (id, creation_address_id, creation_transaction_id, crr, updated_at, volume,
-                   reserve_balance, name, symbol) FROM stdin;
-\.
-
-
---
--- Name: coins_id_seq; Type: SEQUENCE SET; Schema: public; Owner: minter
---
-
-SELECT pg_catalog.setval('public.coins_id_seq', 1, false);
-
-
---
--- Data for Name: invalid_transactions; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.invalid_transactions (id, from_address_id, block_id, created_at, type, hash, tx_data) FROM stdin;
-\.
-
-
---
--- Name: invalid_transactions_id_seq; Type: SEQUENCE SET; Schema: public; Owner: minter
---
-
-SELECT pg_catalog.setval('public.invalid_transactions_id_seq', 1, false);
-
-
---
--- Data for Name: rewards; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.rewards (address_id, block_id, validator_id, role, amount) FROM stdin;
-\.
-
-
---
--- Data for Name: slashes; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.slashes (id, address_id, block_id, validator_id, coin_id, amount) FROM stdin;
-\.
-
-
---
--- Name: slashes_id_seq; Type: SEQUENCE SET; Schema: public; Owner: minter
---
-
-SELECT pg_catalog.setval('public.slashes_id_seq', 1, false);
-
-
---
--- Data for Name: stakes; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.stakes (owner_address_id, validator_id, coin_id, value, bip_value) FROM stdin;
-\.
-
---
--- Data for Name: transaction_outputs; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.transaction_outputs (id, transaction_id, to_address_id, coin_id, value) FROM stdin;
-\.
-
-
---
--- Name: transaction_outputs_id_seq; Type: SEQUENCE SET; Schema: public; Owner: minter
---
-
-SELECT pg_catalog.setval('public.transaction_outputs_id_seq', 1, false);
-
-
---
--- Data for Name: transaction_validator; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.transaction_validator (transaction_id, validator_id) FROM stdin;
-\.
-
-
---
--- Data for Name: transactions; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.transactions (id, from_address_id, nonce, gas_price, gas, block_id, gas_coin_id, created_at, type, hash,
-                          service_data, data, tags, payload, raw_tx) FROM stdin;
-\.
-
-
---
--- Name: transactions_id_seq; Type: SEQUENCE SET; Schema: public; Owner: minter
---
-
-SELECT pg_catalog.setval('public.transactions_id_seq', 1, false);
-
-
---
--- Name: validator_public_keys_id_seq; Type: SEQUENCE SET; Schema: public; Owner: minter
---
-
-SELECT pg_catalog.setval('public.validator_public_keys_id_seq', 1, false);
-
-
---
--- Data for Name: validators; Type: TABLE DATA; Schema: public; Owner: minter
---
-
-COPY public.validators (id, reward_address_id, owner_address_id, created_at_block_id, status, commission, total_stake,
-                        public_key, update_at) FROM stdin;
-\.
-
-
 --
 -- Name: addresses_pkey; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -868,7 +592,6 @@ COPY public.validators (id, reward_address_id, owner_address_id, created_at_bloc
 ALTER TABLE ONLY public.addresses
     ADD CONSTRAINT addresses_pkey PRIMARY KEY (id);
 
-
 --
 -- Name: balances_pkey; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -876,7 +599,6 @@ ALTER TABLE ONLY public.addresses
 ALTER TABLE ONLY public.balances
     ADD CONSTRAINT balances_pkey PRIMARY KEY (id);
 
-
 --
 -- Name: block_validator_pk; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -884,7 +606,6 @@ ALTER TABLE ONLY public.balances
 ALTER TABLE ONLY public.block_validator
     ADD CONSTRAINT block_validator_pk PRIMARY KEY (block_id, validator_id);
 
-
 --
 -- Name: blocks_pkey; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -892,7 +613,6 @@ ALTER TABLE ONLY public.block_validator
 ALTER TABLE ONLY public.blocks
     ADD CONSTRAINT blocks_pkey PRIMARY KEY (id);
 
-
 --
 -- Name: coins_pkey; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -900,7 +620,6 @@ ALTER TABLE ONLY public.blocks
 ALTER TABLE ONLY public.coins
     ADD CONSTRAINT coins_pkey PRIMARY KEY (id);
 
-
 --
 -- Name: invalid_transactions_pkey; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -915,7 +634,6 @@ ALTER TABLE ONLY public.invalid_transactions
 ALTER TABLE ONLY public.slashes
     ADD CONSTRAINT slashes_pkey PRIMARY KEY (id);
 
-
 --
 -- Name: stakes_pkey; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -923,7 +641,6 @@ ALTER TABLE ONLY public.slashes
 ALTER TABLE ONLY public.stakes
     ADD CONSTRAINT stakes_pkey PRIMARY KEY (validator_id, owner_address_id, coin_id);
 
-
 --
 -- Name: transaction_outputs_pkey; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -931,7 +648,6 @@ ALTER TABLE ONLY public.stakes
 ALTER TABLE ONLY public.transaction_outputs
     ADD CONSTRAINT transaction_outputs_pkey PRIMARY KEY (id);
 
-
 --
 -- Name: transaction_validator_pk; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -939,7 +655,6 @@ ALTER TABLE ONLY public.transaction_outputs
 ALTER TABLE ONLY public.transaction_validator
     ADD CONSTRAINT transaction_validator_pk PRIMARY KEY (transaction_id, validator_id);
 
-
 --
 -- Name: transactions_pkey; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -947,7 +662,6 @@ ALTER TABLE ONLY public.transaction_validator
 ALTER TABLE ONLY public.transactions
     ADD CONSTRAINT transactions_pkey PRIMARY KEY (id);
 
-
 --
 -- Name: validator_public_keys_pkey; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -955,7 +669,6 @@ ALTER TABLE ONLY public.transactions
 ALTER TABLE ONLY public.validators
     ADD CONSTRAINT validator_public_keys_pkey PRIMARY KEY (id);
 
-
 --
 -- Name: index_transaction_by_address index_transaction_by_address_pk; Type: CONSTRAINT; Schema: public; Owner: minter
 --
@@ -963,70 +676,60 @@ ALTER TABLE ONLY public.validators
 ALTER TABLE ONLY public.index_transaction_by_address
     ADD CONSTRAINT index_transaction_by_address_pk PRIMARY KEY (block_id, address_id, transaction_id);
 
-
 --
 -- Name: addresses_address_uindex; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE UNIQUE INDEX addresses_address_uindex ON public.addresses USING btree (address);
 
-
 --
 -- Name: balances_address_id_coind_id_uindex; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE UNIQUE INDEX balances_address_id_coind_id_uindex ON public.balances USING btree (address_id, coin_id);
 
-
 --
 -- Name: balances_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX balances_address_id_index ON public.balances USING btree (address_id);
 
-
 --
 -- Name: balances_coind_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX balances_coind_id_index ON public.balances USING btree (coin_id);
 
-
 --
 -- Name: block_validator_block_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX block_validator_block_id_index ON public.block_validator USING btree (block_id);
 
-
 --
 -- Name: block_validator_validator_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX block_validator_validator_id_index ON public.block_validator USING btree (validator_id);
 
-
 --
 -- Name: blocks_proposer_validator_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX blocks_proposer_validator_id_index ON public.blocks USING btree (proposer_validator_id);
 
-
 --
 -- Name: blocks_proposer_validator_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX blocks_created_at_index ON public.blocks (created_at DESC);
 
-
 --
 -- Name: coins_creation_transaction_id_uindex; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE UNIQUE INDEX coins_creation_transaction_id_uindex ON public.coins USING btree (creation_transaction_id);
 
-
 --
 -- Name: coins_creator_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
@@ -1039,73 +742,60 @@ CREATE INDEX coins_creator_address_id_index ON public.coins USING btree (creatio
 
 CREATE UNIQUE INDEX coins_symbol_uindex ON public.coins (symbol);
 
-
 --
 -- Name: invalid_transactions_block_id_from_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX invalid_transactions_block_id_from_address_id_index ON public.invalid_transactions USING btree (block_id DESC, from_address_id);
 
-
 --
 -- Name: invalid_transactions_from_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX invalid_transactions_from_address_id_index ON public.invalid_transactions USING btree (from_address_id);
 
-
 --
 -- Name: invalid_transactions_hash_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX invalid_transactions_hash_index ON public.invalid_transactions USING hash (hash);
 
-
 --
 -- Name: rewards_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX rewards_address_id_index ON public.rewards USING btree (address_id);
 
-
 --
 -- Name: rewards_block_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX rewards_block_id_index ON public.rewards USING btree (block_id);
 
-
 --
 -- Name: rewards_validator_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX rewards_validator_id_index ON public.rewards USING btree (validator_id);
 
-
 --
 -- Name: aggregated_rewards_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX aggregated_rewards_address_id_index ON public.aggregated_rewards USING btree (address_id);
 
-
-
 --
 -- Name: aggregated_rewards_validator_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX aggregated_rewards_validator_id_index ON public.aggregated_rewards USING btree (validator_id);
 
-
-
 --
 -- Name: aggregated_rewards_time_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX aggregated_rewards_time_id_index ON public.aggregated_rewards USING btree (time_id);
 
-
-
 --
 -- Name: aggregated_rewards_unique_index; Type: INDEX; Schema: public; Owner: minter
 --
@@ -1113,64 +803,54 @@ CREATE INDEX aggregated_rewards_time_id_index ON public.aggregated_rewards USING
 CREATE UNIQUE INDEX aggregated_rewards_unique_index ON public.aggregated_rewards
 USING btree (time_id, address_id, validator_id, role);
 
-
-
 --
 -- Name: slashes_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX slashes_address_id_index ON public.slashes USING btree (address_id);
 
-
 --
 -- Name: slashes_block_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX slashes_block_id_index ON public.slashes USING btree (block_id);
 
-
 --
 -- Name: slashes_coin_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX slashes_coin_id_index ON public.slashes USING btree (coin_id);
 
-
 --
 -- Name: slashes_validator_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX slashes_validator_id_index ON public.slashes USING btree (validator_id);
 
-
 --
 -- Name: stakes_coin_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX stakes_coin_id_index ON public.stakes USING btree (coin_id);
 
-
 --
 -- Name: stakes_owner_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX stakes_owner_address_id_index ON public.stakes USING btree (owner_address_id);
 
-
 --
 -- Name: stakes_validator_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX stakes_validator_id_index ON public.stakes USING btree (validator_id);
 
-
 --
 -- Name: transaction_outputs_coin_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX transaction_outputs_coin_id_index ON public.transaction_outputs USING btree (coin_id);
 
-
 --
 -- Name: transaction_outputs_transaction_id_index; Type: INDEX; Schema: public; Owner: minter
 --
@@ -1189,56 +869,48 @@ CREATE INDEX transaction_outputs_address_id_index ON public.transaction_outputs
 
 CREATE INDEX transaction_validator_validator_id_index ON public.transaction_validator USING btree (validator_id);
 
-
 --
 -- Name: transactions_block_id_from_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX transactions_block_id_from_address_id_index ON public.transactions USING btree (block_id DESC, from_address_id);
 
-
 --
 -- Name: transactions_from_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX transactions_from_address_id_index ON public.transactions USING btree (from_address_id);
 
-
 --
 -- Name: transactions_hash_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX transactions_hash_index ON public.transactions USING hash (hash);
 
-
 --
 -- Name: validator_public_keys_public_key_uindex; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE UNIQUE INDEX validator_public_keys_public_key_uindex ON public.validators USING btree (public_key);
 
-
 --
 -- Name: index_transaction_by_address_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX index_transaction_by_address_address_id_index ON public.index_transaction_by_address USING btree (address_id);
 
-
 --
 -- Name: index_transaction_by_address_block_id_address_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX index_transaction_by_address_block_id_address_id_index ON public.index_transaction_by_address USING btree (block_id, address_id);
 
-
 --
 -- Name: index_transaction_by_address_transaction_id_index; Type: INDEX; Schema: public; Owner: minter
 --
 
 CREATE INDEX index_transaction_by_address_transaction_id_index ON public.index_transaction_by_address USING btree (transaction_id);
 
-
 --
 -- Name: balances_addresses_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1246,7 +918,6 @@ CREATE INDEX index_transaction_by_address_transaction_id_index ON public.index_t
 ALTER TABLE ONLY public.balances
     ADD CONSTRAINT balances_addresses_id_fk FOREIGN KEY (address_id) REFERENCES public.addresses (id);
 
-
 --
 -- Name: balances_coins_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1254,7 +925,6 @@ ALTER TABLE ONLY public.balances
 ALTER TABLE ONLY public.balances
     ADD CONSTRAINT balances_coins_id_fk FOREIGN KEY (coin_id) REFERENCES public.coins (id);
 
-
 --
 -- Name: block_validator_blocks_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1262,7 +932,6 @@ ALTER TABLE ONLY public.balances
 ALTER TABLE ONLY public.block_validator
     ADD CONSTRAINT block_validator_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
 
-
 --
 -- Name: block_validator_validators_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1284,7 +953,6 @@ ALTER TABLE ONLY public.coins
 ALTER TABLE ONLY public.coins
     ADD CONSTRAINT coins_transactions_id_fk FOREIGN KEY (creation_transaction_id) REFERENCES public.transactions (id);
 
-
 --
 -- Name: invalid_transactions_addresses_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1292,7 +960,6 @@ ALTER TABLE ONLY public.coins
 ALTER TABLE ONLY public.invalid_transactions
     ADD CONSTRAINT invalid_transactions_addresses_id_fk FOREIGN KEY (from_address_id) REFERENCES public.addresses (id);
 
-
 --
 -- Name: invalid_transactions_blocks_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1300,7 +967,6 @@ ALTER TABLE ONLY public.invalid_transactions
 ALTER TABLE ONLY public.invalid_transactions
     ADD CONSTRAINT invalid_transactions_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
 
-
 --
 -- Name: rewards_addresses_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1308,7 +974,6 @@ ALTER TABLE ONLY public.invalid_transactions
 ALTER TABLE ONLY public.rewards
     ADD CONSTRAINT rewards_addresses_id_fk FOREIGN KEY (address_id) REFERENCES public.addresses (id);
 
-
 --
 -- Name: rewards_blocks_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1316,7 +981,6 @@ ALTER TABLE ONLY public.rewards
 ALTER TABLE ONLY public.rewards
     ADD CONSTRAINT rewards_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
 
-
 --
 -- Name: rewards_validators_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1324,7 +988,6 @@ ALTER TABLE ONLY public.rewards
 ALTER TABLE ONLY public.rewards
     ADD CONSTRAINT rewards_validators_id_fk FOREIGN KEY (validator_id) REFERENCES public.validators (id);
 
-
 --
 -- Name: aggregated_rewards_addresses_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1332,7 +995,6 @@ ALTER TABLE ONLY public.rewards
 ALTER TABLE ONLY public.aggregated_rewards
   ADD CONSTRAINT aggregated_rewards_addresses_id_fk FOREIGN KEY (address_id) REFERENCES public.addresses (id);
 
-
 --
 -- Name: aggregated_rewards_from_blocks_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1340,7 +1002,6 @@ ALTER TABLE ONLY public.aggregated_rewards
 ALTER TABLE ONLY public.aggregated_rewards
   ADD CONSTRAINT aggregated_rewards_from_blocks_id_fk FOREIGN KEY (from_block_id) REFERENCES public.blocks (id);
 
-
 --
 -- Name: aggregated_rewards_to_blocks_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1348,7 +1009,6 @@ ALTER TABLE ONLY public.aggregated_rewards
 ALTER TABLE ONLY public.aggregated_rewards
   ADD CONSTRAINT aggregated_rewards_to_blocks_id_fk FOREIGN KEY (to_block_id) REFERENCES public.blocks (id);
 
-
 --
 -- Name: aggregated_rewards_validators_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1356,7 +1016,6 @@ ALTER TABLE ONLY public.aggregated_rewards
 ALTER TABLE ONLY public.aggregated_rewards
   ADD CONSTRAINT aggregated_rewards_validators_id_fk FOREIGN KEY (validator_id) REFERENCES public.validators (id);
 
-
 --
 -- Name: slashes_addresses_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1364,7 +1023,6 @@ ALTER TABLE ONLY public.aggregated_rewards
 ALTER TABLE ONLY public.slashes
     ADD CONSTRAINT slashes_addresses_id_fk FOREIGN KEY (address_id) REFERENCES public.addresses (id);
 
-
 --
 -- Name: slashes_blocks_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1372,7 +1030,6 @@ ALTER TABLE ONLY public.slashes
 ALTER TABLE ONLY public.slashes
     ADD CONSTRAINT slashes_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
 
-
 --
 -- Name: slashes_coins_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1380,7 +1037,6 @@ ALTER TABLE ONLY public.slashes
 ALTER TABLE ONLY public.slashes
     ADD CONSTRAINT slashes_coins_id_fk FOREIGN KEY (coin_id) REFERENCES public.coins (id);
 
-
 --
 -- Name: slashes_validators_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1388,7 +1044,6 @@ ALTER TABLE ONLY public.slashes
 ALTER TABLE ONLY public.slashes
     ADD CONSTRAINT slashes_validators_id_fk FOREIGN KEY (validator_id) REFERENCES public.validators (id);
 
-
 --
 -- Name: stakes_addresses_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1396,7 +1051,6 @@ ALTER TABLE ONLY public.slashes
 ALTER TABLE ONLY public.stakes
     ADD CONSTRAINT stakes_addresses_id_fk FOREIGN KEY (owner_address_id) REFERENCES public.addresses (id);
 
-
 --
 -- Name: stakes_coins_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1404,7 +1058,6 @@ ALTER TABLE ONLY public.stakes
 ALTER TABLE ONLY public.stakes
     ADD CONSTRAINT stakes_coins_id_fk FOREIGN KEY (coin_id) REFERENCES public.coins (id);
 
-
 --
 -- Name: stakes_validators_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1412,7 +1065,6 @@ ALTER TABLE ONLY public.stakes
 ALTER TABLE ONLY public.stakes
     ADD CONSTRAINT stakes_validators_id_fk FOREIGN KEY (validator_id) REFERENCES public.validators (id);
 
-
 --
 -- Name: transaction_outputs_addresses_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1420,7 +1072,6 @@ ALTER TABLE ONLY public.stakes
 ALTER TABLE ONLY public.transaction_outputs
     ADD CONSTRAINT transaction_outputs_addresses_id_fk FOREIGN KEY (to_address_id) REFERENCES public.addresses (id);
 
-
 --
 -- Name: transaction_outputs_coins_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1428,7 +1079,6 @@ ALTER TABLE ONLY public.transaction_outputs
 ALTER TABLE ONLY public.transaction_outputs
     ADD CONSTRAINT transaction_outputs_coins_id_fk FOREIGN KEY (coin_id) REFERENCES public.coins (id);
 
-
 --
 -- Name: transaction_outputs_transactions_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1436,7 +1086,6 @@ ALTER TABLE ONLY public.transaction_outputs
 ALTER TABLE ONLY public.transaction_outputs
     ADD CONSTRAINT transaction_outputs_transactions_id_fk FOREIGN KEY (transaction_id) REFERENCES public.transactions (id);
 
-
 --
 -- Name: transaction_validator_transactions_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1444,7 +1093,6 @@ ALTER TABLE ONLY public.transaction_outputs
 ALTER TABLE ONLY public.transaction_validator
     ADD CONSTRAINT transaction_validator_transactions_id_fk FOREIGN KEY (transaction_id) REFERENCES public.transactions (id);
 
-
 --
 -- Name: transaction_validator_validators_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1452,7 +1100,6 @@ ALTER TABLE ONLY public.transaction_validator
 ALTER TABLE ONLY public.transaction_validator
     ADD CONSTRAINT transaction_validator_validators_id_fk FOREIGN KEY (validator_id) REFERENCES public.validators (id);
 
-
 --
 -- Name: transactions_addresses_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1460,7 +1107,6 @@ ALTER TABLE ONLY public.transaction_validator
 ALTER TABLE ONLY public.transactions
     ADD CONSTRAINT transactions_addresses_id_fk FOREIGN KEY (from_address_id) REFERENCES public.addresses (id);
 
-
 --
 -- Name: transactions_blocks_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1468,7 +1114,6 @@ ALTER TABLE ONLY public.transactions
 ALTER TABLE ONLY public.transactions
     ADD CONSTRAINT transactions_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
 
-
 --
 -- Name: transactions_coins_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1476,7 +1121,6 @@ ALTER TABLE ONLY public.transactions
 ALTER TABLE ONLY public.transactions
     ADD CONSTRAINT transactions_coins_id_fk FOREIGN KEY (gas_coin_id) REFERENCES public.coins (id);
 
-
 --
 -- Name: index_transaction_by_address index_transaction_by_address_addresses_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1484,7 +1128,6 @@ ALTER TABLE ONLY public.transactions
 ALTER TABLE ONLY public.index_transaction_by_address
     ADD CONSTRAINT index_transaction_by_address_addresses_id_fk FOREIGN KEY (address_id) REFERENCES public.addresses (id);
 
-
 --
 -- Name: index_transaction_by_address index_transaction_by_address_blocks_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1492,7 +1135,6 @@ ALTER TABLE ONLY public.index_transaction_by_address
 ALTER TABLE ONLY public.index_transaction_by_address
     ADD CONSTRAINT index_transaction_by_address_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
 
-
 --
 -- Name: index_transaction_by_address index_transaction_by_address_transactions_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: minter
 --
@@ -1500,15 +1142,24 @@ ALTER TABLE ONLY public.index_transaction_by_address
 ALTER TABLE ONLY public.index_transaction_by_address
     ADD CONSTRAINT index_transaction_by_address_transactions_id_fk FOREIGN KEY (transaction_id) REFERENCES public.transactions (id);
 
-
---
--- Name: SCHEMA public; Type: ACL; Schema: -; Owner: minter
---
-
-REVOKE ALL ON SCHEMA public FROM PUBLIC;
-REVOKE ALL ON SCHEMA public FROM minter;
-GRANT ALL ON SCHEMA public TO minter;
-GRANT ALL ON SCHEMA public TO PUBLIC;
-
-INSERT INTO explorer.public.coins (symbol)
+INSERT INTO public.coins (symbol)
 VALUES ('BIP');
+`, `
+DROP TABLE public.index_transaction_by_address CASCADE;
+DROP TABLE public.transaction_validator CASCADE;
+DROP TABLE public.transaction_outputs CASCADE;
+DROP TABLE public.invalid_transactions CASCADE;
+DROP TABLE public.transactions CASCADE;
+DROP TABLE public.aggregated_rewards CASCADE;
+DROP TABLE public.rewards CASCADE;
+DROP TABLE public.slashes CASCADE;
+DROP TABLE public.stakes CASCADE;
+DROP TABLE public.balances CASCADE;
+DROP TABLE public.block_validator CASCADE;
+DROP TABLE public.blocks CASCADE;
+DROP TABLE public.validators CASCADE;
+DROP TABLE public.coins CASCADE;
+DROP TABLE public.addresses CASCADE;
+DROP TYPE public.rewards_role;
+`)
+}
@@ -0,0 +1,14 @@
+package migrations
+
+func init() {
+	register(2, "stage_cursors", `
+CREATE TABLE IF NOT EXISTS public.stage_cursors
+(
+    stage      character varying(32)    NOT NULL PRIMARY KEY,
+    height     bigint                   NOT NULL,
+    updated_at timestamp with time zone NOT NULL DEFAULT now()
+);
+`, `
+DROP TABLE public.stage_cursors;
+`)
+}
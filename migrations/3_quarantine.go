@@ -0,0 +1,22 @@
+package migrations
+
+func init() {
+	register(3, "quarantine", `
+CREATE TABLE IF NOT EXISTS public.quarantine
+(
+    id          bigserial                NOT NULL PRIMARY KEY,
+    stage       character varying(32)    NOT NULL,
+    block_id    bigint                   NOT NULL,
+    hash        character varying(64),
+    payload     jsonb                    NOT NULL,
+    error       text                     NOT NULL,
+    attempts    integer                  NOT NULL DEFAULT 0,
+    created_at  timestamp with time zone NOT NULL DEFAULT now(),
+    resolved_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS quarantine_block_id_index ON public.quarantine USING btree (block_id) WHERE resolved_at IS NULL;
+`, `
+DROP TABLE public.quarantine;
+`)
+}
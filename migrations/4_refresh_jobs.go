@@ -0,0 +1,19 @@
+package migrations
+
+func init() {
+	register(4, "refresh_jobs", `
+CREATE TABLE IF NOT EXISTS public.refresh_jobs
+(
+    kind         character varying(16)    NOT NULL,
+    key          character varying(64)    NOT NULL,
+    height       bigint                   NOT NULL,
+    locked_until timestamp with time zone,
+    created_at   timestamp with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (kind, key)
+);
+
+CREATE INDEX IF NOT EXISTS refresh_jobs_kind_created_at_index ON public.refresh_jobs USING btree (kind, created_at);
+`, `
+DROP TABLE public.refresh_jobs;
+`)
+}
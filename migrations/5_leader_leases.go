@@ -0,0 +1,14 @@
+package migrations
+
+func init() {
+	register(5, "leader_leases", `
+CREATE TABLE IF NOT EXISTS public.leader_leases
+(
+    name       character varying(32)    NOT NULL PRIMARY KEY,
+    holder     character varying(255)   NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+`, `
+DROP TABLE public.leader_leases;
+`)
+}
@@ -0,0 +1,17 @@
+package migrations
+
+func init() {
+	register(6, "scheduled_jobs", `
+CREATE TABLE IF NOT EXISTS public.scheduled_jobs
+(
+    job         character varying(32)    NOT NULL PRIMARY KEY,
+    height      bigint                   NOT NULL,
+    started_at  timestamp with time zone NOT NULL,
+    duration_ms bigint                   NOT NULL,
+    result      character varying(16)    NOT NULL,
+    error       text
+);
+`, `
+DROP TABLE public.scheduled_jobs;
+`)
+}
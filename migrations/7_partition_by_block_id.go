@@ -0,0 +1,154 @@
+package migrations
+
+import "strings"
+
+// High-volume tables become partitioned by range of block_id. Existing rows stay in the former table,
+// which is attached as the partition of all heights up to the current max block_id.
+// Partitions of upcoming heights are created by the extender. Requires PostgreSQL 11+, the first batch
+// fails on an older server before anything is changed.
+//
+// block_id of transaction_outputs is filled in batches of 10000 rows before the transaction.
+// The transaction locks the tables exclusively while it checks the heights of their rows and builds
+// the indexes of the partitioned tables, so all instances of the extender and the explorer API
+// have to be stopped for the whole migration, which takes about a full scan of every table.
+// It can not be reverted
+func init() {
+	register(7, "partition_by_block_id", `
+ALTER TABLE public.coins
+    DROP CONSTRAINT coins_transactions_id_fk;
+ALTER TABLE public.transaction_outputs
+    DROP CONSTRAINT transaction_outputs_transactions_id_fk;
+ALTER TABLE public.transaction_validator
+    DROP CONSTRAINT transaction_validator_transactions_id_fk;
+ALTER TABLE public.index_transaction_by_address
+    DROP CONSTRAINT index_transaction_by_address_transactions_id_fk;
+
+-- Outputs saved after the batches
+UPDATE public.transaction_outputs
+SET block_id = t.block_id
+FROM public.transactions t
+WHERE t.id = transaction_outputs.transaction_id
+  AND transaction_outputs.block_id IS NULL;
+DROP INDEX public.transaction_outputs_without_block_id_index;
+ALTER TABLE public.transaction_outputs
+    ALTER COLUMN block_id SET NOT NULL;
+`+partitionByBlockId("transactions", `
+ALTER TABLE public.transactions
+    ADD CONSTRAINT transactions_pkey PRIMARY KEY (id, block_id);
+CREATE INDEX transactions_block_id_from_address_id_index ON public.transactions USING btree (block_id DESC, from_address_id);
+CREATE INDEX transactions_from_address_id_index ON public.transactions USING btree (from_address_id);
+CREATE INDEX transactions_hash_index ON public.transactions USING hash (hash);
+ALTER TABLE public.transactions
+    ADD CONSTRAINT transactions_addresses_id_fk FOREIGN KEY (from_address_id) REFERENCES public.addresses (id);
+ALTER TABLE public.transactions
+    ADD CONSTRAINT transactions_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
+ALTER TABLE public.transactions
+    ADD CONSTRAINT transactions_coins_id_fk FOREIGN KEY (gas_coin_id) REFERENCES public.coins (id);
+`)+partitionByBlockId("transaction_outputs", `
+ALTER TABLE public.transaction_outputs
+    ADD CONSTRAINT transaction_outputs_pkey PRIMARY KEY (id, block_id);
+CREATE INDEX transaction_outputs_coin_id_index ON public.transaction_outputs USING btree (coin_id);
+CREATE INDEX transaction_outputs_transaction_id_index ON public.transaction_outputs USING btree (transaction_id);
+CREATE INDEX transaction_outputs_address_id_index ON public.transaction_outputs USING btree (to_address_id);
+ALTER TABLE public.transaction_outputs
+    ADD CONSTRAINT transaction_outputs_addresses_id_fk FOREIGN KEY (to_address_id) REFERENCES public.addresses (id);
+ALTER TABLE public.transaction_outputs
+    ADD CONSTRAINT transaction_outputs_coins_id_fk FOREIGN KEY (coin_id) REFERENCES public.coins (id);
+`)+partitionByBlockId("rewards", `
+CREATE INDEX rewards_address_id_index ON public.rewards USING btree (address_id);
+CREATE INDEX rewards_block_id_index ON public.rewards USING btree (block_id);
+CREATE INDEX rewards_validator_id_index ON public.rewards USING btree (validator_id);
+ALTER TABLE public.rewards
+    ADD CONSTRAINT rewards_addresses_id_fk FOREIGN KEY (address_id) REFERENCES public.addresses (id);
+ALTER TABLE public.rewards
+    ADD CONSTRAINT rewards_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
+ALTER TABLE public.rewards
+    ADD CONSTRAINT rewards_validators_id_fk FOREIGN KEY (validator_id) REFERENCES public.validators (id);
+`)+partitionByBlockId("block_validator", `
+ALTER TABLE public.block_validator
+    ADD CONSTRAINT block_validator_pk PRIMARY KEY (block_id, validator_id);
+CREATE INDEX block_validator_block_id_index ON public.block_validator USING btree (block_id);
+CREATE INDEX block_validator_validator_id_index ON public.block_validator USING btree (validator_id);
+ALTER TABLE public.block_validator
+    ADD CONSTRAINT block_validator_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
+ALTER TABLE public.block_validator
+    ADD CONSTRAINT block_validator_validators_id_fk FOREIGN KEY (validator_id) REFERENCES public.validators (id);
+`)+partitionByBlockId("index_transaction_by_address", `
+ALTER TABLE public.index_transaction_by_address
+    ADD CONSTRAINT index_transaction_by_address_pk PRIMARY KEY (block_id, address_id, transaction_id);
+CREATE INDEX index_transaction_by_address_address_id_index ON public.index_transaction_by_address USING btree (address_id);
+CREATE INDEX index_transaction_by_address_block_id_address_id_index ON public.index_transaction_by_address USING btree (block_id, address_id);
+CREATE INDEX index_transaction_by_address_transaction_id_index ON public.index_transaction_by_address USING btree (transaction_id);
+ALTER TABLE public.index_transaction_by_address
+    ADD CONSTRAINT index_transaction_by_address_addresses_id_fk FOREIGN KEY (address_id) REFERENCES public.addresses (id);
+ALTER TABLE public.index_transaction_by_address
+    ADD CONSTRAINT index_transaction_by_address_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
+`), `
+DO $$
+BEGIN
+    RAISE EXCEPTION 'partitioning by block_id can not be reverted, restore the tables from a backup';
+END
+$$;
+`, `
+DO $$
+BEGIN
+    IF current_setting('server_version_num')::int < 110000 THEN
+        RAISE EXCEPTION 'partitioning by block_id requires PostgreSQL 11 or newer, the server is %',
+            current_setting('server_version');
+    END IF;
+END
+$$;
+`, `
+ALTER TABLE public.transaction_outputs
+    ADD COLUMN IF NOT EXISTS block_id bigint;
+`, `
+CREATE INDEX IF NOT EXISTS transaction_outputs_without_block_id_index
+    ON public.transaction_outputs USING btree (id) WHERE block_id IS NULL;
+`, `
+UPDATE public.transaction_outputs
+SET block_id = t.block_id
+FROM public.transactions t
+WHERE t.id = transaction_outputs.transaction_id
+  AND transaction_outputs.id IN (SELECT id FROM public.transaction_outputs WHERE block_id IS NULL LIMIT 10000);
+`)
+}
+
+// Replace the table with a partitioned one. Indexes of the former table get the _legacy suffix,
+// so the partitioned table has the original names. Constraints and indexes of the partitioned
+// table are created before the former table is attached, equivalent ones of the partition are reused.
+// The range of the former table is validated by a check constraint, so attaching it doesn't scan it again
+func partitionByBlockId(table, constraintsAndIndexes string) string {
+	return strings.Replace(`
+ALTER TABLE public.{table}
+    RENAME TO {table}_legacy;
+DO $$
+DECLARE
+    index_name text;
+BEGIN
+    FOR index_name IN SELECT indexname FROM pg_indexes WHERE schemaname = 'public' AND tablename = '{table}_legacy'
+        LOOP
+            EXECUTE format('ALTER INDEX public.%I RENAME TO %I', index_name, index_name || '_legacy');
+        END LOOP;
+END
+$$;
+
+CREATE TABLE public.{table}
+(
+    LIKE public.{table}_legacy INCLUDING DEFAULTS INCLUDING COMMENTS
+) PARTITION BY RANGE (block_id);
+`+constraintsAndIndexes+`
+DO $$
+DECLARE
+    upper_bound bigint;
+BEGIN
+    SELECT coalesce(max(block_id), 0) + 1 INTO upper_bound FROM public.{table}_legacy;
+    EXECUTE format('ALTER TABLE public.{table}_legacy ADD CONSTRAINT {table}_legacy_block_id_check CHECK (block_id >= 0 AND block_id < %s) NOT VALID',
+                   upper_bound);
+    ALTER TABLE public.{table}_legacy
+        VALIDATE CONSTRAINT {table}_legacy_block_id_check;
+    EXECUTE format('ALTER TABLE public.{table} ATTACH PARTITION public.{table}_legacy FOR VALUES FROM (0) TO (%s)',
+                   upper_bound);
+END
+$$;
+`, "{table}", table, -1)
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Numbered schema change. Migrations are compiled into the binary and applied in the order of versions
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// Statements run before the transaction of Up, each one again until it changes no rows.
+	// Used to backfill large tables in batches, they must be safe to interrupt and repeat
+	Batches []string
+}
+
+var all []*Migration
+
+func register(version int, name, up, down string, batches ...string) {
+	for _, m := range all {
+		if m.Version == version {
+			panic(fmt.Sprintf("migration %d is registered twice", version))
+		}
+	}
+	all = append(all, &Migration{Version: version, Name: name, Up: up, Down: down, Batches: batches})
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Version < all[j].Version
+	})
+}
+
+// Schema version expected by the binary
+func Latest() int {
+	if len(all) == 0 {
+		return 0
+	}
+	return all[len(all)-1].Version
+}
@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"time"
+)
+
+// Migration applied to the database
+type Applied struct {
+	tableName struct{} `sql:"schema_migrations"`
+	Version   int      `sql:",pk"`
+	Name      string   `sql:",notnull"`
+	AppliedAt time.Time
+}
+
+type Repository struct {
+	db orm.DB
+}
+
+func NewRepository(db orm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// Return a copy of the repository which runs all queries inside the transaction
+func (r *Repository) WithTx(tx *pg.Tx) *Repository {
+	return &Repository{
+		db: tx,
+	}
+}
+
+func (r *Repository) CreateTable() error {
+	_, err := r.db.Exec(`
+create table if not exists schema_migrations
+(
+    version    integer                  not null primary key,
+    name       character varying(64)    not null,
+    applied_at timestamp with time zone not null default now()
+);
+	`)
+	return err
+}
+
+func (r *Repository) TableExists(name string) (bool, error) {
+	var exists bool
+	_, err := r.db.QueryOne(pg.Scan(&exists), `select to_regclass(?) is not null;`, "public."+name)
+	return exists, err
+}
+
+// Applied migrations ordered by version
+func (r *Repository) GetAll() ([]*Applied, error) {
+	var applied []*Applied
+	err := r.db.Model(&applied).Order("version ASC").Select()
+	return applied, err
+}
+
+func (r *Repository) Save(m *Migration) error {
+	_, err := r.db.Model(&Applied{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Insert()
+	return err
+}
+
+func (r *Repository) Delete(version int) error {
+	_, err := r.db.Model((*Applied)(nil)).Where("version = ?", version).Delete()
+	return err
+}
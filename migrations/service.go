@@ -0,0 +1,205 @@
+package migrations
+
+import (
+	"fmt"
+	"github.com/go-pg/pg"
+	"github.com/sirupsen/logrus"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Table which exists in every database created from the dump used before migrations
+const baselineTable = "blocks"
+
+type Service struct {
+	db         *pg.DB
+	repository *Repository
+	logger     *logrus.Entry
+}
+
+func NewService(db *pg.DB, repository *Repository, logger *logrus.Entry) *Service {
+	return &Service{
+		db:         db,
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+// Version of the database schema, the highest applied migration.
+// A database created from the dump without the migrations table has the first version
+func (s *Service) Version() (int, error) {
+	exists, err := s.repository.TableExists("schema_migrations")
+	if err != nil || !exists {
+		return s.baselineVersion(), err
+	}
+	applied, err := s.repository.GetAll()
+	if err != nil || len(applied) == 0 {
+		return s.baselineVersion(), err
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+func (s *Service) baselineVersion() int {
+	exists, _ := s.repository.TableExists(baselineTable)
+	if exists {
+		return 1
+	}
+	return 0
+}
+
+// Fail if the schema is older than the binary expects
+func (s *Service) Check() error {
+	version, err := s.Version()
+	if err != nil {
+		return err
+	}
+	if version < Latest() {
+		return fmt.Errorf("the database schema version %d is older than %d expected by the extender, run the migrate up command", version, Latest())
+	}
+	if version > Latest() {
+		s.logger.Warnf("The database schema version %d is newer than %d known by the extender", version, Latest())
+	}
+	return nil
+}
+
+// Apply all migrations which are not applied yet, each one in its own transaction
+func (s *Service) Up() error {
+	applied, err := s.appliedVersions()
+	if err != nil {
+		return err
+	}
+	for _, m := range all {
+		if applied[m.Version] {
+			continue
+		}
+		err = s.runBatches(m)
+		if err != nil {
+			return fmt.Errorf("migration %d %s: %s", m.Version, m.Name, err)
+		}
+		err = s.db.RunInTransaction(func(tx *pg.Tx) error {
+			if _, err := tx.Exec(m.Up); err != nil {
+				return err
+			}
+			return s.repository.WithTx(tx).Save(m)
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d %s: %s", m.Version, m.Name, err)
+		}
+		s.logger.WithFields(logrus.Fields{"version": m.Version, "name": m.Name}).Warn("Migration applied")
+	}
+	return nil
+}
+
+// Run every batch statement of the migration until it changes no rows, each one in its own transaction
+func (s *Service) runBatches(m *Migration) error {
+	for i, batch := range m.Batches {
+		total := 0
+		for {
+			res, err := s.db.Exec(batch)
+			if err != nil {
+				return err
+			}
+			if res.RowsAffected() <= 0 {
+				break
+			}
+			total += res.RowsAffected()
+			s.logger.WithFields(logrus.Fields{"version": m.Version, "batch": i, "rows": total}).Info("Migration batch applied")
+		}
+	}
+	return nil
+}
+
+// Revert the last applied migration
+func (s *Service) Down() error {
+	applied, err := s.repository.GetAll()
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		return fmt.Errorf("there are no applied migrations")
+	}
+	last := applied[len(applied)-1]
+	var migration *Migration
+	for _, m := range all {
+		if m.Version == last.Version {
+			migration = m
+		}
+	}
+	if migration == nil {
+		return fmt.Errorf("migration %d %s is unknown to the extender", last.Version, last.Name)
+	}
+
+	err = s.db.RunInTransaction(func(tx *pg.Tx) error {
+		if _, err := tx.Exec(migration.Down); err != nil {
+			return err
+		}
+		return s.repository.WithTx(tx).Delete(migration.Version)
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d %s: %s", migration.Version, migration.Name, err)
+	}
+	s.logger.WithFields(logrus.Fields{"version": migration.Version, "name": migration.Name}).Warn("Migration reverted")
+	return nil
+}
+
+// Print every known migration with the time it was applied at
+func (s *Service) Status(w io.Writer) error {
+	exists, err := s.repository.TableExists("schema_migrations")
+	if err != nil {
+		return err
+	}
+	var applied []*Applied
+	if exists {
+		applied, err = s.repository.GetAll()
+		if err != nil {
+			return err
+		}
+	}
+	appliedAt := make(map[int]string)
+	for _, a := range applied {
+		appliedAt[a.Version] = a.AppliedAt.Format(time.RFC3339)
+	}
+	version, err := s.Version()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	for _, m := range all {
+		at, ok := appliedAt[m.Version]
+		if !ok {
+			at = "pending"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", m.Version, m.Name, at)
+	}
+	fmt.Fprintf(tw, "\nSchema version %d of %d\n", version, Latest())
+	return tw.Flush()
+}
+
+// Create the migrations table. A database created from the dump before migrations
+// is recorded as having the first migration applied
+func (s *Service) appliedVersions() (map[int]bool, error) {
+	err := s.repository.CreateTable()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.repository.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 && s.baselineVersion() == 1 {
+		if err = s.repository.Save(all[0]); err != nil {
+			return nil, err
+		}
+		s.logger.Warn("Existing schema is recorded as the initial migration")
+		applied = []*Applied{{Version: all[0].Version}}
+	}
+
+	versions := make(map[int]bool)
+	for _, a := range applied {
+		versions[a.Version] = true
+	}
+	return versions, nil
+}
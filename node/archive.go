@@ -0,0 +1,201 @@
+package node
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-tools/models"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Count of heights stored in one directory of the archive
+const archiveGroupSize = 10000
+
+const (
+	archiveBlock      = "block"
+	archiveEvents     = "events"
+	archiveCandidates = "candidates"
+	archiveStakes     = "candidates_stakes"
+	archiveBalances   = "balances"
+	archiveCoinsDir   = "coins"
+	archiveFileExt    = ".json.gz"
+)
+
+// Directory with gzipped node responses:
+// <dir>/<height/10000>/<height>/{block,events,candidates,candidates_stakes,balances}.json.gz
+// and the last coin info responses in <dir>/coins/<symbol>.json.gz
+type archive struct {
+	dir string
+}
+
+func (a archive) heightPath(height uint64, name string) string {
+	return filepath.Join(a.dir, strconv.FormatUint(height/archiveGroupSize, 10), strconv.FormatUint(height, 10), name+archiveFileExt)
+}
+
+func (a archive) coinPath(symbol string) string {
+	return filepath.Join(a.dir, archiveCoinsDir, symbol+archiveFileExt)
+}
+
+// Write the value to a temporary file and move it to the path, so readers never see a partial file
+func (a archive) write(path string, value interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	zw := gzip.NewWriter(file)
+	if err = json.NewEncoder(zw).Encode(value); err != nil {
+		file.Close()
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func (a archive) read(path string, value interface{}) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	return json.NewDecoder(zr).Decode(value)
+}
+
+// Highest height which has a stored block
+func (a archive) lastHeight() (uint64, error) {
+	groups, err := numericDirs(a.dir)
+	if err != nil {
+		return 0, err
+	}
+	for i := len(groups) - 1; i >= 0; i-- {
+		heights, err := numericDirs(filepath.Join(a.dir, strconv.FormatUint(groups[i], 10)))
+		if err != nil {
+			return 0, err
+		}
+		for j := len(heights) - 1; j >= 0; j-- {
+			if _, err := os.Stat(a.heightPath(heights[j], archiveBlock)); err == nil {
+				return heights[j], nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("there are no blocks in the archive %s", a.dir)
+}
+
+// Sorted numeric names of subdirectories
+func numericDirs(dir string) ([]uint64, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var list []uint64
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if n, err := strconv.ParseUint(entry.Name(), 10, 64); err == nil {
+			list = append(list, n)
+		}
+	}
+	// ReadDir sorts by name, numbers of different length need their own order
+	sort.Slice(list, func(i, j int) bool {
+		return list[i] < list[j]
+	})
+	return list, nil
+}
+
+// Transaction data is not serialized with the response, it is decoded from the raw data like the node API client does.
+// Used for stored responses
+func DecodeTransactionsData(transactions []responses.Transaction) error {
+	for i, tx := range transactions {
+		if tx.Data == nil {
+			continue
+		}
+		data, err := decodeTransactionData(tx.Type, tx.Data)
+		if err != nil {
+			return err
+		}
+		transactions[i].IData = data
+	}
+	return nil
+}
+
+func decodeTransactionData(txType uint8, raw json.RawMessage) (interface{}, error) {
+	switch txType {
+	case models.TxTypeSend:
+		data := models.SendTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	case models.TxTypeSellCoin:
+		data := models.SellCoinTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	case models.TxTypeSellAllCoin:
+		data := models.SellAllCoinTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	case models.TxTypeBuyCoin:
+		data := models.BuyCoinTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	case models.TxTypeCreateCoin:
+		data := models.CreateCoinTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	case models.TxTypeDeclareCandidacy:
+		data := models.DeclareCandidacyTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	case models.TxTypeDelegate:
+		data := models.DelegateTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	case models.TxTypeUnbound:
+		data := models.UnbondTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	case models.TxTypeRedeemCheck:
+		data := models.RedeemCheckTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	case models.TxTypeSetCandidateOnline, models.TxTypeSetCandidateOffline:
+		data := models.SetCandidateTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	case models.TxTypeMultiSend:
+		data := models.MultiSendTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	case models.TxTypeEditCandidate:
+		data := models.EditCandidateTxData{}
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	}
+	return nil, nil
+}
+
+// Addresses are sent to the node quoted
+func unquoteAddress(address string) string {
+	return strings.Trim(address, `"`)
+}
@@ -0,0 +1,21 @@
+package node
+
+import (
+	"github.com/MinterTeam/minter-node-go-api"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+)
+
+// Source of blockchain data used by the extender services
+type NodeClient interface {
+	GetStatus() (*responses.StatusResponse, error)
+	GetBlock(height uint64) (*responses.BlockResponse, error)
+	GetBlockEvents(height uint64) (*responses.EventsResponse, error)
+	GetCandidates(height uint64, includeStakes bool) (*responses.CandidatesResponse, error)
+	GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error)
+	GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error)
+}
+
+// Node API client of the link
+func New(link string) NodeClient {
+	return minter_node_go_api.New(link)
+}
@@ -0,0 +1,253 @@
+package node
+
+import (
+	"errors"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	healthCheckInterval = 5 * time.Second
+	// Heights are checked again before the health check interval when no node reached the requested height,
+	// but not more often, since the extender polls a future height until it is produced
+	forcedCheckInterval = time.Second
+)
+
+var ErrNoSyncedNodes = errors.New("there are no healthy nodes which reached the height")
+
+type poolNode struct {
+	link    string
+	client  NodeClient
+	height  uint64
+	healthy bool
+}
+
+// NodeClient which spreads requests over a list of nodes.
+// Health of the nodes is checked by GetStatus, a node is used only if its latest height
+// is not behind the requested height and the highest block received by the extender.
+// Failed requests are repeated on the next node
+type Pool struct {
+	indexedHeight uint64 // highest received block, updated atomically (keep first for 64-bit alignment)
+	next          uint32 // round robin counter, updated atomically
+	nodes         []*poolNode
+	mu            sync.RWMutex
+	refreshMu     sync.Mutex
+	lastCheck     time.Time
+	logger        *logrus.Entry
+}
+
+func NewPool(links []string, logger *logrus.Entry) *Pool {
+	nodes := make([]*poolNode, len(links))
+	for i, link := range links {
+		nodes[i] = &poolNode{link: link, client: New(link)}
+	}
+	return &Pool{
+		nodes:  nodes,
+		logger: logger,
+	}
+}
+
+// Status of the highest healthy node
+func (p *Pool) GetStatus() (*responses.StatusResponse, error) {
+	var resp *responses.StatusResponse
+	err := p.call(0, false, func(client NodeClient) error {
+		var err error
+		resp, err = client.GetStatus()
+		return err
+	})
+	return resp, err
+}
+
+// If none of the nodes reached the height, the response contains an error like the one of a node
+func (p *Pool) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	var resp *responses.BlockResponse
+	err := p.call(height, false, func(client NodeClient) error {
+		var err error
+		resp, err = client.GetBlock(height)
+		return err
+	})
+	if err == ErrNoSyncedNodes {
+		return &responses.BlockResponse{Error: &responses.Error{Message: err.Error()}}, nil
+	}
+	if err == nil && resp.Error == nil {
+		p.observeHeight(height)
+	}
+	return resp, err
+}
+
+func (p *Pool) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	var resp *responses.EventsResponse
+	err := p.call(height, false, func(client NodeClient) error {
+		var err error
+		resp, err = client.GetBlockEvents(height)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Pool) GetCandidates(height uint64, includeStakes bool) (*responses.CandidatesResponse, error) {
+	var resp *responses.CandidatesResponse
+	err := p.call(height, true, func(client NodeClient) error {
+		var err error
+		resp, err = client.GetCandidates(height, includeStakes)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Pool) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	var resp *responses.BalancesResponse
+	err := p.call(height, true, func(client NodeClient) error {
+		var err error
+		resp, err = client.GetAddresses(addresses, height)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Pool) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	var resp *responses.CoinInfoResponse
+	err := p.call(atomic.LoadUint64(&p.indexedHeight), true, func(client NodeClient) error {
+		var err error
+		resp, err = client.GetCoinInfo(symbol)
+		return err
+	})
+	return resp, err
+}
+
+// Run the request on the nodes which reached the height until one of them succeeds.
+// Without spread the nodes are tried in the configured order, otherwise the first node is rotated
+func (p *Pool) call(height uint64, spread bool, request func(client NodeClient) error) error {
+	nodes := p.candidates(height, spread)
+	if len(nodes) == 0 {
+		p.refresh(true)
+		nodes = p.candidates(height, spread)
+	}
+	if len(nodes) == 0 {
+		return ErrNoSyncedNodes
+	}
+	var err error
+	for _, n := range nodes {
+		err = request(n.client)
+		if err == nil {
+			return nil
+		}
+		p.logger.WithFields(logrus.Fields{
+			"node":   n.link,
+			"height": height,
+		}).Warn("Node request failed: ", err)
+		p.setUnhealthy(n)
+	}
+	return err
+}
+
+func (p *Pool) candidates(height uint64, spread bool) []*poolNode {
+	p.refresh(false)
+	highestFirst := height == 0
+	if indexed := atomic.LoadUint64(&p.indexedHeight); height < indexed {
+		height = indexed
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var list []*poolNode
+	for _, n := range p.nodes {
+		if n.healthy && n.height >= height {
+			list = append(list, n)
+		}
+	}
+	if highestFirst {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].height > list[j].height
+		})
+	}
+	if spread && len(list) > 1 {
+		shift := int(atomic.AddUint32(&p.next, 1) % uint32(len(list)))
+		list = append(list[shift:], list[:shift]...)
+	}
+	return list
+}
+
+// Update latest heights of the nodes. It happens once per health check interval,
+// or once per forced check interval with force
+func (p *Pool) refresh(force bool) {
+	p.mu.RLock()
+	lastCheck := p.lastCheck
+	p.mu.RUnlock()
+	interval := healthCheckInterval
+	if force {
+		interval = forcedCheckInterval
+	}
+	if time.Since(lastCheck) < interval {
+		return
+	}
+
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	// Could be refreshed by another request while waiting
+	p.mu.RLock()
+	refreshed := p.lastCheck.After(lastCheck)
+	p.mu.RUnlock()
+	if refreshed {
+		return
+	}
+
+	heights := make([]uint64, len(p.nodes))
+	errs := make([]error, len(p.nodes))
+	wg := new(sync.WaitGroup)
+	for i, n := range p.nodes {
+		wg.Add(1)
+		go func(i int, n *poolNode) {
+			defer wg.Done()
+			heights[i], errs[i] = getLatestHeight(n.client)
+		}(i, n)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, n := range p.nodes {
+		if errs[i] != nil {
+			if n.healthy {
+				p.logger.WithField("node", n.link).Warn("Node is unhealthy: ", errs[i])
+			}
+			n.healthy = false
+			continue
+		}
+		n.healthy = true
+		n.height = heights[i]
+	}
+	p.lastCheck = time.Now()
+}
+
+func (p *Pool) setUnhealthy(n *poolNode) {
+	p.mu.Lock()
+	n.healthy = false
+	p.mu.Unlock()
+}
+
+func (p *Pool) observeHeight(height uint64) {
+	for {
+		indexed := atomic.LoadUint64(&p.indexedHeight)
+		if height <= indexed || atomic.CompareAndSwapUint64(&p.indexedHeight, indexed, height) {
+			return
+		}
+	}
+}
+
+func getLatestHeight(client NodeClient) (uint64, error) {
+	resp, err := client.GetStatus()
+	if err != nil {
+		return 0, err
+	}
+	if resp.Error != nil {
+		return 0, errors.New(resp.Error.Message)
+	}
+	return strconv.ParseUint(resp.Result.LatestBlockHeight, 10, 64)
+}
@@ -0,0 +1,154 @@
+package node
+
+import (
+	"errors"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Node which serves blocks up to its height and counts requests
+type fakeNode struct {
+	mu       sync.Mutex
+	height   uint64
+	fail     error
+	statuses int
+	blocks   int
+}
+
+func (n *fakeNode) GetStatus() (*responses.StatusResponse, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.statuses++
+	resp := new(responses.StatusResponse)
+	resp.Result.LatestBlockHeight = strconv.FormatUint(n.height, 10)
+	return resp, nil
+}
+
+func (n *fakeNode) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.blocks++
+	if n.fail != nil {
+		return nil, n.fail
+	}
+	resp := new(responses.BlockResponse)
+	resp.Result.Height = strconv.FormatUint(height, 10)
+	return resp, nil
+}
+
+func (n *fakeNode) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	return new(responses.EventsResponse), nil
+}
+
+func (n *fakeNode) GetCandidates(height uint64, includeStakes bool) (*responses.CandidatesResponse, error) {
+	return new(responses.CandidatesResponse), nil
+}
+
+func (n *fakeNode) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	return new(responses.BalancesResponse), nil
+}
+
+func (n *fakeNode) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	return new(responses.CoinInfoResponse), nil
+}
+
+func (n *fakeNode) setHeight(height uint64) {
+	n.mu.Lock()
+	n.height = height
+	n.mu.Unlock()
+}
+
+func testLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	return logrus.NewEntry(logger)
+}
+
+func newTestPool(nodes ...*fakeNode) *Pool {
+	p := &Pool{logger: testLogger()}
+	for i, n := range nodes {
+		p.nodes = append(p.nodes, &poolNode{link: "node" + strconv.Itoa(i), client: n})
+	}
+	return p
+}
+
+func TestPoolFailsOverToNextNode(t *testing.T) {
+	first := &fakeNode{height: 10, fail: errors.New("connection refused")}
+	second := &fakeNode{height: 10}
+	p := newTestPool(first, second)
+
+	resp, err := p.GetBlock(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != nil || resp.Result.Height != "5" {
+		t.Fatalf("GetBlock() = %+v, want block 5", resp)
+	}
+	if first.blocks != 1 || second.blocks != 1 {
+		t.Errorf("requests = %d, %d, want one request to each node", first.blocks, second.blocks)
+	}
+
+	// The failed node is skipped until the next health check
+	if _, err = p.GetBlock(6); err != nil {
+		t.Fatal(err)
+	}
+	if first.blocks != 1 {
+		t.Errorf("unhealthy node got %d requests, want 1", first.blocks)
+	}
+}
+
+func TestPoolSkipsNodesBehindHeight(t *testing.T) {
+	behind := &fakeNode{height: 4}
+	synced := &fakeNode{height: 10}
+	p := newTestPool(behind, synced)
+
+	if _, err := p.GetBlock(5); err != nil {
+		t.Fatal(err)
+	}
+	if behind.blocks != 0 || synced.blocks != 1 {
+		t.Errorf("requests = %d, %d, want only the synced node", behind.blocks, synced.blocks)
+	}
+}
+
+func TestPoolRateLimitsForcedRefresh(t *testing.T) {
+	n := &fakeNode{height: 5}
+	p := newTestPool(n)
+
+	resp, err := p.GetBlock(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil {
+		t.Fatal("GetBlock() of a future height has no error response")
+	}
+	// The forced check right after the first one is skipped
+	if n.statuses != 1 {
+		t.Fatalf("status requests = %d, want 1", n.statuses)
+	}
+	n.setHeight(6)
+	if resp, _ = p.GetBlock(6); resp.Error == nil {
+		t.Fatal("heights are checked again before the forced check interval")
+	}
+	if n.statuses != 1 {
+		t.Fatalf("status requests = %d, want 1", n.statuses)
+	}
+
+	p.mu.Lock()
+	p.lastCheck = time.Now().Add(-forcedCheckInterval)
+	p.mu.Unlock()
+	resp, err = p.GetBlock(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("GetBlock() = %s after the forced check", resp.Error.Message)
+	}
+	if n.statuses != 2 {
+		t.Errorf("status requests = %d, want 2", n.statuses)
+	}
+}
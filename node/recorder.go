@@ -0,0 +1,99 @@
+package node
+
+import (
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+type recorder struct {
+	client     NodeClient
+	archive    archive
+	muBalances sync.Mutex
+	logger     *logrus.Entry
+}
+
+// Write successful responses of the client into the archive directory.
+// A failed write is logged and does not affect the response
+func NewRecorder(client NodeClient, dir string, logger *logrus.Entry) NodeClient {
+	return &recorder{
+		client:  client,
+		archive: archive{dir: dir},
+		logger:  logger,
+	}
+}
+
+func (r *recorder) GetStatus() (*responses.StatusResponse, error) {
+	return r.client.GetStatus()
+}
+
+func (r *recorder) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	resp, err := r.client.GetBlock(height)
+	if err == nil && resp.Error == nil {
+		r.write(r.archive.heightPath(height, archiveBlock), resp)
+	}
+	return resp, err
+}
+
+func (r *recorder) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	resp, err := r.client.GetBlockEvents(height)
+	if err == nil && resp.Error == nil {
+		r.write(r.archive.heightPath(height, archiveEvents), resp)
+	}
+	return resp, err
+}
+
+func (r *recorder) GetCandidates(height uint64, includeStakes bool) (*responses.CandidatesResponse, error) {
+	resp, err := r.client.GetCandidates(height, includeStakes)
+	if err == nil && resp.Error == nil {
+		name := archiveCandidates
+		if includeStakes {
+			name = archiveStakes
+		}
+		r.write(r.archive.heightPath(height, name), resp)
+	}
+	return resp, err
+}
+
+// Balances are requested in chunks, all of them are merged into one file of the height
+func (r *recorder) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	resp, err := r.client.GetAddresses(addresses, height)
+	if err != nil || resp.Error != nil {
+		return resp, err
+	}
+
+	r.muBalances.Lock()
+	defer r.muBalances.Unlock()
+
+	path := r.archive.heightPath(height, archiveBalances)
+	stored := new(responses.BalancesResponse)
+	_ = r.archive.read(path, stored)
+	index := make(map[string]int)
+	for i, b := range stored.Result {
+		index[b.Address] = i
+	}
+	for _, b := range resp.Result {
+		if i, ok := index[b.Address]; ok {
+			stored.Result[i] = b
+			continue
+		}
+		index[b.Address] = len(stored.Result)
+		stored.Result = append(stored.Result, b)
+	}
+	r.write(path, stored)
+	return resp, err
+}
+
+func (r *recorder) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	resp, err := r.client.GetCoinInfo(symbol)
+	if err == nil && resp.Error == nil {
+		r.write(r.archive.coinPath(symbol), resp)
+	}
+	return resp, err
+}
+
+func (r *recorder) write(path string, value interface{}) {
+	if err := r.archive.write(path, value); err != nil {
+		r.logger.WithField("path", path).Error(err)
+	}
+}
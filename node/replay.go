@@ -0,0 +1,94 @@
+package node
+
+import (
+	"fmt"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+	"os"
+	"strconv"
+)
+
+type replay struct {
+	archive archive
+}
+
+// Node source which serves responses from the archive written by the recorder.
+// The latest height is the highest stored block, heights which are not stored
+// get the same error response as not yet created blocks on a node
+func NewReplay(dir string) NodeClient {
+	return &replay{archive: archive{dir: dir}}
+}
+
+func (r *replay) GetStatus() (*responses.StatusResponse, error) {
+	height, err := r.archive.lastHeight()
+	if err != nil {
+		return nil, err
+	}
+	resp := new(responses.StatusResponse)
+	resp.Result.LatestBlockHeight = strconv.FormatUint(height, 10)
+	return resp, nil
+}
+
+func (r *replay) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	resp := new(responses.BlockResponse)
+	err := r.archive.read(r.archive.heightPath(height, archiveBlock), resp)
+	if os.IsNotExist(err) {
+		resp.Error = &responses.Error{Message: fmt.Sprintf("block %d is not in the archive", height)}
+		return resp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = DecodeTransactionsData(resp.Result.Transactions); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *replay) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	resp := new(responses.EventsResponse)
+	if err := r.archive.read(r.archive.heightPath(height, archiveEvents), resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *replay) GetCandidates(height uint64, includeStakes bool) (*responses.CandidatesResponse, error) {
+	name := archiveCandidates
+	if includeStakes {
+		name = archiveStakes
+	}
+	resp := new(responses.CandidatesResponse)
+	if err := r.archive.read(r.archive.heightPath(height, name), resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *replay) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	stored := new(responses.BalancesResponse)
+	if err := r.archive.read(r.archive.heightPath(height, archiveBalances), stored); err != nil {
+		return nil, err
+	}
+	balances := make(map[string]responses.Balance, len(stored.Result))
+	for _, b := range stored.Result {
+		balances[b.Address] = b
+	}
+
+	resp := new(responses.BalancesResponse)
+	for _, address := range addresses {
+		b, ok := balances[unquoteAddress(address)]
+		if !ok {
+			return nil, fmt.Errorf("balance of %s at height %d is not in the archive", unquoteAddress(address), height)
+		}
+		resp.Result = append(resp.Result, b)
+	}
+	return resp, nil
+}
+
+func (r *replay) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	resp := new(responses.CoinInfoResponse)
+	if err := r.archive.read(r.archive.coinPath(symbol), resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,79 @@
+package node
+
+import (
+	"context"
+	"github.com/MinterTeam/minter-explorer-extender/retry"
+	"github.com/MinterTeam/minter-node-go-api/responses"
+)
+
+type retryClient struct {
+	ctx    context.Context
+	client NodeClient
+	policy *retry.Policy
+}
+
+// Repeat failed requests of the client with the policy until the context is done.
+// Any error of a request is considered transient
+func WithRetry(ctx context.Context, client NodeClient, policy *retry.Policy) NodeClient {
+	return &retryClient{ctx: ctx, client: client, policy: policy}
+}
+
+func (c *retryClient) GetStatus() (*responses.StatusResponse, error) {
+	var resp *responses.StatusResponse
+	err := c.do(func() (err error) {
+		resp, err = c.client.GetStatus()
+		return err
+	})
+	return resp, err
+}
+
+func (c *retryClient) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	var resp *responses.BlockResponse
+	err := c.do(func() (err error) {
+		resp, err = c.client.GetBlock(height)
+		return err
+	})
+	return resp, err
+}
+
+func (c *retryClient) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	var resp *responses.EventsResponse
+	err := c.do(func() (err error) {
+		resp, err = c.client.GetBlockEvents(height)
+		return err
+	})
+	return resp, err
+}
+
+func (c *retryClient) GetCandidates(height uint64, includeStakes bool) (*responses.CandidatesResponse, error) {
+	var resp *responses.CandidatesResponse
+	err := c.do(func() (err error) {
+		resp, err = c.client.GetCandidates(height, includeStakes)
+		return err
+	})
+	return resp, err
+}
+
+func (c *retryClient) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	var resp *responses.BalancesResponse
+	err := c.do(func() (err error) {
+		resp, err = c.client.GetAddresses(addresses, height)
+		return err
+	})
+	return resp, err
+}
+
+func (c *retryClient) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	var resp *responses.CoinInfoResponse
+	err := c.do(func() (err error) {
+		resp, err = c.client.GetCoinInfo(symbol)
+		return err
+	})
+	return resp, err
+}
+
+func (c *retryClient) do(request func() error) error {
+	return c.policy.Do(c.ctx, func() error {
+		return retry.Transient(request())
+	})
+}
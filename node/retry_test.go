@@ -0,0 +1,58 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"github.com/MinterTeam/minter-explorer-extender/retry"
+	"testing"
+	"time"
+)
+
+func TestRetryRepeatsFailedRequests(t *testing.T) {
+	n := &fakeNode{height: 10, fail: errors.New("timeout")}
+	client := WithRetry(context.Background(), n, retry.NewPolicy(3, time.Millisecond, time.Millisecond, testLogger()))
+
+	if _, err := client.GetBlock(5); err == nil {
+		t.Fatal("GetBlock() succeeded on a failing node")
+	}
+	if n.blocks != 3 {
+		t.Errorf("requests = %d, want 3 attempts", n.blocks)
+	}
+}
+
+func TestRetryStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := &fakeNode{height: 10, fail: errors.New("timeout")}
+	client := WithRetry(ctx, n, retry.NewPolicy(10, time.Hour, time.Hour, testLogger()))
+
+	done := make(chan error)
+	go func() {
+		_, err := client.GetBlock(5)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("GetBlock() succeeded on a failing node")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("requests are retried after the context is done")
+	}
+	if n.blocks != 1 {
+		t.Errorf("requests = %d, want 1", n.blocks)
+	}
+}
+
+func TestRetryReturnsSuccessfulResponse(t *testing.T) {
+	n := &fakeNode{height: 10}
+	client := WithRetry(context.Background(), n, retry.NewPolicy(3, time.Millisecond, time.Millisecond, testLogger()))
+
+	resp, err := client.GetBlock(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.Height != "5" || n.blocks != 1 {
+		t.Errorf("GetBlock() = %+v after %d requests, want block 5 after 1", resp, n.blocks)
+	}
+}
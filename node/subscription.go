@@ -0,0 +1,123 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	subscriptionReconnectDelay = 5 * time.Second
+	subscriptionReadTimeout    = time.Minute
+)
+
+// Notifications about new blocks from the Tendermint RPC websocket (ws://host:26657/websocket)
+type BlockSubscription struct {
+	connected int32 // updated atomically
+	link      string
+	newBlocks chan struct{}
+	logger    *logrus.Entry
+}
+
+func NewBlockSubscription(link string, logger *logrus.Entry) *BlockSubscription {
+	return &BlockSubscription{
+		link:      link,
+		newBlocks: make(chan struct{}, 1),
+		logger:    logger,
+	}
+}
+
+// Receives a value when a new block is committed. Notifications are not queued,
+// a receiver should check the node for all blocks it has not processed yet
+func (s *BlockSubscription) NewBlocks() <-chan struct{} {
+	return s.newBlocks
+}
+
+// Whether the subscription is active. Consumers should poll the node while it is not
+func (s *BlockSubscription) IsConnected() bool {
+	return atomic.LoadInt32(&s.connected) == 1
+}
+
+// Keep the subscription until the context is done, reconnecting after drops
+func (s *BlockSubscription) Run(ctx context.Context) {
+	for {
+		err := s.listen(ctx)
+		atomic.StoreInt32(&s.connected, 0)
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.WithField("link", s.link).Warn("New block subscription dropped, polling the node: ", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(subscriptionReconnectDelay):
+		}
+	}
+}
+
+func (s *BlockSubscription) listen(ctx context.Context) error {
+	conn, _, err := websocket.DefaultDialer.Dial(s.link, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Unblock ReadMessage on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	err = conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "subscribe",
+		"id":      "extender",
+		"params":  map[string]string{"query": "tm.event='NewBlock'"},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Blocks are created every few seconds, a silent connection is considered dropped
+	_ = conn.SetReadDeadline(time.Now().Add(subscriptionReadTimeout))
+	conn.SetPingHandler(func(data string) error {
+		_ = conn.SetReadDeadline(time.Now().Add(subscriptionReadTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+
+	// The first message confirms the subscription
+	confirmation := new(struct {
+		Error *struct {
+			Message string `json:"message"`
+			Data    string `json:"data"`
+		} `json:"error"`
+	})
+	if err = conn.ReadJSON(confirmation); err != nil {
+		return err
+	}
+	if confirmation.Error != nil {
+		return fmt.Errorf("%s %s", confirmation.Error.Message, confirmation.Error.Data)
+	}
+	atomic.StoreInt32(&s.connected, 1)
+	s.logger.WithField("link", s.link).Info("Subscribed to new blocks")
+
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			return err
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(subscriptionReadTimeout))
+		select {
+		case s.newBlocks <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package partition
+
+import (
+	"fmt"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+)
+
+// Partition of a table for a range of heights
+type Partition struct {
+	Name       string
+	LowerBound uint64
+	UpperBound uint64 // exclusive
+}
+
+// Partitions of tables partitioned by block_id
+type Repository interface {
+	WithTx(tx *pg.Tx) Repository
+	GetAll(table string) ([]*Partition, error)
+	Create(table string, from, to uint64) error
+	Detach(table, partition string) error
+}
+
+type pgRepository struct {
+	db orm.DB
+}
+
+func NewRepository(db orm.DB) Repository {
+	return &pgRepository{
+		db: db,
+	}
+}
+
+// Return a copy of the repository which runs all queries inside the transaction
+func (r *pgRepository) WithTx(tx *pg.Tx) Repository {
+	return &pgRepository{
+		db: tx,
+	}
+}
+
+// Attached partitions of the table ordered by their upper bound
+func (r *pgRepository) GetAll(table string) ([]*Partition, error) {
+	var partitions []*Partition
+	_, err := r.db.Query(&partitions, `
+select c.relname as name,
+       substring(pg_get_expr(c.relpartbound, c.oid) from 'FROM \(''*([0-9]+)')::bigint as lower_bound,
+       substring(pg_get_expr(c.relpartbound, c.oid) from 'TO \(''*([0-9]+)')::bigint as upper_bound
+from pg_inherits i
+         inner join pg_class c on c.oid = i.inhrelid
+where i.inhparent = ?::regclass
+order by upper_bound;
+	`, "public."+table)
+	return partitions, err
+}
+
+// Create the partition of heights from the lower bound up to the upper one, which is not included
+func (r *pgRepository) Create(table string, from, to uint64) error {
+	_, err := r.db.Exec(`create table if not exists ? partition of ? for values from (?) to (?);`,
+		pg.F(fmt.Sprintf("public.%s_%d", table, from)), pg.F("public."+table), from, to)
+	return err
+}
+
+// Detached partition stays a standalone table with its rows
+func (r *pgRepository) Detach(table, partition string) error {
+	_, err := r.db.Exec(`alter table ? detach partition ?;`, pg.F("public."+table), pg.F("public."+partition))
+	return err
+}
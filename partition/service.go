@@ -0,0 +1,106 @@
+package partition
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+// Tables partitioned by range of block_id
+var Tables = []string{"transactions", "transaction_outputs", "rewards", "block_validator", "index_transaction_by_address"}
+
+// Creates partitions of upcoming heights and optionally detaches old ones
+type Service struct {
+	repository Repository
+	size       uint64 // heights in one partition
+	ahead      uint64 // count of partitions kept above the head
+	retain     uint64 // count of partitions kept attached below the head, all if 0
+	logger     *logrus.Entry
+
+	mu        sync.Mutex
+	createdTo uint64 // lowest upper bound of the last partitions of all tables after the last Maintain
+}
+
+func NewService(repository Repository, size, ahead, retain int, logger *logrus.Entry) *Service {
+	return &Service{
+		repository: repository,
+		size:       uint64(size),
+		ahead:      uint64(ahead),
+		retain:     uint64(retain),
+		logger:     logger,
+	}
+}
+
+// Create partitions up to `ahead` partitions above the height and detach ones which end
+// more than `retain` partitions below it
+func (s *Service) Maintain(height uint64) error {
+	var createdTo uint64
+	for i, table := range Tables {
+		partitions, err := s.repository.GetAll(table)
+		if err != nil {
+			return err
+		}
+
+		var from uint64
+		if len(partitions) > 0 {
+			from = partitions[len(partitions)-1].UpperBound
+		}
+		for ; from <= height+s.ahead*s.size; from += s.size {
+			err = s.repository.Create(table, from, from+s.size)
+			if err != nil {
+				return err
+			}
+			s.logger.WithFields(logrus.Fields{"table": table, "from": from, "to": from + s.size}).Info("Partition created")
+		}
+		if i == 0 || from < createdTo {
+			createdTo = from
+		}
+
+		if s.retain == 0 || height <= s.retain*s.size {
+			continue
+		}
+		for _, p := range partitions {
+			if p.UpperBound > height-s.retain*s.size {
+				break
+			}
+			err = s.repository.Detach(table, p.Name)
+			if err != nil {
+				return err
+			}
+			s.logger.WithFields(logrus.Fields{"table": table, "partition": p.Name}).Warn("Partition detached")
+		}
+	}
+
+	s.mu.Lock()
+	s.createdTo = createdTo
+	s.mu.Unlock()
+	return nil
+}
+
+// Run Maintain before the block is saved if the height is less than a partition away from the last created one.
+// Scheduled runs keep the partitions ahead, so this only happens when they fail
+func (s *Service) EnsureAhead(height uint64) error {
+	s.mu.Lock()
+	createdTo := s.createdTo
+	s.mu.Unlock()
+	if height+s.size < createdTo {
+		return nil
+	}
+	return s.Maintain(height)
+}
+
+// Check that the heights from the given one are in attached partitions of every table.
+// Rows of detached partitions are not reachable through the partitioned tables, so they can not be replaced or deleted
+func (s *Service) CheckAttached(from uint64) error {
+	for _, table := range Tables {
+		partitions, err := s.repository.GetAll(table)
+		if err != nil {
+			return err
+		}
+		if len(partitions) > 0 && partitions[0].LowerBound > from {
+			return fmt.Errorf("heights below %d of %s are in detached partitions, data from height %d can not be changed",
+				partitions[0].LowerBound, table, from)
+		}
+	}
+	return nil
+}
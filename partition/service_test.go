@@ -0,0 +1,176 @@
+package partition
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-pg/pg"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Partitions of tables kept in memory, ordered by range as returned by PostgreSQL
+type fakeRepository struct {
+	partitions map[string][]*Partition
+	created    []string
+	detached   []string
+	createErr  error // returned by Create if set
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{partitions: make(map[string][]*Partition)}
+}
+
+func (r *fakeRepository) WithTx(tx *pg.Tx) Repository {
+	return r
+}
+
+func (r *fakeRepository) GetAll(table string) ([]*Partition, error) {
+	return r.partitions[table], nil
+}
+
+func (r *fakeRepository) Create(table string, from, to uint64) error {
+	if r.createErr != nil {
+		return r.createErr
+	}
+	name := fmt.Sprintf("%s_%d", table, from)
+	r.partitions[table] = append(r.partitions[table], &Partition{Name: name, LowerBound: from, UpperBound: to})
+	r.created = append(r.created, name)
+	return nil
+}
+
+func (r *fakeRepository) Detach(table, partition string) error {
+	list := r.partitions[table]
+	for i, p := range list {
+		if p.Name == partition {
+			r.partitions[table] = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+	r.detached = append(r.detached, partition)
+	return nil
+}
+
+func testLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	return logrus.NewEntry(logger)
+}
+
+func TestMaintainCreatesPartitionsAhead(t *testing.T) {
+	repository := newFakeRepository()
+	s := NewService(repository, 100, 2, 0, testLogger())
+
+	if err := s.Maintain(150); err != nil {
+		t.Fatal(err)
+	}
+	want := []*Partition{
+		{Name: "rewards_0", LowerBound: 0, UpperBound: 100},
+		{Name: "rewards_100", LowerBound: 100, UpperBound: 200},
+		{Name: "rewards_200", LowerBound: 200, UpperBound: 300},
+		{Name: "rewards_300", LowerBound: 300, UpperBound: 400},
+	}
+	if got := repository.partitions["rewards"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("partitions of rewards = %v, want %v", got, want)
+	}
+	if len(repository.created) != len(Tables)*len(want) {
+		t.Errorf("%d partitions created, want %d", len(repository.created), len(Tables)*len(want))
+	}
+
+	// Nothing is created while the head stays inside the kept range
+	repository.created = nil
+	if err := s.Maintain(190); err != nil {
+		t.Fatal(err)
+	}
+	if len(repository.created) != 0 {
+		t.Errorf("created %v, want none", repository.created)
+	}
+}
+
+func TestMaintainDetachesOldPartitions(t *testing.T) {
+	repository := newFakeRepository()
+	s := NewService(repository, 100, 1, 2, testLogger())
+	if err := s.Maintain(50); err != nil {
+		t.Fatal(err)
+	}
+	if len(repository.detached) != 0 {
+		t.Fatalf("detached %v below the retained range", repository.detached)
+	}
+
+	if err := s.Maintain(450); err != nil {
+		t.Fatal(err)
+	}
+	// Partitions which end at or below 450 - 2*100 are detached
+	var detached []string
+	for _, name := range repository.detached {
+		if strings.HasPrefix(name, "rewards_") {
+			detached = append(detached, name)
+		}
+	}
+	if want := []string{"rewards_0", "rewards_100"}; !reflect.DeepEqual(detached, want) {
+		t.Errorf("detached partitions of rewards = %v, want %v", detached, want)
+	}
+	if len(repository.detached) != 2*len(Tables) {
+		t.Errorf("%d partitions detached, want %d", len(repository.detached), 2*len(Tables))
+	}
+}
+
+func TestMaintainKeepsAllPartitionsWithoutRetain(t *testing.T) {
+	repository := newFakeRepository()
+	s := NewService(repository, 100, 1, 0, testLogger())
+
+	if err := s.Maintain(10000); err != nil {
+		t.Fatal(err)
+	}
+	if len(repository.detached) != 0 {
+		t.Errorf("detached %v, want none", repository.detached)
+	}
+}
+
+func TestEnsureAheadMaintainsNearLastPartition(t *testing.T) {
+	repository := newFakeRepository()
+	s := NewService(repository, 100, 2, 0, testLogger())
+	if err := s.Maintain(150); err != nil {
+		t.Fatal(err)
+	}
+
+	// The scheduled runs fail from here on
+	repository.createErr = errors.New("no space left")
+	if err := s.EnsureAhead(250); err != nil {
+		t.Errorf("EnsureAhead(250) = %v, want nil more than a partition below 400", err)
+	}
+	if err := s.EnsureAhead(300); err == nil {
+		t.Error("EnsureAhead(300) does not fail a partition below 400")
+	}
+
+	repository.createErr = nil
+	if err := s.EnsureAhead(300); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnsureAhead(350); err != nil {
+		t.Errorf("EnsureAhead(350) = %v after partitions up to 600 are created", err)
+	}
+}
+
+func TestCheckAttachedRejectsDetachedHeights(t *testing.T) {
+	repository := newFakeRepository()
+	s := NewService(repository, 100, 1, 2, testLogger())
+	if err := s.Maintain(50); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckAttached(0); err != nil {
+		t.Errorf("CheckAttached(0) = %v before partitions are detached", err)
+	}
+
+	if err := s.Maintain(450); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckAttached(150); err == nil {
+		t.Error("CheckAttached(150) accepts heights of the detached partition")
+	}
+	if err := s.CheckAttached(200); err != nil {
+		t.Errorf("CheckAttached(200) = %v, want nil for attached heights", err)
+	}
+}
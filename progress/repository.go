@@ -0,0 +1,60 @@
+package progress
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"time"
+)
+
+// Last completed height of a pipeline stage
+type Cursor struct {
+	tableName struct{} `sql:"stage_cursors"`
+	Stage     string   `sql:",pk"`
+	Height    uint64   `sql:",notnull"`
+	UpdatedAt time.Time
+}
+
+// Storage of stage cursors
+type Repository interface {
+	WithTx(tx *pg.Tx) Repository
+	Save(stage string, height uint64) error
+	GetAll() ([]*Cursor, error)
+	RollbackTo(height uint64) error
+}
+
+type pgRepository struct {
+	db orm.DB
+}
+
+func NewRepository(db orm.DB) Repository {
+	return &pgRepository{
+		db: db,
+	}
+}
+
+// Repository which runs queries inside the transaction
+func (r *pgRepository) WithTx(tx *pg.Tx) Repository {
+	return &pgRepository{db: tx}
+}
+
+// Save the height of the stage. A cursor never moves back, except by RollbackTo
+func (r *pgRepository) Save(stage string, height uint64) error {
+	_, err := r.db.Exec(`
+insert into stage_cursors (stage, height, updated_at) values (?, ?, now())
+on conflict (stage) do update set height     = greatest(stage_cursors.height, excluded.height),
+                                  updated_at = excluded.updated_at;
+	`, stage, height)
+	return err
+}
+
+func (r *pgRepository) GetAll() ([]*Cursor, error) {
+	var cursors []*Cursor
+	err := r.db.Model(&cursors).Order("stage ASC").Select()
+	return cursors, err
+}
+
+// Move cursors above the height back to it
+func (r *pgRepository) RollbackTo(height uint64) error {
+	_, err := r.db.Exec(`update stage_cursors set height = ?, updated_at = now() where height > ?;`, height, height)
+	return err
+}
@@ -0,0 +1,78 @@
+package progress
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Pipeline stages which have their own cursors
+const (
+	StageBlocks             = "blocks" // block, transactions, address index, rewards and slashes
+	StageBalances           = "balances"
+	StageValidators         = "validators"
+	StageStakes             = "stakes"
+	StageRewardsAggregation = "rewards_aggregation"
+	StageTxsIndex           = "txs_index"
+)
+
+var stageHeight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "extender_stage_height",
+	Help: "Last completed height of the pipeline stage",
+}, []string{"stage"})
+
+func init() {
+	prometheus.MustRegister(stageHeight)
+}
+
+type Service struct {
+	repository Repository
+	logger     *logrus.Entry
+}
+
+func NewService(repository Repository, logger *logrus.Entry) *Service {
+	return &Service{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+// Record the last completed height of the stage.
+// A failed write is only logged, the stage will be resumed from an older height
+func (s *Service) Complete(stage string, height uint64) {
+	err := s.repository.Save(stage, height)
+	if err != nil {
+		s.logger.WithField("stage", stage).Error(err)
+		return
+	}
+	stageHeight.WithLabelValues(stage).Set(float64(height))
+}
+
+// Record the height of the stage inside the transaction which stores its data
+func (s *Service) CompleteInTx(tx *pg.Tx, stage string, height uint64) error {
+	err := s.repository.WithTx(tx).Save(stage, height)
+	if err != nil {
+		return err
+	}
+	stageHeight.WithLabelValues(stage).Set(float64(height))
+	return nil
+}
+
+// Heights of all stages with cursors
+func (s *Service) GetAll() (map[string]uint64, error) {
+	cursors, err := s.repository.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	heights := make(map[string]uint64, len(cursors))
+	for _, c := range cursors {
+		heights[c.Stage] = c.Height
+		stageHeight.WithLabelValues(c.Stage).Set(float64(c.Height))
+	}
+	return heights, nil
+}
+
+// Move cursors above the height back to it inside the transaction which removes the data
+func (s *Service) RollbackTo(tx *pg.Tx, height uint64) error {
+	return s.repository.WithTx(tx).RollbackTo(height)
+}
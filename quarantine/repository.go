@@ -0,0 +1,82 @@
+package quarantine
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"time"
+)
+
+// Part of a block which could not be parsed or derived, stored with the raw node response
+type Item struct {
+	tableName  struct{} `sql:"quarantine"`
+	ID         uint64
+	Stage      string `sql:",notnull"`
+	BlockID    uint64 `sql:",notnull"`
+	Hash       string
+	Payload    string `sql:",notnull"`
+	Error      string `sql:",notnull"`
+	Attempts   uint   `sql:",notnull"`
+	CreatedAt  time.Time
+	ResolvedAt *time.Time
+}
+
+// Storage of quarantined items
+type Repository interface {
+	WithTx(tx *pg.Tx) Repository
+	Save(item *Item) error
+	FindUnresolved(stage string) ([]*Item, error)
+	MarkResolved(id uint64) error
+	SaveFailedAttempt(id uint64, cause string) error
+}
+
+type pgRepository struct {
+	db orm.DB
+}
+
+func NewRepository(db orm.DB) Repository {
+	return &pgRepository{
+		db: db,
+	}
+}
+
+// Return a copy of the repository which runs all queries inside the transaction
+func (r *pgRepository) WithTx(tx *pg.Tx) Repository {
+	return &pgRepository{
+		db: tx,
+	}
+}
+
+func (r *pgRepository) Save(item *Item) error {
+	_, err := r.db.Model(item).Insert()
+	return err
+}
+
+// Not resolved items in order of heights. Empty stage matches all stages
+func (r *pgRepository) FindUnresolved(stage string) ([]*Item, error) {
+	var items []*Item
+	query := r.db.Model(&items).Where("resolved_at IS NULL")
+	if stage != "" {
+		query = query.Where("stage = ?", stage)
+	}
+	err := query.Order("block_id ASC", "id ASC").Select()
+	return items, err
+}
+
+func (r *pgRepository) MarkResolved(id uint64) error {
+	_, err := r.db.Model(&Item{}).
+		Set("resolved_at = now()").
+		Set("attempts = attempts + 1").
+		Where("id = ?", id).
+		Update()
+	return err
+}
+
+// Keep the error of the last retry
+func (r *pgRepository) SaveFailedAttempt(id uint64, cause string) error {
+	_, err := r.db.Model(&Item{}).
+		Set("error = ?", cause).
+		Set("attempts = attempts + 1").
+		Where("id = ?", id).
+		Update()
+	return err
+}
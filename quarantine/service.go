@@ -0,0 +1,118 @@
+package quarantine
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/MinterTeam/minter-explorer-extender/retry"
+	"github.com/go-pg/pg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// Stages which quarantine their failed items
+const (
+	StageTransaction = "transaction"
+	StageEvent       = "event"
+)
+
+var quarantinedItems = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "extender_quarantined_items_total",
+	Help: "Count of items moved to the quarantine",
+}, []string{"stage"})
+
+func init() {
+	prometheus.MustRegister(quarantinedItems)
+}
+
+// Database which runs functions inside transactions, *pg.DB or the in-memory store
+type Transactor interface {
+	RunInTransaction(fn func(tx *pg.Tx) error) error
+}
+
+type Service struct {
+	repository Repository
+	logger     *logrus.Entry
+}
+
+func NewService(repository Repository, logger *logrus.Entry) *Service {
+	return &Service{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+// Store the item which failed with the cause inside the block transaction, so the rest of the block is saved.
+// Transient errors are returned as is, the whole block will be retried
+func (s *Service) Add(tx *pg.Tx, stage string, height uint64, hash string, payload interface{}, cause error) error {
+	if retry.IsTransient(cause) {
+		return cause
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	err = s.repository.WithTx(tx).Save(&Item{
+		Stage:     stage,
+		BlockID:   height,
+		Hash:      hash,
+		Payload:   string(raw),
+		Error:     cause.Error(),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	quarantinedItems.WithLabelValues(stage).Inc()
+	s.logger.WithFields(logrus.Fields{
+		"stage": stage,
+		"block": height,
+		"hash":  hash,
+	}).Error("Moved to quarantine: ", cause)
+	return nil
+}
+
+// Run the handler for every not resolved item of the stage (all stages if it is empty).
+// Each item is handled in its own transaction and marked as resolved in it, a failed item keeps the last error.
+// Return counts of resolved and failed items
+func (s *Service) Retry(db Transactor, stage string, handle func(tx *pg.Tx, item *Item) error) (int, int, error) {
+	items, err := s.repository.FindUnresolved(stage)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var resolved, failed int
+	for _, item := range items {
+		err = db.RunInTransaction(func(tx *pg.Tx) error {
+			if err := handle(tx, item); err != nil {
+				return err
+			}
+			return s.repository.WithTx(tx).MarkResolved(item.ID)
+		})
+		fields := logrus.Fields{
+			"id":    item.ID,
+			"stage": item.Stage,
+			"block": item.BlockID,
+			"hash":  item.Hash,
+		}
+		if err != nil {
+			failed++
+			s.logger.WithFields(fields).Error("Quarantined item failed again: ", err)
+			if err := s.repository.SaveFailedAttempt(item.ID, err.Error()); err != nil {
+				return resolved, failed, err
+			}
+			continue
+		}
+		resolved++
+		s.logger.WithFields(fields).Info("Quarantined item resolved")
+	}
+	return resolved, failed, nil
+}
+
+// Panics of parsing code are turned into errors, so one malformed item does not stop the extender
+func Recover(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("panic: %v", r)
+	}
+}
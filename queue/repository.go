@@ -0,0 +1,111 @@
+package queue
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"time"
+)
+
+// Kinds of refresh jobs
+const (
+	KindBalance = "balance" // key is an address without prefix
+	KindStakes  = "stakes"  // single job with an empty key
+)
+
+// Refresh of the state of the key up to the height. A key is queued once, queuing it again raises the height
+type Job struct {
+	tableName   struct{} `sql:"refresh_jobs"`
+	Kind        string   `sql:",pk"`
+	Key         string   `sql:",pk"`
+	Height      uint64   `sql:",notnull"`
+	LockedUntil *time.Time
+	CreatedAt   time.Time
+}
+
+// Queue of refresh jobs
+type Repository interface {
+	WithTx(tx *pg.Tx) Repository
+	Enqueue(kind string, keys []string, height uint64) error
+	Claim(kind string, limit int, lease time.Duration) ([]*Job, error)
+	Complete(kind string, keys []string, height uint64) error
+	Count(kind string) (int, error)
+	MinHeight(kind string) (uint64, error)
+}
+
+// Jobs persisted in PostgreSQL and shared by all extender processes
+type pgRepository struct {
+	db orm.DB
+}
+
+func NewRepository(db orm.DB) Repository {
+	return &pgRepository{
+		db: db,
+	}
+}
+
+// Return a copy of the repository which runs all queries inside the transaction
+func (r *pgRepository) WithTx(tx *pg.Tx) Repository {
+	return &pgRepository{
+		db: tx,
+	}
+}
+
+// Queue the keys or raise the height of already queued ones. A locked key stays locked,
+// so it is not claimed by another worker while it is handled, and is unlocked by Complete
+func (r *pgRepository) Enqueue(kind string, keys []string, height uint64) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.db.Exec(`
+insert into refresh_jobs (kind, key, height, created_at)
+select ?, unnest(?::text[]), ?, now()
+on conflict (kind, key) do update set height = greatest(refresh_jobs.height, excluded.height);
+	`, kind, pg.Array(keys), height)
+	return err
+}
+
+// Lock up to the limit of the oldest jobs of the kind for the lease time. Jobs locked by other
+// processes are skipped, jobs of a crashed process are taken again when the lease is over
+func (r *pgRepository) Claim(kind string, limit int, lease time.Duration) ([]*Job, error) {
+	var jobs []*Job
+	_, err := r.db.Query(&jobs, `
+update refresh_jobs
+set locked_until = now() + ? * interval '1 millisecond'
+where (kind, key) in (select kind, key
+                      from refresh_jobs
+                      where kind = ?
+                        and (locked_until is null or locked_until < now())
+                      order by created_at
+                      limit ? for update skip locked)
+returning kind, key, height, locked_until, created_at;
+	`, lease.Nanoseconds()/int64(time.Millisecond), kind, limit)
+	return jobs, err
+}
+
+// Remove handled jobs. A job queued again for a higher height than the handled one stays and is unlocked,
+// so the change is handled by the next claim
+func (r *pgRepository) Complete(kind string, keys []string, height uint64) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.db.Exec(`delete from refresh_jobs where kind = ? and key in (?) and height <= ?;`, kind, pg.In(keys), height)
+	if err != nil {
+		return err
+	}
+	// A separate statement sees heights raised while the deletion waited for their rows
+	_, err = r.db.Exec(`update refresh_jobs set locked_until = null where kind = ? and key in (?) and height > ?;`,
+		kind, pg.In(keys), height)
+	return err
+}
+
+// Count of queued jobs of the kind
+func (r *pgRepository) Count(kind string) (int, error) {
+	return r.db.Model((*Job)(nil)).Where("kind = ?", kind).Count()
+}
+
+// Lowest height of queued jobs of the kind, 0 if there are none
+func (r *pgRepository) MinHeight(kind string) (uint64, error) {
+	var height uint64
+	_, err := r.db.QueryOne(pg.Scan(&height), `select coalesce(min(height), 0) from refresh_jobs where kind = ?;`, kind)
+	return height, err
+}
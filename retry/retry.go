@@ -0,0 +1,125 @@
+package retry
+
+import (
+	"context"
+	"github.com/go-pg/pg"
+	"github.com/sirupsen/logrus"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts     = 5
+	DefaultInitialInterval = 500 * time.Millisecond
+	DefaultMaxInterval     = 30 * time.Second
+)
+
+// Exponential backoff with jitter and a maximum count of attempts
+type Policy struct {
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	logger          *logrus.Entry
+}
+
+// Not positive values are replaced with defaults
+func NewPolicy(maxAttempts int, initialInterval, maxInterval time.Duration, logger *logrus.Entry) *Policy {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if initialInterval <= 0 {
+		initialInterval = DefaultInitialInterval
+	}
+	if maxInterval <= 0 {
+		maxInterval = DefaultMaxInterval
+	}
+	return &Policy{
+		MaxAttempts:     maxAttempts,
+		InitialInterval: initialInterval,
+		MaxInterval:     maxInterval,
+		logger:          logger,
+	}
+}
+
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string {
+	return e.err.Error()
+}
+
+// Mark the error as worth another attempt
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return transientError{err: err}
+}
+
+// Errors marked by Transient, network errors and PostgreSQL errors of the connection exception,
+// transaction rollback, insufficient resources and operator intervention classes
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := err.(transientError); ok {
+		return true
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	if pgErr, ok := err.(pg.Error); ok {
+		code := pgErr.Field('C')
+		for _, class := range []string{"08", "40", "53", "57"} {
+			if strings.HasPrefix(code, class) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Run the operation until it succeeds, returns not transient error, the attempts are over or the context is done.
+// Every retry is logged with its cause
+func (p *Policy) Do(ctx context.Context, operation func() error) error {
+	interval := p.InitialInterval
+	for attempt := 1; ; attempt++ {
+		err := operation()
+		if err == nil {
+			return nil
+		}
+		if e, ok := err.(transientError); ok {
+			err = e.err
+		} else if !IsTransient(err) {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			return err
+		}
+
+		// Equal jitter: half of the interval is fixed, the rest is random
+		delay := interval/2 + time.Duration(rand.Int63n(int64(interval/2)+1))
+		p.logger.WithFields(logrus.Fields{
+			"attempt": attempt,
+			"delay":   delay.String(),
+		}).Warn("Retrying after error: ", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		interval *= 2
+		if interval > p.MaxInterval {
+			interval = p.MaxInterval
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"time"
+)
+
+// Results of a run
+const (
+	ResultOk    = "ok"
+	ResultError = "error"
+)
+
+// Last run of a scheduled job
+type Run struct {
+	tableName  struct{} `sql:"scheduled_jobs"`
+	Job        string   `sql:",pk"`
+	Height     uint64   `sql:",notnull"` // height which triggered the run, 0 for jobs run by time
+	StartedAt  time.Time
+	DurationMs int64  `sql:",notnull"`
+	Result     string `sql:",notnull"`
+	Error      string
+}
+
+// Storage of the last runs of jobs
+type Repository interface {
+	WithTx(tx *pg.Tx) Repository
+	Save(run *Run) error
+	GetAll() ([]*Run, error)
+}
+
+type pgRepository struct {
+	db orm.DB
+}
+
+func NewRepository(db orm.DB) Repository {
+	return &pgRepository{
+		db: db,
+	}
+}
+
+// Return a copy of the repository which runs all queries inside the transaction
+func (r *pgRepository) WithTx(tx *pg.Tx) Repository {
+	return &pgRepository{
+		db: tx,
+	}
+}
+
+// Replace the last run of the job
+func (r *pgRepository) Save(run *Run) error {
+	_, err := r.db.Model(run).
+		OnConflict("(job) DO UPDATE").
+		Set("height = EXCLUDED.height").
+		Set("started_at = EXCLUDED.started_at").
+		Set("duration_ms = EXCLUDED.duration_ms").
+		Set("result = EXCLUDED.result").
+		Set("error = EXCLUDED.error").
+		Insert()
+	return err
+}
+
+func (r *pgRepository) GetAll() ([]*Run, error) {
+	var runs []*Run
+	err := r.db.Model(&runs).Order("job ASC").Select()
+	return runs, err
+}
@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+var (
+	jobDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "extender_job_duration_seconds",
+		Help: "Duration of the last run of the scheduled job",
+	}, []string{"job"})
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "extender_job_runs_total",
+		Help: "Count of runs of the scheduled job by result, skipped runs overlapped a running one",
+	}, []string{"job", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(jobDuration, jobRuns)
+}
+
+// Periodic job. It runs every EveryBlocks indexed blocks or every Interval, or only when triggered if both are zero
+type Job struct {
+	Name        string
+	EveryBlocks uint64
+	Interval    time.Duration
+	Run         func(ctx context.Context, height uint64) error
+}
+
+// Runs periodic jobs by height or time. A job is never run concurrently with itself:
+// a run triggered while the previous one is not finished is skipped
+type Service struct {
+	repository Repository
+	jobs       map[string]*Job
+	ctx        context.Context // passed to jobs, set by Run
+	mu         sync.Mutex
+	running    map[string]bool
+	stopped    bool
+	wg         sync.WaitGroup
+	logger     *logrus.Entry
+}
+
+func NewService(repository Repository, logger *logrus.Entry) *Service {
+	return &Service{
+		repository: repository,
+		jobs:       make(map[string]*Job),
+		ctx:        context.Background(),
+		running:    make(map[string]bool),
+		logger:     logger,
+	}
+}
+
+// Register the job. Must be called before Run
+func (s *Service) Add(job *Job) {
+	s.jobs[job.Name] = job
+}
+
+// Run jobs with an interval until the context is done. The first run is after one interval.
+// Jobs get the context, so their retries stop with it
+func (s *Service) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.Interval <= 0 {
+			continue
+		}
+		go func(job *Job) {
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					s.Trigger(job.Name, 0)
+				}
+			}
+		}(job)
+	}
+}
+
+// Start jobs which run on the indexed height
+func (s *Service) OnHeight(height uint64) {
+	for _, job := range s.jobs {
+		if job.EveryBlocks > 0 && height%job.EveryBlocks == 0 {
+			s.Trigger(job.Name, height)
+		}
+	}
+}
+
+// Start the job in the background unless it is running or the scheduler is stopped
+func (s *Service) Trigger(name string, height uint64) {
+	job, ok := s.jobs[name]
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	if s.running[name] {
+		s.mu.Unlock()
+		jobRuns.WithLabelValues(name, "skipped").Inc()
+		s.logger.WithFields(logrus.Fields{"job": name, "height": height}).Warn("Job is still running, the run is skipped")
+		return
+	}
+	s.running[name] = true
+	s.wg.Add(1)
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		s.run(ctx, job, height)
+
+		s.mu.Lock()
+		delete(s.running, name)
+		s.mu.Unlock()
+	}()
+}
+
+func (s *Service) run(ctx context.Context, job *Job, height uint64) {
+	run := &Run{Job: job.Name, Height: height, StartedAt: time.Now(), Result: ResultOk}
+	err := job.Run(ctx, height)
+	duration := time.Since(run.StartedAt)
+	run.DurationMs = duration.Nanoseconds() / int64(time.Millisecond)
+	if err != nil {
+		run.Result = ResultError
+		run.Error = err.Error()
+		s.logger.WithFields(logrus.Fields{"job": job.Name, "height": height}).Error(err)
+	}
+	jobDuration.WithLabelValues(job.Name).Set(duration.Seconds())
+	jobRuns.WithLabelValues(job.Name, run.Result).Inc()
+
+	err = s.repository.Save(run)
+	if err != nil {
+		s.logger.Error(err)
+	}
+}
+
+// Stop starting jobs and wait until running ones are finished
+func (s *Service) Wait() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Last runs of all jobs
+func (s *Service) GetLastRuns() ([]*Run, error) {
+	return s.repository.GetAll()
+}
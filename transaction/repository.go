@@ -1,21 +1,46 @@
 package transaction
 
 import (
+	"encoding/json"
+	"github.com/MinterTeam/minter-explorer-extender/bulk"
 	"github.com/MinterTeam/minter-explorer-tools/models"
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 )
 
-type Repository struct {
-	db *pg.DB
+// Storage of transactions, their outputs and links
+type Repository interface {
+	WithTx(tx *pg.Tx) Repository
+	Save(transaction *models.Transaction) error
+	SaveAll(transactions []*models.Transaction) error
+	SaveAllInvalid(transactions []*models.InvalidTransaction) error
+	SaveAllTxOutputs(outputs []*models.TransactionOutput, blockId uint64) error
+	LinkWithValidators(links []*models.TransactionValidator) error
+	IndexTxAddress(txsId []uint64) error
+	IndexTxAddressInRange(from, to uint64) error
 }
 
-func NewRepository(db *pg.DB) *Repository {
-	return &Repository{
-		db: db,
+type pgRepository struct {
+	db            orm.DB
+	copyThreshold int // batches of at least this size are loaded with COPY, never if negative
+}
+
+func NewRepository(db orm.DB, copyThreshold int) Repository {
+	return &pgRepository{
+		db:            db,
+		copyThreshold: copyThreshold,
+	}
+}
+
+// Return a copy of the repository which runs all queries inside the transaction
+func (r *pgRepository) WithTx(tx *pg.Tx) Repository {
+	return &pgRepository{
+		db:            tx,
+		copyThreshold: r.copyThreshold,
 	}
 }
 
-func (r *Repository) Save(transaction *models.Transaction) error {
+func (r *pgRepository) Save(transaction *models.Transaction) error {
 	_, err := r.db.Model(transaction).Insert()
 	if err != nil {
 		return err
@@ -23,7 +48,10 @@ func (r *Repository) Save(transaction *models.Transaction) error {
 	return nil
 }
 
-func (r *Repository) SaveAll(transactions []*models.Transaction) error {
+func (r *pgRepository) SaveAll(transactions []*models.Transaction) error {
+	if bulk.Enabled(r.copyThreshold, len(transactions)) {
+		return r.copyAll(transactions)
+	}
 	var args []interface{}
 	for _, t := range transactions {
 		args = append(args, t)
@@ -31,7 +59,52 @@ func (r *Repository) SaveAll(transactions []*models.Transaction) error {
 	return r.db.Insert(args...)
 }
 
-func (r *Repository) SaveAllInvalid(transactions []*models.InvalidTransaction) error {
+// COPY does not return generated values, so ids are taken from the sequence before the load
+func (r *pgRepository) copyAll(transactions []*models.Transaction) error {
+	var ids []uint64
+	_, err := r.db.Query(&ids, `select nextval('transactions_id_seq') from generate_series(1, ?)`, len(transactions))
+	if err != nil {
+		return err
+	}
+	c := bulk.NewCopy("transactions", "id", "from_address_id", "nonce", "gas_price", "gas", "block_id",
+		"gas_coin_id", "created_at", "type", "hash", "service_data", "data", "tags", "payload", "raw_tx")
+	for i, t := range transactions {
+		tags, err := json.Marshal(t.Tags)
+		if err != nil {
+			return err
+		}
+		err = c.Add(ids[i], t.FromAddressID, t.Nonce, t.GasPrice, t.Gas, t.BlockID, t.GasCoinID, t.CreatedAt,
+			t.Type, t.Hash, nullIfEmpty(t.ServiceData), t.Data, json.RawMessage(tags), nullIfNoBytes(t.Payload), t.RawTx)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Exec(r.db)
+	if err != nil {
+		return err
+	}
+	for i, t := range transactions {
+		t.ID = ids[i]
+	}
+	return nil
+}
+
+// Empty values are inserted as NULL by go-pg
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullIfNoBytes(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+func (r *pgRepository) SaveAllInvalid(transactions []*models.InvalidTransaction) error {
 	var args []interface{}
 	for _, t := range transactions {
 		args = append(args, t)
@@ -39,15 +112,39 @@ func (r *Repository) SaveAllInvalid(transactions []*models.InvalidTransaction) e
 	return r.db.Insert(args...)
 }
 
-func (r *Repository) SaveAllTxOutputs(output []*models.TransactionOutput) error {
-	var args []interface{}
-	for _, t := range output {
-		args = append(args, t)
+// Row of transaction_outputs with the height of its transaction, which is the partition key of the table
+type outputRow struct {
+	tableName struct{} `sql:"transaction_outputs"`
+	models.TransactionOutput
+	BlockID uint64 `sql:",notnull"`
+}
+
+func (r *pgRepository) SaveAllTxOutputs(outputs []*models.TransactionOutput, blockId uint64) error {
+	rows := make([]*outputRow, len(outputs))
+	for i, output := range outputs {
+		rows[i] = &outputRow{TransactionOutput: *output, BlockID: blockId}
 	}
-	return r.db.Insert(args...)
+	_, err := r.db.Model(&rows).Insert()
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		outputs[i].ID = row.ID
+	}
+	return nil
 }
 
-func (r *Repository) LinkWithValidators(links []*models.TransactionValidator) error {
+func (r *pgRepository) LinkWithValidators(links []*models.TransactionValidator) error {
+	if bulk.Enabled(r.copyThreshold, len(links)) {
+		c := bulk.NewCopy("transaction_validator", "transaction_id", "validator_id")
+		for _, l := range links {
+			err := c.Add(l.TransactionID, l.ValidatorID)
+			if err != nil {
+				return err
+			}
+		}
+		return c.Exec(r.db)
+	}
 	var args []interface{}
 	for _, t := range links {
 		args = append(args, t)
@@ -55,7 +152,7 @@ func (r *Repository) LinkWithValidators(links []*models.TransactionValidator) er
 	return r.db.Insert(args...)
 }
 
-func (r Repository) IndexTxAddress(txsId []uint64) error {
+func (r pgRepository) IndexTxAddress(txsId []uint64) error {
 	_, err := r.db.Query(nil, `
 insert into index_transaction_by_address (block_id, address_id, transaction_id)
   (select block_id, from_address_id, id
@@ -71,18 +168,19 @@ ON CONFLICT DO NOTHING;
 	return err
 }
 
-func (r Repository) IndexLastNTxAddress(txsNumber int) error {
+// Index transactions of blocks in the (from, to] range by addresses
+func (r pgRepository) IndexTxAddressInRange(from, to uint64) error {
 	_, err := r.db.Query(nil, `
 insert into index_transaction_by_address (block_id, address_id, transaction_id)
     (select it.block_id, it.from_address_id, it.id
      from transactions as it
-     where it.block_id > (select (id - ?) from blocks order by id desc limit 1)
+     where it.block_id > ? and it.block_id <= ?
      union
      select ot.block_id, touts.to_address_id, touts.transaction_id
      from transaction_outputs touts
             inner join transactions ot on touts.transaction_id = ot.id
-     where ot.block_id > (select (id - ?) from blocks order by id desc limit 1))
+     where ot.block_id > ? and ot.block_id <= ?)
 ON CONFLICT DO NOTHING;
-	`, txsNumber, txsNumber)
+	`, from, to, from, to)
 	return err
 }
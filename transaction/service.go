@@ -6,13 +6,16 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/MinterTeam/minter-explorer-extender/address"
-	"github.com/MinterTeam/minter-explorer-extender/broadcast"
+	"github.com/MinterTeam/minter-explorer-extender/bulk"
 	"github.com/MinterTeam/minter-explorer-extender/coin"
+	"github.com/MinterTeam/minter-explorer-extender/progress"
+	"github.com/MinterTeam/minter-explorer-extender/quarantine"
 	"github.com/MinterTeam/minter-explorer-extender/validator"
 	"github.com/MinterTeam/minter-explorer-tools/helpers"
 	"github.com/MinterTeam/minter-explorer-tools/models"
 	"github.com/MinterTeam/minter-go-node/core/check"
 	"github.com/MinterTeam/minter-node-go-api/responses"
+	"github.com/go-pg/pg"
 	"github.com/sirupsen/logrus"
 	"math"
 	"strconv"
@@ -21,255 +24,341 @@ import (
 
 type Service struct {
 	env                 *models.ExtenderEnvironment
-	txRepository        *Repository
-	addressRepository   *address.Repository
-	validatorRepository *validator.Repository
-	coinRepository      *coin.Repository
-	coinService         *coin.Service
-	broadcastService    *broadcast.Service
-	jobSaveTxs          chan []*models.Transaction
-	jobSaveTxsOutput    chan []*models.Transaction
-	jobSaveValidatorTxs chan []*models.TransactionValidator
-	jobSaveInvalidTxs   chan []*models.InvalidTransaction
+	txRepository        Repository
+	copyThreshold       int // batches of at least this size are not split into chunks, so they are loaded with COPY
+	addressRepository   address.Repository
+	validatorRepository validator.Repository
+	coinRepository      coin.Repository
+	progressService     *progress.Service
+	quarantineService   *quarantine.Service
 	logger              *logrus.Entry
 }
 
-func NewService(env *models.ExtenderEnvironment, repository *Repository, addressRepository *address.Repository,
-	validatorRepository *validator.Repository, coinRepository *coin.Repository, coinService *coin.Service,
-	broadcastService *broadcast.Service, logger *logrus.Entry) *Service {
+func NewService(env *models.ExtenderEnvironment, repository Repository, copyThreshold int, addressRepository address.Repository,
+	validatorRepository validator.Repository, coinRepository coin.Repository, progressService *progress.Service,
+	quarantineService *quarantine.Service, logger *logrus.Entry) *Service {
 	return &Service{
 		env:                 env,
+		copyThreshold:       copyThreshold,
+		progressService:     progressService,
+		quarantineService:   quarantineService,
 		txRepository:        repository,
 		coinRepository:      coinRepository,
 		addressRepository:   addressRepository,
-		coinService:         coinService,
 		validatorRepository: validatorRepository,
-		broadcastService:    broadcastService,
-		jobSaveTxs:          make(chan []*models.Transaction, env.WrkSaveTxsCount),
-		jobSaveTxsOutput:    make(chan []*models.Transaction, env.WrkSaveTxsOutputCount),
-		jobSaveValidatorTxs: make(chan []*models.TransactionValidator, env.WrkSaveValidatorTxsCount),
-		jobSaveInvalidTxs:   make(chan []*models.InvalidTransaction, env.WrkSaveInvTxsCount),
 		logger:              logger,
 	}
 }
 
-func (s *Service) GetSaveTxJobChannel() chan []*models.Transaction {
-	return s.jobSaveTxs
-}
-func (s *Service) GetSaveTxsOutputJobChannel() chan []*models.Transaction {
-	return s.jobSaveTxsOutput
-}
-func (s *Service) GetSaveInvalidTxsJobChannel() chan []*models.InvalidTransaction {
-	return s.jobSaveInvalidTxs
-}
-func (s *Service) GetSaveTxValidatorJobChannel() chan []*models.TransactionValidator {
-	return s.jobSaveValidatorTxs
+// Transaction with the rows derived from it. The transaction id is set to outputs and the validator link after it is saved
+type parsedTransaction struct {
+	transaction *models.Transaction
+	outputs     []*models.TransactionOutput
+	validatorID uint64
 }
 
-//Handle response and save block to DB
-func (s *Service) HandleTransactionsFromBlockResponse(blockHeight uint64, blockCreatedAt time.Time,
-	transactions []responses.Transaction) error {
+//Handle transactions from block response and save them inside the block transaction.
+//Transactions which could not be parsed are moved to the quarantine.
+//Return saved valid transactions
+func (s *Service) HandleTransactionsFromBlockResponse(dbTx *pg.Tx, blockHeight uint64, blockCreatedAt time.Time,
+	transactions []responses.Transaction) ([]*models.Transaction, error) {
 
-	var txList []*models.Transaction
+	var txList []*parsedTransaction
 	var invalidTxList []*models.InvalidTransaction
 
 	for _, tx := range transactions {
 		if tx.Log == nil {
-			transaction, err := s.handleValidTransaction(tx, blockHeight, blockCreatedAt)
+			transaction, err := s.parseValidTransaction(tx, blockHeight, blockCreatedAt, s.validatorRepository.FindIdByPkOrCreate)
 			if err != nil {
-				s.logger.Error(err)
-				return err
+				err = s.quarantineService.Add(dbTx, quarantine.StageTransaction, blockHeight, helpers.RemovePrefix(tx.Hash), tx, err)
+				if err != nil {
+					s.logger.Error(err)
+					return nil, err
+				}
+				continue
 			}
 			txList = append(txList, transaction)
 		} else {
-			transaction, err := s.handleInvalidTransaction(tx, blockHeight, blockCreatedAt)
+			transaction, err := s.parseInvalidTransaction(tx, blockHeight, blockCreatedAt)
 			if err != nil {
-				s.logger.Error(err)
-				return err
+				err = s.quarantineService.Add(dbTx, quarantine.StageTransaction, blockHeight, helpers.RemovePrefix(tx.Hash), tx, err)
+				if err != nil {
+					s.logger.Error(err)
+					return nil, err
+				}
+				continue
 			}
 			invalidTxList = append(invalidTxList, transaction)
 		}
 	}
 
+	repository := s.txRepository.WithTx(dbTx)
+
+	var saved []*models.Transaction
 	if len(txList) > 0 {
-		s.GetSaveTxJobChannel() <- txList
-		s.coinService.GetUpdateCoinsFromTxsJobChannel() <- txList
+		var err error
+		saved, err = s.saveTransactions(repository, blockHeight, txList)
+		if err != nil {
+			s.logger.Error(err)
+			return nil, err
+		}
 	}
 
 	if len(invalidTxList) > 0 {
-		s.GetSaveInvalidTxsJobChannel() <- invalidTxList
+		err := repository.SaveAllInvalid(invalidTxList)
+		if err != nil {
+			s.logger.Error(err)
+			return nil, err
+		}
 	}
 
-	return nil
+	return saved, nil
 }
 
-func (s *Service) SaveTransactionsWorker(jobs <-chan []*models.Transaction) {
-	for transactions := range jobs {
-		err := s.txRepository.SaveAll(transactions)
+// Save the quarantined transaction of the block inside the transaction. Parsing errors are returned
+func (s *Service) SaveQuarantined(dbTx *pg.Tx, blockHeight uint64, blockCreatedAt time.Time, tx responses.Transaction) error {
+	repository := s.txRepository.WithTx(dbTx)
+	if tx.Log != nil {
+		transaction, err := s.parseInvalidTransaction(tx, blockHeight, blockCreatedAt)
 		if err != nil {
-			s.logger.Error(err)
+			return err
 		}
-		helpers.HandleError(err)
-
-		links, err := s.getLinksTxValidator(transactions)
-		helpers.HandleError(err)
-		if len(links) > 0 {
-			chunksCount := int(math.Ceil(float64(len(links)) / float64(s.env.TxChunkSize)))
-			for i := 0; i < chunksCount; i++ {
-				start := s.env.TxChunkSize * i
-				end := start + s.env.TxChunkSize
-				if end > len(links) {
-					end = len(links)
-				}
-				s.GetSaveTxValidatorJobChannel() <- links[start:end]
+		return repository.SaveAllInvalid([]*models.InvalidTransaction{transaction})
+	}
+	transaction, err := s.parseValidTransaction(tx, blockHeight, blockCreatedAt, s.validatorRepository.FindIdByPkOrCreate)
+	if err != nil {
+		return err
+	}
+	_, err = s.saveTransactions(repository, blockHeight, []*parsedTransaction{transaction})
+	return err
+}
+
+// Rows which would be saved for transactions of a block and transactions which failed to parse
+type ParseResult struct {
+	Transactions        []*models.Transaction
+	Outputs             []*models.TransactionOutput
+	ValidatorLinks      int
+	InvalidTransactions []*models.InvalidTransaction
+	Failed              map[string]error // by hash
+}
+
+// Run parsing of the block transactions without writing anything. Validators must exist or be cached
+func (s *Service) ParseTransactions(blockHeight uint64, blockCreatedAt time.Time, transactions []responses.Transaction) *ParseResult {
+	result := &ParseResult{Failed: make(map[string]error)}
+	for _, tx := range transactions {
+		if tx.Log != nil {
+			transaction, err := s.parseInvalidTransaction(tx, blockHeight, blockCreatedAt)
+			if err != nil {
+				result.Failed[helpers.RemovePrefix(tx.Hash)] = err
+				continue
 			}
+			result.InvalidTransactions = append(result.InvalidTransactions, transaction)
+			continue
+		}
+		parsed, err := s.parseValidTransaction(tx, blockHeight, blockCreatedAt, s.validatorRepository.FindIdByPk)
+		if err != nil {
+			result.Failed[helpers.RemovePrefix(tx.Hash)] = err
+			continue
+		}
+		result.Transactions = append(result.Transactions, parsed.transaction)
+		result.Outputs = append(result.Outputs, parsed.outputs...)
+		if parsed.validatorID != 0 {
+			result.ValidatorLinks++
 		}
+	}
+	return result
+}
 
-		s.GetSaveTxsOutputJobChannel() <- transactions
+func (s *Service) saveTransactions(repository Repository, blockHeight uint64, list []*parsedTransaction) ([]*models.Transaction, error) {
+	transactions := make([]*models.Transaction, len(list))
+	for i, parsed := range list {
+		transactions[i] = parsed.transaction
+	}
+	err := repository.SaveAll(transactions)
+	if err != nil {
+		return nil, err
+	}
 
-		//no need to publish a big number of transaction
-		if len(transactions) > 10 {
-			go s.broadcastService.PublishTransactions(transactions[:10])
-		} else {
-			go s.broadcastService.PublishTransactions(transactions)
+	var (
+		links   []*models.TransactionValidator
+		outputs []*models.TransactionOutput
+		idsList []uint64
+	)
+	for _, parsed := range list {
+		if parsed.transaction.ID == 0 {
+			return nil, errors.New("no transaction id")
+		}
+		idsList = append(idsList, parsed.transaction.ID)
+		if parsed.validatorID != 0 {
+			links = append(links, &models.TransactionValidator{
+				TransactionID: parsed.transaction.ID,
+				ValidatorID:   parsed.validatorID,
+			})
+		}
+		for _, output := range parsed.outputs {
+			output.TransactionID = parsed.transaction.ID
+			outputs = append(outputs, output)
 		}
 	}
-}
-func (s *Service) SaveTransactionsOutputWorker(jobs <-chan []*models.Transaction) {
-	for transactions := range jobs {
-		err := s.SaveAllTxOutputs(transactions)
+
+	chunkSize := bulk.ChunkSize(s.copyThreshold, len(links), s.env.TxChunkSize)
+	chunksCount := int(math.Ceil(float64(len(links)) / float64(chunkSize)))
+	for i := 0; i < chunksCount; i++ {
+		start := chunkSize * i
+		end := start + chunkSize
+		if end > len(links) {
+			end = len(links)
+		}
+		err = repository.LinkWithValidators(links[start:end])
 		if err != nil {
-			s.logger.Error(err)
+			return nil, err
 		}
-		helpers.HandleError(err)
 	}
-}
-func (s *Service) SaveInvalidTransactionsWorker(jobs <-chan []*models.InvalidTransaction) {
-	for transactions := range jobs {
-		err := s.txRepository.SaveAllInvalid(transactions)
+
+	if len(outputs) > 0 {
+		err = repository.SaveAllTxOutputs(outputs, blockHeight)
 		if err != nil {
-			s.logger.Error(err)
+			return nil, err
 		}
-		helpers.HandleError(err)
 	}
-}
-
-func (s *Service) SaveTxValidatorWorker(jobs <-chan []*models.TransactionValidator) {
-	for links := range jobs {
-		err := s.txRepository.LinkWithValidators(links)
+	if len(idsList) > 0 {
+		err = repository.IndexTxAddress(idsList)
 		if err != nil {
-			s.logger.Error(err)
+			return nil, err
 		}
-		helpers.HandleError(err)
 	}
+
+	return transactions, nil
 }
 
-func (s *Service) UpdateTxsIndexWorker() {
-	for {
-		err := s.txRepository.IndexLastNTxAddress(s.env.WrkUpdateTxsIndexNumBlocks)
-		if err != nil {
-			s.logger.Error(err)
-		}
-		time.Sleep(time.Duration(s.env.WrkUpdateTxsIndexTime) * time.Second)
+// Re-index the last WrkUpdateTxsIndexNumBlocks blocks, or all blocks after the own cursor if it is further behind
+func (s *Service) UpdateTxsIndex() error {
+	cursors, err := s.progressService.GetAll()
+	if err != nil {
+		return err
+	}
+	head := cursors[progress.StageBlocks]
+	from := uint64(0)
+	if head > uint64(s.env.WrkUpdateTxsIndexNumBlocks) {
+		from = head - uint64(s.env.WrkUpdateTxsIndexNumBlocks)
 	}
+	if cursor, ok := cursors[progress.StageTxsIndex]; ok && cursor < from {
+		from = cursor
+	}
+	err = s.txRepository.IndexTxAddressInRange(from, head)
+	if err != nil {
+		return err
+	}
+	s.progressService.Complete(progress.StageTxsIndex, head)
+	return nil
 }
 
-func (s *Service) SaveAllTxOutputs(txList []*models.Transaction) error {
-	var (
-		list    []*models.TransactionOutput
-		idsList []uint64
-	)
+// Build the transaction with its outputs and validator. Only a new validator can be created by findValidatorId,
+// so a failed transaction can be skipped
+func (s *Service) parseValidTransaction(tx responses.Transaction, blockHeight uint64, blockCreatedAt time.Time,
+	findValidatorId func(pk string) (uint64, error)) (parsed *parsedTransaction, err error) {
+	defer quarantine.Recover(&err)
 
-	for _, tx := range txList {
-		if tx.ID == 0 {
-			return errors.New("no transaction id")
+	transaction, err := s.handleValidTransaction(tx, blockHeight, blockCreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := s.getTxOutputs(transaction)
+	if err != nil {
+		return nil, err
+	}
+	var validatorID uint64
+	if pk := ValidatorPubKey(transaction.Type, transaction.IData); pk != "" {
+		validatorID, err = findValidatorId(helpers.RemovePrefix(pk))
+		if err != nil {
+			return nil, err
 		}
+	}
+	return &parsedTransaction{
+		transaction: transaction,
+		outputs:     outputs,
+		validatorID: validatorID,
+	}, nil
+}
 
-		idsList = append(idsList, tx.ID)
+func (s *Service) parseInvalidTransaction(tx responses.Transaction, blockHeight uint64, blockCreatedAt time.Time) (transaction *models.InvalidTransaction, err error) {
+	defer quarantine.Recover(&err)
+	return s.handleInvalidTransaction(tx, blockHeight, blockCreatedAt)
+}
 
-		if tx.Type != models.TxTypeSend && tx.Type != models.TxTypeMultiSend && tx.Type != models.TxTypeRedeemCheck {
-			continue
-		}
+// Outputs of send, multisend and redeem check transactions without the transaction id
+func (s *Service) getTxOutputs(tx *models.Transaction) ([]*models.TransactionOutput, error) {
+	var list []*models.TransactionOutput
 
-		if tx.Type == models.TxTypeSend {
-			if tx.IData.(models.SendTxData).To == "" {
-				return errors.New("empty receiver of transaction")
-			}
+	if tx.Type == models.TxTypeSend {
+		if tx.IData.(models.SendTxData).To == "" {
+			return nil, errors.New("empty receiver of transaction")
+		}
 
-			toId, err := s.addressRepository.FindId(helpers.RemovePrefix(tx.IData.(models.SendTxData).To))
-			helpers.HandleError(err)
-			coinID, err := s.coinRepository.FindIdBySymbol(tx.IData.(models.SendTxData).Coin)
-			helpers.HandleError(err)
-			list = append(list, &models.TransactionOutput{
-				TransactionID: tx.ID,
-				ToAddressID:   toId,
-				CoinID:        coinID,
-				Value:         tx.IData.(models.SendTxData).Value,
-			})
+		toId, err := s.addressRepository.FindId(helpers.RemovePrefix(tx.IData.(models.SendTxData).To))
+		if err != nil {
+			return nil, err
 		}
-		if tx.Type == models.TxTypeMultiSend {
-			for _, receiver := range tx.IData.(models.MultiSendTxData).List {
-				toId, err := s.addressRepository.FindId(helpers.RemovePrefix(receiver.To))
-				helpers.HandleError(err)
-				coinID, err := s.coinRepository.FindIdBySymbol(receiver.Coin)
-				helpers.HandleError(err)
-				list = append(list, &models.TransactionOutput{
-					TransactionID: tx.ID,
-					ToAddressID:   toId,
-					CoinID:        coinID,
-					Value:         receiver.Value,
-				})
-			}
+		coinID, err := s.coinRepository.FindIdBySymbol(tx.IData.(models.SendTxData).Coin)
+		if err != nil {
+			return nil, err
 		}
-		if tx.Type == models.TxTypeRedeemCheck {
-			decoded, err := base64.StdEncoding.DecodeString(tx.IData.(models.RedeemCheckTxData).RawCheck)
-			if err != nil {
-				s.logger.WithFields(logrus.Fields{
-					"Tx": tx.Hash,
-				}).Error(err)
-				continue
-			}
-			data, err := check.DecodeFromBytes(decoded)
+		list = append(list, &models.TransactionOutput{
+			ToAddressID: toId,
+			CoinID:      coinID,
+			Value:       tx.IData.(models.SendTxData).Value,
+		})
+	}
+	if tx.Type == models.TxTypeMultiSend {
+		for _, receiver := range tx.IData.(models.MultiSendTxData).List {
+			toId, err := s.addressRepository.FindId(helpers.RemovePrefix(receiver.To))
 			if err != nil {
-				s.logger.WithFields(logrus.Fields{
-					"Tx": tx.Hash,
-				}).Error(err)
-				continue
+				return nil, err
 			}
-			sender, err := data.Sender()
+			coinID, err := s.coinRepository.FindIdBySymbol(receiver.Coin)
 			if err != nil {
-				s.logger.WithFields(logrus.Fields{
-					"Tx": tx.Hash,
-				}).Error(err)
-				continue
+				return nil, err
 			}
-
-			// We are put a creator of a check into "to" field
-			// because "from" field use for a person who created a transaction
-			toId, err := s.addressRepository.FindId(helpers.RemovePrefix(sender.String()))
-			helpers.HandleError(err)
-			coinID, err := s.coinRepository.FindIdBySymbol(data.Coin.String())
-			helpers.HandleError(err)
-
 			list = append(list, &models.TransactionOutput{
-				TransactionID: tx.ID,
-				ToAddressID:   toId,
-				CoinID:        coinID,
-				Value:         data.Value.String(),
+				ToAddressID: toId,
+				CoinID:      coinID,
+				Value:       receiver.Value,
 			})
 		}
 	}
+	if tx.Type == models.TxTypeRedeemCheck {
+		decoded, err := base64.StdEncoding.DecodeString(tx.IData.(models.RedeemCheckTxData).RawCheck)
+		if err != nil {
+			return nil, err
+		}
+		data, err := check.DecodeFromBytes(decoded)
+		if err != nil {
+			return nil, err
+		}
+		sender, err := data.Sender()
+		if err != nil {
+			return nil, err
+		}
 
-	if len(list) > 0 {
-		err := s.txRepository.SaveAllTxOutputs(list)
-		helpers.HandleError(err)
-	}
-	if len(idsList) > 0 {
-		err := s.txRepository.IndexTxAddress(idsList)
-		helpers.HandleError(err)
+		// We are put a creator of a check into "to" field
+		// because "from" field use for a person who created a transaction
+		toId, err := s.addressRepository.FindId(helpers.RemovePrefix(sender.String()))
+		if err != nil {
+			return nil, err
+		}
+		coinID, err := s.coinRepository.FindIdBySymbol(data.Coin.String())
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, &models.TransactionOutput{
+			ToAddressID: toId,
+			CoinID:      coinID,
+			Value:       data.Value.String(),
+		})
 	}
 
-	return nil
+	return list, nil
 }
 
 func (s *Service) handleValidTransaction(tx responses.Transaction, blockHeight uint64, blockCreatedAt time.Time) (*models.Transaction, error) {
@@ -338,40 +427,21 @@ func (s *Service) handleInvalidTransaction(tx responses.Transaction, blockHeight
 	}, nil
 }
 
-func (s *Service) getLinksTxValidator(transactions []*models.Transaction) ([]*models.TransactionValidator, error) {
-	var links []*models.TransactionValidator
-
-	for _, tx := range transactions {
-		if tx.ID == 0 {
-			return nil, errors.New("no transaction id")
-		}
-		var validatorPk string
-		switch tx.Type {
-		case models.TxTypeDeclareCandidacy:
-			validatorPk = tx.IData.(models.DeclareCandidacyTxData).PubKey
-		case models.TxTypeDelegate:
-			validatorPk = tx.IData.(models.DelegateTxData).PubKey
-		case models.TxTypeUnbound:
-			validatorPk = tx.IData.(models.UnbondTxData).PubKey
-		case models.TxTypeSetCandidateOnline:
-			validatorPk = tx.IData.(models.SetCandidateTxData).PubKey
-		case models.TxTypeSetCandidateOffline:
-			validatorPk = tx.IData.(models.SetCandidateTxData).PubKey
-		case models.TxTypeEditCandidate:
-			validatorPk = tx.IData.(models.EditCandidateTxData).PubKey
-		}
-
-		if validatorPk != "" {
-			validatorId, err := s.validatorRepository.FindIdByPkOrCreate(helpers.RemovePrefix(validatorPk))
-			if err != nil {
-				return nil, err
-			}
-			links = append(links, &models.TransactionValidator{
-				TransactionID: tx.ID,
-				ValidatorID:   validatorId,
-			})
-		}
+// Public key of the validator the transaction data is related to, empty for other transactions
+func ValidatorPubKey(txType uint8, data interface{}) string {
+	switch txType {
+	case models.TxTypeDeclareCandidacy:
+		return data.(models.DeclareCandidacyTxData).PubKey
+	case models.TxTypeDelegate:
+		return data.(models.DelegateTxData).PubKey
+	case models.TxTypeUnbound:
+		return data.(models.UnbondTxData).PubKey
+	case models.TxTypeSetCandidateOnline:
+		return data.(models.SetCandidateTxData).PubKey
+	case models.TxTypeSetCandidateOffline:
+		return data.(models.SetCandidateTxData).PubKey
+	case models.TxTypeEditCandidate:
+		return data.(models.EditCandidateTxData).PubKey
 	}
-
-	return links, nil
+	return ""
 }
@@ -6,13 +6,28 @@ import (
 	"sync"
 )
 
-type Repository struct {
+// Storage of validators and stakes. Ids of known public keys are cached
+type Repository interface {
+	FindIdByPk(pk string) (uint64, error)
+	CachePlaceholder(pk string, id uint64)
+	FindIdByPkOrCreate(pk string) (uint64, error)
+	SaveAllIfNotExist(validators []*models.Validator) error
+	FindAllByPK(validators []*models.Validator) ([]*models.Validator, error)
+	UpdateAll(validators []*models.Validator) error
+	Update(validator *models.Validator) error
+	DeleteStakesNotInListIds(idList []uint64) error
+	DeleteStakesByValidatorIds(idList []uint64) error
+	SaveAllStakes(stakes []*models.Stake) error
+	ResetAllStatuses() error
+}
+
+type pgRepository struct {
 	db    *pg.DB
 	cache *sync.Map
 }
 
-func NewRepository(db *pg.DB) *Repository {
-	return &Repository{
+func NewRepository(db *pg.DB) Repository {
+	return &pgRepository{
 		db:    db,
 		cache: new(sync.Map), //TODO: добавить реализацию очистки
 	}
@@ -20,7 +35,7 @@ func NewRepository(db *pg.DB) *Repository {
 
 //Find validator with public key.
 //Return Validator ID
-func (r *Repository) FindIdByPk(pk string) (uint64, error) {
+func (r *pgRepository) FindIdByPk(pk string) (uint64, error) {
 	//First look in the cache
 	id, ok := r.cache.Load(pk)
 	if ok {
@@ -35,9 +50,14 @@ func (r *Repository) FindIdByPk(pk string) (uint64, error) {
 	return validator.ID, nil
 }
 
+//Store the id of the validator which would be created to the cache only, used by the dry run
+func (r *pgRepository) CachePlaceholder(pk string, id uint64) {
+	r.cache.Store(pk, id)
+}
+
 //Find validator with public key or create if not exist.
 //Return Validator ID
-func (r *Repository) FindIdByPkOrCreate(pk string) (uint64, error) {
+func (r *pgRepository) FindIdByPkOrCreate(pk string) (uint64, error) {
 	id, _ := r.FindIdByPk(pk)
 	if id == 0 {
 		validator := &models.Validator{PublicKey: pk}
@@ -52,7 +72,7 @@ func (r *Repository) FindIdByPkOrCreate(pk string) (uint64, error) {
 }
 
 // Save list of validators if not exist
-func (r *Repository) SaveAllIfNotExist(validators []*models.Validator) error {
+func (r *pgRepository) SaveAllIfNotExist(validators []*models.Validator) error {
 	if r.isAllAddressesInCache(validators) {
 		return nil
 	}
@@ -81,7 +101,7 @@ func (r *Repository) SaveAllIfNotExist(validators []*models.Validator) error {
 // Find validators by PK
 // Update cache
 // Return slice of validators
-func (r *Repository) FindAllByPK(validators []*models.Validator) ([]*models.Validator, error) {
+func (r *pgRepository) FindAllByPK(validators []*models.Validator) ([]*models.Validator, error) {
 	var pkList []string
 	var vList []*models.Validator
 	for _, v := range validators {
@@ -95,7 +115,7 @@ func (r *Repository) FindAllByPK(validators []*models.Validator) ([]*models.Vali
 	return vList, err
 }
 
-func (r *Repository) UpdateAll(validators []*models.Validator) error {
+func (r *pgRepository) UpdateAll(validators []*models.Validator) error {
 	_, err := r.db.Model(&validators).
 		Column("status").
 		Column("commission").
@@ -107,11 +127,11 @@ func (r *Repository) UpdateAll(validators []*models.Validator) error {
 	return err
 }
 
-func (r *Repository) Update(validator *models.Validator) error {
+func (r *pgRepository) Update(validator *models.Validator) error {
 	return r.db.Update(validator)
 }
 
-func (r Repository) DeleteStakesNotInListIds(idList []uint64) error {
+func (r pgRepository) DeleteStakesNotInListIds(idList []uint64) error {
 	if len(idList) > 0 {
 		_, err := r.db.Query(nil, `delete from stakes where id not in (?);`, pg.In(idList))
 		return err
@@ -120,7 +140,7 @@ func (r Repository) DeleteStakesNotInListIds(idList []uint64) error {
 	return nil
 }
 
-func (r Repository) DeleteStakesByValidatorIds(idList []uint64) error {
+func (r pgRepository) DeleteStakesByValidatorIds(idList []uint64) error {
 	if len(idList) > 0 {
 		_, err := r.db.Query(nil, `delete from stakes where validator_id in (?);`, pg.In(idList))
 		return err
@@ -129,12 +149,12 @@ func (r Repository) DeleteStakesByValidatorIds(idList []uint64) error {
 	return nil
 }
 
-func (r *Repository) SaveAllStakes(stakes []*models.Stake) error {
+func (r *pgRepository) SaveAllStakes(stakes []*models.Stake) error {
 	_, err := r.db.Model(&stakes).OnConflict("(owner_address_id, validator_id, coin_id) DO UPDATE").Insert()
 	return err
 }
 
-func (r *Repository) addToCache(validators []*models.Validator) {
+func (r *pgRepository) addToCache(validators []*models.Validator) {
 	for _, v := range validators {
 		_, exist := r.cache.Load(v.PublicKey)
 		if !exist {
@@ -143,7 +163,7 @@ func (r *Repository) addToCache(validators []*models.Validator) {
 	}
 }
 
-func (r *Repository) isAllAddressesInCache(validators []*models.Validator) bool {
+func (r *pgRepository) isAllAddressesInCache(validators []*models.Validator) bool {
 	// look PK in cache
 	for _, v := range validators {
 		_, exist := r.cache.Load(v.PublicKey)
@@ -154,7 +174,7 @@ func (r *Repository) isAllAddressesInCache(validators []*models.Validator) bool
 	return true
 }
 
-func (r Repository) ResetAllStatuses() error {
+func (r pgRepository) ResetAllStatuses() error {
 	_, err := r.db.Query(nil, `update validators set status = null;`)
 	return err
 }
@@ -1,11 +1,15 @@
 package validator
 
 import (
+	"context"
 	"github.com/MinterTeam/minter-explorer-extender/address"
 	"github.com/MinterTeam/minter-explorer-extender/coin"
+	"github.com/MinterTeam/minter-explorer-extender/node"
+	"github.com/MinterTeam/minter-explorer-extender/progress"
+	"github.com/MinterTeam/minter-explorer-extender/queue"
+	"github.com/MinterTeam/minter-explorer-extender/retry"
 	"github.com/MinterTeam/minter-explorer-tools/helpers"
 	"github.com/MinterTeam/minter-explorer-tools/models"
-	"github.com/MinterTeam/minter-node-go-api"
 	"github.com/MinterTeam/minter-node-go-api/responses"
 	"github.com/sirupsen/logrus"
 	"math"
@@ -13,28 +17,38 @@ import (
 	"time"
 )
 
+const (
+	stakesJobLease    = 5 * time.Minute // time to update stakes before other workers can take the job
+	queuePollInterval = 1 * time.Second // delay before the next claim when the queue is empty
+)
+
 type Service struct {
 	env                 *models.ExtenderEnvironment
-	nodeApi             *minter_node_go_api.MinterNodeApi
-	repository          *Repository
-	addressRepository   *address.Repository
-	coinRepository      *coin.Repository
+	nodeApi             node.NodeClient
+	repository          Repository
+	addressRepository   address.Repository
+	coinRepository      coin.Repository
 	jobUpdateValidators chan uint64
-	jobUpdateStakes     chan uint64
+	queueRepository     queue.Repository
+	retryPolicy         *retry.Policy
+	progressService     *progress.Service
 	logger              *logrus.Entry
 }
 
-func NewService(env *models.ExtenderEnvironment, nodeApi *minter_node_go_api.MinterNodeApi, repository *Repository,
-	addressRepository *address.Repository, coinRepository *coin.Repository, logger *logrus.Entry) *Service {
+func NewService(env *models.ExtenderEnvironment, nodeApi node.NodeClient, repository Repository,
+	addressRepository address.Repository, coinRepository coin.Repository, retryPolicy *retry.Policy,
+	progressService *progress.Service, queueRepository queue.Repository, logger *logrus.Entry) *Service {
 	return &Service{
 		env:                 env,
+		progressService:     progressService,
+		queueRepository:     queueRepository,
+		retryPolicy:         retryPolicy,
 		nodeApi:             nodeApi,
 		repository:          repository,
 		addressRepository:   addressRepository,
 		coinRepository:      coinRepository,
 		logger:              logger,
 		jobUpdateValidators: make(chan uint64, 1),
-		jobUpdateStakes:     make(chan uint64, 1),
 	}
 }
 
@@ -42,8 +56,10 @@ func (s *Service) GetUpdateValidatorsJobChannel() chan uint64 {
 	return s.jobUpdateValidators
 }
 
-func (s *Service) GetUpdateStakesJobChannel() chan uint64 {
-	return s.jobUpdateStakes
+// Queue the stakes update at the height. All stakes are updated at once,
+// so a queued update which is not taken yet is moved to the new height
+func (s *Service) QueueStakesUpdate(height uint64) error {
+	return s.queueRepository.Enqueue(queue.KindStakes, []string{""}, height)
 }
 
 func (s *Service) UpdateValidatorsWorker(jobs <-chan uint64) {
@@ -51,6 +67,7 @@ func (s *Service) UpdateValidatorsWorker(jobs <-chan uint64) {
 		resp, err := s.nodeApi.GetCandidates(height, false)
 		if err != nil {
 			s.logger.Error(err)
+			continue
 		}
 
 		if len(resp.Result) > 0 {
@@ -118,95 +135,130 @@ func (s *Service) UpdateValidatorsWorker(jobs <-chan uint64) {
 			err = s.repository.UpdateAll(validators)
 			if err != nil {
 				s.logger.Error(err)
+				continue
 			}
 		}
+		s.progressService.Complete(progress.StageValidators, height)
 	}
 }
 
-func (s *Service) UpdateStakesWorker(jobs <-chan uint64) {
-	for height := range jobs {
-		resp, err := s.nodeApi.GetCandidates(height, true)
+// Claim queued stakes updates until the context is done
+func (s *Service) UpdateStakesWorker(ctx context.Context) {
+	for ctx.Err() == nil {
+		jobs, err := s.queueRepository.Claim(queue.KindStakes, 1, stakesJobLease)
 		if err != nil {
 			s.logger.Error(err)
 		}
-		var (
-			stakes       []*models.Stake
-			validatorIds = make([]uint64, len(resp.Result))
-			validators   = make([]*models.Validator, len(resp.Result))
-			addressesMap = make(map[string]struct{})
-		)
-
-		// Collect all PubKey's and addresses for save it before
-		for i, vlr := range resp.Result {
-			validators[i] = &models.Validator{PublicKey: helpers.RemovePrefix(vlr.PubKey)}
-			addressesMap[helpers.RemovePrefix(vlr.RewardAddress)] = struct{}{}
-			addressesMap[helpers.RemovePrefix(vlr.OwnerAddress)] = struct{}{}
-			for _, stake := range vlr.Stakes {
-				addressesMap[helpers.RemovePrefix(stake.Owner)] = struct{}{}
+		if len(jobs) == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(queuePollInterval):
 			}
+			continue
 		}
-
-		err = s.repository.SaveAllIfNotExist(validators)
+		// Failed updates stay locked until the lease is over and are taken again
+		height := jobs[0].Height
+		if err = s.updateStakes(height); err != nil {
+			s.logger.Error(err)
+			continue
+		}
+		err = s.queueRepository.Complete(queue.KindStakes, []string{jobs[0].Key}, height)
 		if err != nil {
 			s.logger.Error(err)
 		}
+		s.progressService.Complete(progress.StageStakes, height)
+	}
+}
+
+func (s *Service) updateStakes(height uint64) error {
+	resp, err := s.nodeApi.GetCandidates(height, true)
+	if err != nil {
+		return err
+	}
+	var (
+		stakes       []*models.Stake
+		validatorIds = make([]uint64, len(resp.Result))
+		validators   = make([]*models.Validator, len(resp.Result))
+		addressesMap = make(map[string]struct{})
+	)
+
+	// Collect all PubKey's and addresses for save it before
+	for i, vlr := range resp.Result {
+		validators[i] = &models.Validator{PublicKey: helpers.RemovePrefix(vlr.PubKey)}
+		addressesMap[helpers.RemovePrefix(vlr.RewardAddress)] = struct{}{}
+		addressesMap[helpers.RemovePrefix(vlr.OwnerAddress)] = struct{}{}
+		for _, stake := range vlr.Stakes {
+			addressesMap[helpers.RemovePrefix(stake.Owner)] = struct{}{}
+		}
+	}
+
+	err = s.repository.SaveAllIfNotExist(validators)
+	if err != nil {
+		s.logger.Error(err)
+	}
+
+	err = s.addressRepository.SaveFromMapIfNotExists(addressesMap)
+	if err != nil {
+		s.logger.Error(err)
+	}
 
-		err = s.addressRepository.SaveFromMapIfNotExists(addressesMap)
+	for i, vlr := range resp.Result {
+		id, err := s.repository.FindIdByPkOrCreate(helpers.RemovePrefix(vlr.PubKey))
 		if err != nil {
 			s.logger.Error(err)
+			continue
 		}
-
-		for i, vlr := range resp.Result {
-			id, err := s.repository.FindIdByPkOrCreate(helpers.RemovePrefix(vlr.PubKey))
+		validatorIds[i] = id
+		for _, stake := range vlr.Stakes {
+			ownerAddressID, err := s.addressRepository.FindIdOrCreate(helpers.RemovePrefix(stake.Owner))
 			if err != nil {
 				s.logger.Error(err)
 				continue
 			}
-			validatorIds[i] = id
-			for _, stake := range vlr.Stakes {
-				ownerAddressID, err := s.addressRepository.FindIdOrCreate(helpers.RemovePrefix(stake.Owner))
-				if err != nil {
-					s.logger.Error(err)
-					continue
-				}
-				coinID, err := s.coinRepository.FindIdBySymbol(stake.Coin)
-				if err != nil {
-					s.logger.Error(err)
-					continue
-				}
-				stakes = append(stakes, &models.Stake{
-					ValidatorID:    id,
-					OwnerAddressID: ownerAddressID,
-					CoinID:         coinID,
-					Value:          stake.Value,
-					BipValue:       stake.BipValue,
-				})
-			}
-		}
-
-		chunksCount := int(math.Ceil(float64(len(stakes)) / float64(s.env.StakeChunkSize)))
-		for i := 0; i < chunksCount; i++ {
-			start := s.env.StakeChunkSize * i
-			end := start + s.env.StakeChunkSize
-			if end > len(stakes) {
-				end = len(stakes)
-			}
-			err = s.repository.SaveAllStakes(stakes[start:end])
+			coinID, err := s.coinRepository.FindIdBySymbol(stake.Coin)
 			if err != nil {
 				s.logger.Error(err)
-				panic(err)
+				continue
 			}
+			stakes = append(stakes, &models.Stake{
+				ValidatorID:    id,
+				OwnerAddressID: ownerAddressID,
+				CoinID:         coinID,
+				Value:          stake.Value,
+				BipValue:       stake.BipValue,
+			})
 		}
+	}
+
+	err = s.saveStakes(stakes)
+	if err != nil {
+		// Stakes which are not saved must not be deleted, they will be updated with the next job
+		return err
+	}
 
-		stakesId := make([]uint64, len(stakes))
-		for i, stake := range stakes {
-			stakesId[i] = stake.ID
+	stakesId := make([]uint64, len(stakes))
+	for i, stake := range stakes {
+		stakesId[i] = stake.ID
+	}
+	return s.repository.DeleteStakesNotInListIds(stakesId)
+}
+
+func (s *Service) saveStakes(stakes []*models.Stake) error {
+	chunksCount := int(math.Ceil(float64(len(stakes)) / float64(s.env.StakeChunkSize)))
+	for i := 0; i < chunksCount; i++ {
+		start := s.env.StakeChunkSize * i
+		end := start + s.env.StakeChunkSize
+		if end > len(stakes) {
+			end = len(stakes)
 		}
-		err = s.repository.DeleteStakesNotInListIds(stakesId)
+		err := s.retryPolicy.Do(context.Background(), func() error {
+			return s.repository.SaveAllStakes(stakes[start:end])
+		})
 		if err != nil {
-			s.logger.Error(err)
+			return err
 		}
 	}
+	return nil
 }
 
 //Get validators PK from response and store it to validators table if not exist